@@ -0,0 +1,309 @@
+package admin
+
+//------------------------------------------------------------------------------------------
+//-----------------------------------------IMPORTS------------------------------------------
+//------------------------------------------------------------------------------------------
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/fccn/gofetch-snmp/config"
+	"github.com/fccn/gofetch-snmp/data"
+	"github.com/fccn/gofetch-snmp/devices"
+	. "github.com/fccn/gofetch-snmp/log"
+	"gopkg.in/yaml.v2"
+)
+
+//------------------------------------------------------------------------------------------
+//-----------------------------------------STRUCTS------------------------------------------
+//------------------------------------------------------------------------------------------
+type server struct {
+	conf   config.Admin
+	config interface{}                  //Effective Configuration, Redacted
+	hosts  []devices.Host               //In The Configured Order
+	byIP   map[string]devices.Host      //Configured Hosts By IP
+	poll   func(host devices.Host) bool //Collects A Single Host Right Away, False If It Is Already Being Collected
+
+	stopping <-chan struct{} //Closed When The Application Is Stopping, After Which No Host Is Polled
+}
+
+//Host As Listed By The API
+type hostInfo struct {
+	IP       string              `json:"ip"`
+	Type     string              `json:"type"`
+	Paused   bool                `json:"paused"`
+	Features []string            `json:"features"`
+	Status   *devices.HostStatus `json:"status,omitempty"` //Nil If It Wasn't Collected Yet
+}
+
+//------------------------------------------------------------------------------------------
+//----------------------------------------VARIABLES-----------------------------------------
+//------------------------------------------------------------------------------------------
+var enabled bool
+
+//Hosts Whose Collection Is Paused, By IP
+var paused = map[string]bool{}
+
+//Last Collected Data Of Each Host, Encoded As JSON, By IP
+var lastData = map[string][]byte{}
+
+var mutex sync.Mutex
+
+//Keys Of The Configuration Whose Values Are Not Shown
+var secret = regexp.MustCompile(`(?i)pass|secret|token|community|headers|authorization`)
+
+//------------------------------------------------------------------------------------------
+//----------------------------------------FUNCTIONS-----------------------------------------
+//------------------------------------------------------------------------------------------
+//Starts The Admin API, If Configured, Given The Effective Configurations, When The Application Is Stopping And How To Collect A Single Host
+func Serve(conf *config.Admin, c *config.Config, hosts devices.Hosts, stopping <-chan struct{}, poll func(host devices.Host) bool) error {
+	if conf == nil {
+		return nil
+	}
+	s := newServer(*conf, c, hosts, stopping, poll)
+	listener, err := net.Listen("tcp", s.conf.Address)
+	if err != nil {
+		return fmt.Errorf("Could Not Serve The Admin API: %v", err)
+	}
+	enabled = true
+	Log(fmt.Sprintf("Serving The Admin API On %s", s.conf.Address))
+	go func() {
+		if err := http.Serve(listener, s.handler()); err != nil {
+			ErrorLog(fmt.Sprintf("Admin API Stopped: %v", err))
+		}
+	}()
+	return nil
+}
+
+func newServer(conf config.Admin, c *config.Config, hosts devices.Hosts, stopping <-chan struct{}, poll func(host devices.Host) bool) *server {
+	s := &server{conf: conf, hosts: hosts.Hosts, byIP: map[string]devices.Host{}, poll: poll, stopping: stopping}
+	if s.conf.Address == "" {
+		s.conf.Address = ":8080"
+	}
+	for _, host := range hosts.Hosts {
+		if _, ok := s.byIP[host.IP]; !ok {
+			s.byIP[host.IP] = host
+		}
+	}
+	effective := map[string]interface{}{"config": redact(c), "hosts": redact(hosts)}
+	if db := data.InfluxConfig(); db != nil {
+		effective["db"] = redact(db)
+	}
+	s.config = effective
+	return s
+}
+
+//Routes The Requests Of The API
+func (s *server) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/hosts", s.auth(s.listHosts))
+	mux.HandleFunc("/hosts/", s.auth(s.host))
+	mux.HandleFunc("/config", s.auth(s.showConfig))
+	return mux
+}
+
+//Checks If The Collection Of A Host Is Paused
+func Paused(ip string) bool {
+	mutex.Lock()
+	defer mutex.Unlock()
+	return paused[ip]
+}
+
+//Keeps The Last Collected Data Of Each Host, If The API Is Enabled
+func Collected(d []*data.Data) {
+	if !enabled {
+		return
+	}
+	for _, dat := range d {
+		ip := dat.GetTag("device_ip")
+		if ip == "" {
+			continue
+		}
+		content, err := json.Marshal(dat)
+		if err != nil {
+			ErrorLog("Could Not Encode Data: " + err.Error())
+			continue
+		}
+		mutex.Lock()
+		lastData[ip] = content
+		mutex.Unlock()
+	}
+}
+
+//Requires The Token Or The Basic Authentication, If Any Of Them Is Configured
+func (s *server) auth(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.conf.Token == "" && s.conf.Username == "" {
+			handler(w, r)
+			return
+		}
+		if s.conf.Token != "" && equal(r.Header.Get("Authorization"), "Bearer "+s.conf.Token) {
+			handler(w, r)
+			return
+		}
+		if user, pass, ok := r.BasicAuth(); ok && s.conf.Username != "" && equal(user, s.conf.Username) && equal(pass, s.conf.Password) {
+			handler(w, r)
+			return
+		}
+		if s.conf.Username != "" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="gofetch"`)
+		}
+		reply(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+}
+
+func equal(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+//GET /hosts
+func (s *server) listHosts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		reply(w, http.StatusMethodNotAllowed, map[string]string{"error": "Method Not Allowed"})
+		return
+	}
+	hosts := []hostInfo{}
+	for _, host := range s.hosts {
+		hosts = append(hosts, info(host))
+	}
+	reply(w, http.StatusOK, hosts)
+}
+
+//GET /hosts/<ip>, GET /hosts/<ip>/data, POST /hosts/<ip>/poll, POST /hosts/<ip>/pause And POST /hosts/<ip>/resume
+func (s *server) host(w http.ResponseWriter, r *http.Request) {
+	path := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/hosts/"), "/"), "/")
+	host, ok := s.byIP[path[0]]
+	if !ok || len(path) > 2 {
+		reply(w, http.StatusNotFound, map[string]string{"error": "Unknown Host"})
+		return
+	}
+	action := ""
+	if len(path) == 2 {
+		action = path[1]
+	}
+
+	method := http.MethodPost
+	if action == "" || action == "data" {
+		method = http.MethodGet
+	}
+	if r.Method != method {
+		reply(w, http.StatusMethodNotAllowed, map[string]string{"error": "Method Not Allowed"})
+		return
+	}
+
+	switch action {
+	case "":
+		reply(w, http.StatusOK, info(host))
+	case "data":
+		mutex.Lock()
+		content, ok := lastData[host.IP]
+		mutex.Unlock()
+		if !ok {
+			reply(w, http.StatusNotFound, map[string]string{"error": "Host Was Not Collected Yet"})
+			return
+		}
+		reply(w, http.StatusOK, json.RawMessage(content))
+	case "poll":
+		if s.isStopping() {
+			reply(w, http.StatusServiceUnavailable, map[string]string{"error": "Stopping"})
+			return
+		}
+		if !s.poll(host) {
+			//The Application May Have Started Stopping Meanwhile
+			if s.isStopping() {
+				reply(w, http.StatusServiceUnavailable, map[string]string{"error": "Stopping"})
+				return
+			}
+			reply(w, http.StatusConflict, map[string]string{"error": "Host Is Already Being Collected"})
+			return
+		}
+		With(Fields{"host": host.IP}).Info("Collecting On Request")
+		reply(w, http.StatusAccepted, map[string]string{"status": "Collecting"})
+	case "pause", "resume":
+		mutex.Lock()
+		paused[host.IP] = action == "pause"
+		mutex.Unlock()
+		With(Fields{"host": host.IP}).Info("Collection Was " + map[bool]string{true: "Paused", false: "Resumed"}[action == "pause"])
+		reply(w, http.StatusOK, info(host))
+	default:
+		reply(w, http.StatusNotFound, map[string]string{"error": "Unknown Action"})
+	}
+}
+
+//GET /config
+func (s *server) showConfig(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		reply(w, http.StatusMethodNotAllowed, map[string]string{"error": "Method Not Allowed"})
+		return
+	}
+	reply(w, http.StatusOK, s.config)
+}
+
+func (s *server) isStopping() bool {
+	select {
+	case <-s.stopping:
+		return true
+	default:
+		return false
+	}
+}
+
+func info(host devices.Host) hostInfo {
+	i := hostInfo{IP: host.IP, Type: host.Type, Paused: Paused(host.IP), Features: host.Features.Enabled()}
+	if status, ok := devices.Status(host.IP); ok {
+		i.Status = &status
+	}
+	return i
+}
+
+func reply(w http.ResponseWriter, code int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(body); err != nil {
+		DebugLog("Could Not Write Admin API Reply: " + err.Error())
+	}
+}
+
+//Converts A Configuration Into What It Looks Like In YAML, Without The Values Of Secret Keys
+func redact(conf interface{}) interface{} {
+	var generic interface{}
+	content, err := yaml.Marshal(conf)
+	if err == nil {
+		err = yaml.Unmarshal(content, &generic)
+	}
+	if err != nil {
+		ErrorLog("Could Not Encode Configuration: " + err.Error())
+		return nil
+	}
+	return redactValue(generic)
+}
+
+//Turns YAML Maps Into JSON Maps, Replacing The Values Of Secret Keys
+func redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		m := map[string]interface{}{}
+		for key, val := range v {
+			k := fmt.Sprint(key)
+			if secret.MatchString(k) && val != nil && val != "" {
+				m[k] = "<redacted>"
+			} else {
+				m[k] = redactValue(val)
+			}
+		}
+		return m
+	case []interface{}:
+		for i := range v {
+			v[i] = redactValue(v[i])
+		}
+	}
+	return value
+}
@@ -0,0 +1,228 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fccn/gofetch-snmp/config"
+	"github.com/fccn/gofetch-snmp/data"
+	"github.com/fccn/gofetch-snmp/devices"
+	"gopkg.in/yaml.v2"
+)
+
+//Hosts Decoded As From The Hosts Configuration File
+func testHosts(t *testing.T) devices.Hosts {
+	t.Helper()
+	var hosts devices.Hosts
+	content := "Hosts:\n- IP: 10.0.0.1\n  Type: generic\n  SnmpConfig:\n    Version: 2\n    Community: private\n"
+	if err := yaml.Unmarshal([]byte(content), &hosts); err != nil {
+		t.Fatal(err)
+	}
+	return hosts
+}
+
+//Serves The API Over HTTP, Polling With The Given Function
+func newTestServer(t *testing.T, conf config.Admin, poll func(host devices.Host) bool) *httptest.Server {
+	t.Helper()
+	return newStoppingServer(t, conf, nil, poll)
+}
+
+//Serves The API Over HTTP, Stopping When The Channel Is Closed
+func newStoppingServer(t *testing.T, conf config.Admin, stopping <-chan struct{}, poll func(host devices.Host) bool) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(newServer(conf, &config.Config{}, testHosts(t), stopping, poll).handler())
+	t.Cleanup(server.Close)
+	return server
+}
+
+//Makes A Request, Returning The Status And The Decoded Body
+func request(t *testing.T, method, url string, prepare func(r *http.Request)) (int, map[string]interface{}) {
+	t.Helper()
+	r, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prepare != nil {
+		prepare(r)
+	}
+	response, err := http.DefaultClient.Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	body := map[string]interface{}{}
+	json.NewDecoder(response.Body).Decode(&body)
+	return response.StatusCode, body
+}
+
+func TestAuth(t *testing.T) {
+	bearer := func(token string) func(r *http.Request) {
+		return func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+token) }
+	}
+	basic := func(user, pass string) func(r *http.Request) {
+		return func(r *http.Request) { r.SetBasicAuth(user, pass) }
+	}
+	tests := []struct {
+		conf     config.Admin
+		prepare  func(r *http.Request)
+		expected int
+	}{
+		{config.Admin{}, nil, http.StatusOK},
+		{config.Admin{Token: "t0ken"}, nil, http.StatusUnauthorized},
+		{config.Admin{Token: "t0ken"}, bearer("wrong"), http.StatusUnauthorized},
+		{config.Admin{Token: "t0ken"}, bearer("t0ken"), http.StatusOK},
+		{config.Admin{Username: "admin", Password: "pass"}, nil, http.StatusUnauthorized},
+		{config.Admin{Username: "admin", Password: "pass"}, basic("admin", "wrong"), http.StatusUnauthorized},
+		{config.Admin{Username: "admin", Password: "pass"}, basic("admin", "pass"), http.StatusOK},
+		//Either Of Them Is Accepted When Both Are Configured
+		{config.Admin{Token: "t0ken", Username: "admin", Password: "pass"}, basic("admin", "pass"), http.StatusOK},
+		{config.Admin{Token: "t0ken", Username: "admin", Password: "pass"}, bearer("t0ken"), http.StatusOK},
+	}
+	for i, test := range tests {
+		server := newTestServer(t, test.conf, nil)
+		if status, _ := request(t, http.MethodGet, server.URL+"/hosts/10.0.0.1", test.prepare); status != test.expected {
+			t.Errorf("Case %d - Expected %d, Got %d", i, test.expected, status)
+		}
+	}
+
+	//The Basic Authentication Is Asked For
+	server := newTestServer(t, config.Admin{Username: "admin", Password: "pass"}, nil)
+	response, err := http.Get(server.URL + "/config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if response.Header.Get("WWW-Authenticate") == "" {
+		t.Error("Expected The Basic Authentication To Be Asked For")
+	}
+}
+
+func TestRouting(t *testing.T) {
+	polls := 0
+	server := newTestServer(t, config.Admin{}, func(host devices.Host) bool {
+		polls++
+		return polls == 1 //The Host Is Still Being Collected Afterwards
+	})
+	defer func() {
+		mutex.Lock()
+		delete(paused, "10.0.0.1")
+		mutex.Unlock()
+	}()
+
+	tests := []struct {
+		method   string
+		path     string
+		expected int
+	}{
+		{http.MethodGet, "/hosts", http.StatusOK},
+		{http.MethodPost, "/hosts", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/hosts/10.0.0.1", http.StatusOK},
+		{http.MethodGet, "/hosts/10.0.0.2", http.StatusNotFound},
+		{http.MethodGet, "/hosts/10.0.0.1/data", http.StatusNotFound}, //Not Collected Yet
+		{http.MethodGet, "/hosts/10.0.0.1/data/more", http.StatusNotFound},
+		{http.MethodPost, "/hosts/10.0.0.1/unknown", http.StatusNotFound},
+		{http.MethodGet, "/hosts/10.0.0.1/poll", http.StatusMethodNotAllowed},
+		{http.MethodPost, "/hosts/10.0.0.1/data", http.StatusMethodNotAllowed},
+		{http.MethodPost, "/config", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/unknown", http.StatusNotFound},
+		//A Poll While The Host Is Still Being Collected Is Refused
+		{http.MethodPost, "/hosts/10.0.0.1/poll", http.StatusAccepted},
+		{http.MethodPost, "/hosts/10.0.0.1/poll", http.StatusConflict},
+	}
+	for _, test := range tests {
+		if status, _ := request(t, test.method, server.URL+test.path, nil); status != test.expected {
+			t.Errorf("%s %s - Expected %d, Got %d", test.method, test.path, test.expected, status)
+		}
+	}
+
+	//Pausing And Resuming Is Told By The Host And Its Collection
+	for _, action := range []string{"pause", "resume"} {
+		status, body := request(t, http.MethodPost, server.URL+"/hosts/10.0.0.1/"+action, nil)
+		if status != http.StatusOK || body["paused"] != (action == "pause") || Paused("10.0.0.1") != (action == "pause") {
+			t.Errorf("%s - Unexpected Reply %d %v", action, status, body)
+		}
+	}
+}
+
+func TestPollStopping(t *testing.T) {
+	stopping := make(chan struct{})
+	polls := 0
+	server := newStoppingServer(t, config.Admin{}, stopping, func(host devices.Host) bool {
+		polls++
+		close(stopping) //Refused For Stopping Meanwhile
+		return false
+	})
+
+	//Once Stopping, No Host Is Polled
+	for i := 0; i < 2; i++ {
+		if status, _ := request(t, http.MethodPost, server.URL+"/hosts/10.0.0.1/poll", nil); status != http.StatusServiceUnavailable || polls != 1 {
+			t.Errorf("Case %d - Expected 503 After 1 Poll, Got %d After %d Polls", i, status, polls)
+		}
+	}
+	if status, _ := request(t, http.MethodGet, server.URL+"/hosts/10.0.0.1", nil); status != http.StatusOK {
+		t.Errorf("Expected The Host To Still Be Shown, Got %d", status)
+	}
+}
+
+func TestConfigRedacted(t *testing.T) {
+	//The InfluxDB Configuration Is Shown Along The Others
+	dbConfigFile := filepath.Join(t.TempDir(), "db.yml")
+	content := "version: 2\nserver: http://localhost:8086\norg: fccn\nbucket: gofetch\ntoken: influx-token\n"
+	if err := os.WriteFile(dbConfigFile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := data.InfluxInit(dbConfigFile); err != nil {
+		t.Fatal(err)
+	}
+
+	server := newTestServer(t, config.Admin{}, nil)
+	response, err := http.Get(server.URL + "/config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	var body map[string]map[string]interface{}
+	if err := json.NewDecoder(response.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body["db"]["token"] != "<redacted>" || body["db"]["bucket"] != "gofetch" {
+		t.Errorf("Expected The InfluxDB Configuration Without The Token, Got %v", body["db"])
+	}
+	hosts, _ := json.Marshal(body["hosts"])
+	if strings.Contains(string(hosts), "private") || !strings.Contains(string(hosts), "10.0.0.1") {
+		t.Errorf("Expected The Hosts Without The Community, Got %s", hosts)
+	}
+}
+
+func TestRedact(t *testing.T) {
+	conf := map[string]interface{}{
+		"outputs": map[string]interface{}{
+			"kafka": map[string]interface{}{
+				"brokers": []string{"localhost:9092"},
+				"sasl":    map[string]interface{}{"username": "gofetch", "password": "kafka-pass"},
+			},
+			"otlp": map[string]interface{}{"headers": map[string]string{"x-token": "header-token"}},
+		},
+		"events": []map[string]interface{}{{"url": "http://localhost", "secret": "hook-secret"}},
+		"admin":  map[string]interface{}{"password": ""}, //Empty Values Are Shown As Such
+	}
+	redacted, err := json.Marshal(redact(conf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, value := range []string{"kafka-pass", "header-token", "hook-secret"} {
+		if strings.Contains(string(redacted), value) {
+			t.Errorf("Expected %s To Be Redacted, Got %s", value, redacted)
+		}
+	}
+	for _, value := range []string{`"username":"gofetch"`, `"brokers":["localhost:9092"]`, `"password":""`} {
+		if !strings.Contains(string(redacted), value) {
+			t.Errorf("Expected %s To Be Kept, Got %s", value, redacted)
+		}
+	}
+}
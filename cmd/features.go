@@ -0,0 +1,38 @@
+package main
+
+//------------------------------------------------------------------------------------------
+//-----------------------------------------IMPORTS------------------------------------------
+//------------------------------------------------------------------------------------------
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/fccn/gofetch-snmp/devices"
+	. "github.com/fccn/gofetch-snmp/log"
+)
+
+//------------------------------------------------------------------------------------------
+//----------------------------------------FUNCTIONS-----------------------------------------
+//------------------------------------------------------------------------------------------
+//Prints The Features Supported By A Device Type, Or By All Types If None Is Given
+func featuresCommand(args []string) {
+	var deviceType string
+	flags := flag.NewFlagSet("features", flag.ExitOnError)
+	flags.StringVar(&deviceType, "type", deviceType, "Device Type")
+	flags.Parse(args)
+
+	types := devices.Types()
+	if deviceType != "" {
+		types = []string{strings.ToLower(deviceType)}
+	}
+
+	for _, t := range types {
+		features, ok := devices.Capabilities(t)
+		if !ok {
+			FatalLog(fmt.Sprintf("Unknown Device Type \"%s\", Must Be One Of: %s", t, strings.Join(devices.Types(), ", ")))
+		}
+		fmt.Fprintf(os.Stdout, "%s: %s\n", t, strings.Join(features, ", "))
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+//------------------------------------------------------------------------------------------
+//-----------------------------------------IMPORTS------------------------------------------
+//------------------------------------------------------------------------------------------
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/fccn/gofetch-snmp/data"
+	. "github.com/fccn/gofetch-snmp/log"
+)
+
+//------------------------------------------------------------------------------------------
+//----------------------------------------FUNCTIONS-----------------------------------------
+//------------------------------------------------------------------------------------------
+//Prints The Fields With Their Kind, Unit And Description, As A Markdown Table For The Documentation Or As JSON
+func fieldsCommand(args []string) {
+	var format string
+	flags := flag.NewFlagSet("fields", flag.ExitOnError)
+	flags.StringVar(&format, "format", "markdown", "Output Format, \"markdown\" Or \"json\"")
+	flags.Parse(args)
+
+	switch format {
+	case "markdown":
+		fmt.Fprintln(os.Stdout, "| Field | Kind | Unit | Description |")
+		fmt.Fprintln(os.Stdout, "|-|-|-|-|")
+		for _, f := range data.Schema() {
+			fmt.Fprintf(os.Stdout, "| `%s` | %s | `%s` | %s |\n", f.Name, f.Kind, f.Unit, f.Description)
+		}
+	case "json":
+		content, err := json.MarshalIndent(data.Schema(), "", "  ")
+		if err != nil {
+			FatalLog("Could Not Encode Fields: " + err.Error())
+		}
+		fmt.Fprintln(os.Stdout, string(content))
+	default:
+		FatalLog(fmt.Sprintf("Unknown Format \"%s\", Must Be \"markdown\" Or \"json\"", format))
+	}
+}
@@ -9,13 +9,18 @@ import (
 	"fmt"
 	"io/ioutil"
 	"log"
+	"os"
+	"os/signal"
 	"sync"
+	"syscall"
 	"time"
 
+	"github.com/fccn/gofetch-snmp/admin"
 	"github.com/fccn/gofetch-snmp/config"
 	"github.com/fccn/gofetch-snmp/data"
 	"github.com/fccn/gofetch-snmp/devices"
 	. "github.com/fccn/gofetch-snmp/log"
+	"github.com/fccn/gofetch-snmp/trap"
 	"github.com/matryer/runner"
 	"golang.org/x/sync/semaphore"
 	"gopkg.in/yaml.v2"
@@ -25,11 +30,18 @@ import (
 //----------------------------------------VARIABLES-----------------------------------------
 //------------------------------------------------------------------------------------------
 var wg, forever sync.WaitGroup
+
+//Hosts Polled Through The Admin API, Which Are Written Before Stopping
+var polls sync.WaitGroup
+var pollsMutex sync.Mutex
 var ss *semaphore.Weighted
 var ctx context.Context
 var tasks []*runner.Task
 var fetchedData []*data.Data
 
+//Closed When A Signal To Stop Is Received, And When The Grace Period To Stop Ends
+var stopping, expired = make(chan struct{}), make(chan struct{})
+
 //------------------------------------------------------------------------------------------
 //----------------------------------------FUNCTIONS-----------------------------------------
 //------------------------------------------------------------------------------------------
@@ -44,6 +56,17 @@ func waitTimeout(wg *sync.WaitGroup, timeout time.Duration) bool {
 		return false //OK
 	case <-time.After(timeout):
 		return true //Timed Out
+	case <-expired:
+		return true //Stopping
+	}
+}
+
+func isStopping() bool {
+	select {
+	case <-stopping:
+		return true
+	default:
+		return false
 	}
 }
 
@@ -56,6 +79,11 @@ func stopAllTasks() {
 func fetchData(host devices.Host) {
 	//Fetch Data From Device
 	if dev := devices.NewDevice(host); dev != nil {
+		//A Collection Of The Host That Outlived Its Timeout, Or Was Requested, Is Still Running
+		if !devices.StartCollecting(host.IP) {
+			With(Fields{"host": host.IP}).Info("Still Being Collected, Skipped")
+			return
+		}
 		dat := data.NewData()
 		fetchedData = append(fetchedData, &dat)
 
@@ -68,38 +96,117 @@ func fetchData(host devices.Host) {
 			//Multithreading Sync
 			defer wg.Done()
 			defer ss.Release(1)
+			defer devices.StopCollecting(host.IP)
 
-			dev.Fetch(&dat, &s)
+			if err := dev.Fetch(&dat, &s); err != nil {
+				With(Fields{"host": host.IP}).Error(err.Error())
+			}
 
 			return nil
 		}))
 	}
 }
 
-func writeData() {
-	//Test Connection To The InfluxDB
-	influx := data.InfluxTestConnection()
+//Collects A Single Host Outside The Collection Cycle, Writing Its Data Like The Others, Once Marked As Being Collected
+func pollHost(host devices.Host, timeout time.Duration) {
+	dev := devices.NewDevice(host)
+	dat := data.NewData()
 
-	//If Connection To InfluxDB Is Possible
-	if influx {
-		for i := 0; i < len(fetchedData); {
-			//Try To Write To InfluxDB And Remove Position If Writing Is Successful
-			if success := fetchedData[i].WriteInflux(); success {
-				fetchedData = fetchedData[i+1:]
-			}
+	var done sync.WaitGroup
+	done.Add(1)
+	ss.Acquire(ctx, 1)
+	task := runner.Go(func(s runner.S) error {
+		defer done.Done()
+		defer ss.Release(1)
+		defer devices.StopCollecting(host.IP)
+
+		if err := dev.Fetch(&dat, &s); err != nil {
+			With(Fields{"host": host.IP}).Error(err.Error())
 		}
+
+		return nil
+	})
+	if waitTimeout(&done, timeout) {
+		task.Stop()
 	}
-	//If Connection To InfluxDB Is Not Possible, Or Writing Was Not Successful
-	if !influx || len(fetchedData) > 0 {
-		//Try To Write Locally And Empty Array If Writing Is Successful
-		if success := data.LocalWrite(fetchedData); success {
-			DebugLog("Successfully Stored Fetched Data Locally")
-			fetchedData = []*data.Data{}
+	writeData([]*data.Data{&dat})
+}
+
+//Initializes The InfluxDB, Unless No Configuration File Is Given, The Archive, The Additional Outputs And Their Buffers
+func initOutputs(conf *config.Config, dbConfFile string) {
+	//Initialize The InfluxDB Connection, Unless The Data Is Only Written To The Additional Outputs
+	if dbConfFile != "" {
+		must(data.InfluxInit(dbConfFile))
+	}
+
+	//Initialize The Archive Where Data Is Stored When It Can Not Be Written
+	must(data.ArchiveInit(conf.Archive))
+
+	//Initialize The Additional Outputs
+	must(data.OtlpInit(conf.Outputs.Otlp))
+	must(data.KafkaInit(conf.Outputs.Kafka))
+	must(data.MqttInit(conf.Outputs.Mqtt))
+	must(data.GraphiteInit(conf.Outputs.Graphite))
+	must(data.StreamInit(conf.Outputs.Stream))
+
+	//Initialize A Buffer For Each Output, So A Slow Output Never Stalls The Collection
+	must(data.BufferInit(conf.Buffer))
+}
+
+//Exits If A Part Of The Application Could Not Be Initialized
+func must(err error) {
+	if err != nil {
+		FatalLog(err.Error())
+	}
+}
+
+func writeData(collected []*data.Data) {
+	//Hand Out Snapshots, Since A Collection That Timed Out May Still Be Writing To Its Data
+	fetched := make([]*data.Data, len(collected))
+	for i, dat := range collected {
+		fetched[i] = dat.Snapshot()
+		if fetched[i].Timestamp.IsZero() {
+			fetched[i].SetTimestamp(time.Now())
 		}
 	}
+
+	//Compare Each Device With Its Previous Collection, Adding The Events Of What Changed
+	for _, dat := range fetched {
+		if events := data.DetectEvents(dat); len(events) > 0 {
+			dat.AddEvents(events)
+			data.NotifyEvents(dat, events)
+		}
+	}
+
+	//Evaluate The Alert Rules, Posting The Alerts That Fired Or Resolved
+	data.EvaluateAlerts(fetched)
+
+	//Keep The Last Data Of Each Host For The Admin API
+	admin.Collected(fetched)
+
+	//Hand The Fetched Data To The Output Buffers, Which Write It In The Background
+	data.BufferWrite(fetched)
 }
 
 func main() {
+	//Run A Subcommand Instead Of Collecting, If One Was Given
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "features":
+			featuresCommand(os.Args[2:])
+			return
+		case "poll":
+			pollCommand(os.Args[2:])
+			return
+		case "fields":
+			fieldsCommand(os.Args[2:])
+			return
+		case "replay":
+			replayCommand(os.Args[2:])
+			return
+		}
+	}
+
 	//Get The Flags From The Execution Command
 	var confFile, hostsConfFile, dbConfFile string
 	flag.StringVar(&confFile, "c", confFile, "General - Configuration File")
@@ -108,10 +215,20 @@ func main() {
 	flag.Parse()
 
 	//Get General Configurations Struct
-	conf := config.GetConfigs(confFile)
+	conf, err := config.GetConfigs(confFile)
+	must(err)
+
+	//Set The Logging Level, Format And Target, And The Debug Flag On Util Module
+	must(Configure(conf.Logging.Level, conf.Logging.Format, conf.Logging.Target))
+	DebugHosts(conf.Logging.DebugHosts)
+	if conf.Debug {
+		Debug(true)
+	}
 
-	//Set Debug Flag On Util Module
-	Debug(conf.Debug)
+	//Keep The Standard Output For The Data, If It Is Streamed There
+	if stream := conf.Outputs.Stream; stream != nil && data.IsStdout(stream.Path) {
+		SetOutput(os.Stderr)
+	}
 
 	//Configuring Log Output
 	log.SetFlags(log.LstdFlags | log.Lshortfile)
@@ -129,8 +246,17 @@ func main() {
 		FatalLog(fmt.Sprintf("Could Not Decode Hosts Configuration File: %v", err))
 	}
 
-	//Initialize The InfluxDB Connection
-	data.InfluxInit(dbConfFile)
+	//Initialize The InfluxDB, The Additional Outputs And Their Buffers
+	initOutputs(conf, dbConfFile)
+
+	//Set Where The Events Are Posted, If Configured
+	data.EventsInit(conf.Events)
+
+	//Load The Alert Rules, If Configured
+	must(data.AlertsInit(conf.Alerts))
+
+	//Start Receiving Traps, If Configured, Which Are Written As Events
+	must(trap.Listen(conf.Traps, hosts))
 
 	//Set A Ticker That Defines The Running Interval
 	ticker := time.NewTicker(conf.Interval)
@@ -139,14 +265,35 @@ func main() {
 	ss = semaphore.NewWeighted(conf.MaxRoutines)
 	ctx = context.TODO()
 
+	//Serve The Admin API, If Configured
+	must(admin.Serve(conf.Admin, conf, hosts, stopping, func(host devices.Host) bool {
+		//Checked Along The Polls Being Added, So None Is Added Once They Are Waited For
+		pollsMutex.Lock()
+		defer pollsMutex.Unlock()
+		if isStopping() || !devices.StartCollecting(host.IP) {
+			return false
+		}
+		polls.Add(1)
+		go func() {
+			defer polls.Done()
+			pollHost(host, conf.Timeout)
+		}()
+		return true
+	}))
+
 	//Starting The Infinite Loop
 	forever.Add(1)
 	go func() {
 		for firstRun := true; ; firstRun = false {
 
-			//Wait 1 Minute, If It's Not The First Iteration
+			//Wait 1 Minute, If It's Not The First Iteration, Unless Stopping
 			if !firstRun {
-				<-ticker.C
+				select {
+				case <-ticker.C:
+				case <-stopping:
+					forever.Done()
+					return
+				}
 			}
 
 			//Collection Control Information
@@ -154,6 +301,13 @@ func main() {
 
 			//Retrieve Data For All Hosts
 			for _, host := range hosts.Hosts {
+				if isStopping() {
+					break
+				}
+				if admin.Paused(host.IP) {
+					With(Fields{"host": host.IP}).Debug("Skipping, Its Collection Is Paused")
+					continue
+				}
 				fetchData(host)
 			}
 
@@ -161,13 +315,48 @@ func main() {
 			if waitTimeout(&wg, conf.Timeout) {
 				stopAllTasks()
 			}
+			tasks = nil
 
-			//Write Fetched Data To InfluxDB or Disk
-			writeData()
+			//Queue Fetched Data And The Buffers' Statistics To Be Written To InfluxDB And The Additional Outputs
+			writeData(append(fetchedData, data.BufferStats()))
+			fetchedData = []*data.Data{}
 
 			//Collection Control Information
 			DebugLog("Collection Ended")
 		}
 	}()
+
+	//Stop On SIGINT Or SIGTERM, Letting The Current Collection End Within The Grace Period, A Second Signal Exits Right Away
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	sig := <-signals
+	start := time.Now()
+	Log(fmt.Sprintf("Received %s, Stopping Within %s", sig, conf.Grace))
+	close(stopping)
+	go func() {
+		<-signals
+		FatalLog("Received A Second Signal, Exiting Without Writing The Remaining Data")
+	}()
+	grace := time.AfterFunc(conf.Grace, func() { close(expired) })
 	forever.Wait()
+	pollsMutex.Lock()
+	pollsMutex.Unlock()
+	polls.Wait()
+	grace.Stop()
+
+	//Write What Is Left In The Rest Of The Grace Period, Storing On Disk What Is Not Written
+	remaining := conf.Grace - time.Since(start)
+	if remaining < 0 {
+		remaining = 0
+	}
+	trap.Close(remaining)
+	remaining = conf.Grace - time.Since(start)
+	if remaining < 0 {
+		remaining = 0
+	}
+	if !data.BufferClose(remaining) {
+		ErrorLog("Stopped, Some Data Was Lost")
+		os.Exit(1)
+	}
+	Log("Stopped")
 }
@@ -0,0 +1,206 @@
+package main
+
+//------------------------------------------------------------------------------------------
+//-----------------------------------------IMPORTS------------------------------------------
+//------------------------------------------------------------------------------------------
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/fccn/gofetch-snmp/data"
+	"github.com/fccn/gofetch-snmp/devices"
+	. "github.com/fccn/gofetch-snmp/log"
+	"github.com/matryer/runner"
+	"gopkg.in/yaml.v2"
+)
+
+//------------------------------------------------------------------------------------------
+//----------------------------------------FUNCTIONS-----------------------------------------
+//------------------------------------------------------------------------------------------
+//Collects A Single Host Once And Prints Its Data, Without Writing It To Any Output
+func pollCommand(args []string) {
+	var hostsConfFile, ip, featureList, format string
+	var debug bool
+	snmpPort := 161
+	host := devices.Host{Type: devices.AUTO}
+	host.SnmpConfig.Version, host.SnmpConfig.Community, host.SnmpConfig.Timeout = 2, "public", 5
+
+	flags := flag.NewFlagSet("poll", flag.ExitOnError)
+	flags.StringVar(&hostsConfFile, "h", hostsConfFile, "Hosts - Configuration File, With The Host's Credentials")
+	flags.StringVar(&ip, "host", ip, "IP Address Of The Host")
+	flags.StringVar(&featureList, "features", featureList, "Comma Separated Features To Collect, Defaults To The Configured Ones")
+	flags.StringVar(&format, "format", "table", "Output Format, \"table\" Or \"json\"")
+	flags.BoolVar(&debug, "debug", debug, "Enable Debug Logging")
+	flags.StringVar(&host.Type, "type", host.Type, "Device Type, If Not In The Hosts File")
+	flags.IntVar(&host.SnmpConfig.Version, "version", host.SnmpConfig.Version, "SNMP Version, If Not In The Hosts File")
+	flags.StringVar(&host.SnmpConfig.Community, "community", host.SnmpConfig.Community, "SNMP Community, If Not In The Hosts File")
+	flags.IntVar(&snmpPort, "port", snmpPort, "SNMP Port, If Not In The Hosts File")
+	flags.IntVar(&host.SnmpConfig.Timeout, "timeout", host.SnmpConfig.Timeout, "SNMP Timeout In Seconds, If Not In The Hosts File")
+	flags.IntVar(&host.SnmpConfig.Retries, "retries", host.SnmpConfig.Retries, "SNMP Retries, If Not In The Hosts File")
+	flags.IntVar(&host.SnmpConfig.Parallel, "parallel", host.SnmpConfig.Parallel, "Maximum Concurrent SNMP Requests, If Not In The Hosts File")
+	flags.StringVar(&host.SnmpConfig.Flags, "flags", host.SnmpConfig.Flags, "SNMPv3 Flags, Such As \"AuthPriv\"")
+	flags.StringVar(&host.SnmpConfig.Username, "username", host.SnmpConfig.Username, "SNMPv3 Username")
+	flags.StringVar(&host.SnmpConfig.AuthProt, "authprot", host.SnmpConfig.AuthProt, "SNMPv3 Authentication Protocol")
+	flags.StringVar(&host.SnmpConfig.AuthPass, "authpass", host.SnmpConfig.AuthPass, "SNMPv3 Authentication Password")
+	flags.StringVar(&host.SnmpConfig.PrivProt, "privprot", host.SnmpConfig.PrivProt, "SNMPv3 Privacy Protocol")
+	flags.StringVar(&host.SnmpConfig.PrivPass, "privpass", host.SnmpConfig.PrivPass, "SNMPv3 Privacy Password")
+	flags.Parse(args)
+
+	//Keep The Standard Output For The Result
+	SetOutput(os.Stderr)
+	Debug(debug)
+
+	if ip == "" {
+		FatalLog("Missing The Host, Use -host")
+	}
+	if format != "table" && format != "json" {
+		FatalLog(fmt.Sprintf("Unknown Format \"%s\", Must Be \"table\" Or \"json\"", format))
+	}
+
+	//Use The Host's Configurations From The Hosts File, If It Is There
+	host.IP, host.SnmpConfig.Port = ip, uint16(snmpPort)
+	configured := false
+	if hostsConfFile != "" {
+		var hosts devices.Hosts
+		h, err := ioutil.ReadFile(hostsConfFile)
+		if err == nil {
+			err = yaml.Unmarshal(h, &hosts)
+		}
+		if err != nil {
+			FatalLog(fmt.Sprintf("Could Not Decode Hosts Configuration File: %v", err))
+		}
+		for _, h := range hosts.Hosts {
+			if h.IP == ip {
+				host, configured = h, true
+				break
+			}
+		}
+		if !configured {
+			Log(fmt.Sprintf("Host %s Is Not In The Hosts Configuration File, Using The Flags", ip))
+		}
+	}
+
+	//Collect The Given Features, Or The Configured Ones, Or All The Type Supports If Neither
+	var names []string
+	if featureList != "" {
+		names = strings.Split(featureList, ",")
+		for i := range names {
+			names[i] = strings.TrimSpace(names[i])
+		}
+	}
+	if featureList != "" || !configured {
+		if err := host.Features.Only(names); err != nil {
+			FatalLog(err.Error())
+		}
+	}
+	host.Features.GofetchStatistics = true
+
+	//Collect The Host
+	dev := devices.NewDevice(host)
+	dat := data.NewData()
+	stop := runner.S(func() bool { return false })
+	dev.Fetch(&dat, &stop)
+
+	//Print The Data And The Duration Of Each Feature
+	if format == "json" {
+		content, err := json.MarshalIndent(dat, "", "  ")
+		if err != nil {
+			FatalLog("Could Not Encode Data: " + err.Error())
+		}
+		fmt.Fprintln(os.Stdout, string(content))
+	} else {
+		printTable(&dat)
+	}
+
+	//Fail If The Host Did Not Answer Or Any Request Failed
+	failed := false
+	if status, ok := devices.Status(host.IP); ok {
+		if status.Error != "" {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", status.Error)
+			failed = true
+		}
+		for _, err := range status.Errors {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			failed = true
+		}
+		for feature, f := range status.Features {
+			for _, err := range f.Errors {
+				fmt.Fprintf(os.Stderr, "Error: %s: %s\n", feature, err)
+				failed = true
+			}
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+//Prints The Device's Tags, A Line Per Index Of Each Metric, And The Duration Of Each Feature
+func printTable(d *data.Data) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	defer w.Flush()
+
+	fmt.Fprintf(w, "DEVICE\t%s\n", joinSorted(d.Tags))
+	fmt.Fprintf(w, "TIMESTAMP\t%s\n\n", d.Timestamp.Format("2006-01-02 15:04:05 MST"))
+
+	fmt.Fprintln(w, "METRIC\tINDEX\tTAGS\tFIELDS")
+	for _, metric := range sortedKeys(d.Metrics) {
+		if metric == devices.STATISTICS {
+			continue
+		}
+		m := d.Metrics[metric]
+		for _, index := range sortedKeys(m.Fields) {
+			fields := map[string]string{}
+			for k, v := range m.Fields[index] {
+				fields[k] = fmt.Sprint(v)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", metric, index, joinSorted(m.Tags[index]), joinSorted(fields))
+		}
+	}
+
+	if stats, ok := d.Metrics[devices.STATISTICS]; ok {
+		fmt.Fprintln(w, "\nFEATURE\tSECONDS")
+		for _, field := range sortedKeys(stats.Fields["0"]) {
+			feature := strings.TrimSuffix(strings.TrimPrefix(field, "statistics_"), "_seconds")
+			fmt.Fprintf(w, "%s\t%.3f\n", feature, stats.Fields["0"][field])
+		}
+	}
+}
+
+func joinSorted(m map[string]string) string {
+	var pairs []string
+	for _, k := range sortedKeys(m) {
+		pairs = append(pairs, k+"="+m[k])
+	}
+	return strings.Join(pairs, " ")
+}
+
+//Returns The Keys Of A Map With String Keys, Sorted
+func sortedKeys(m interface{}) (keys []string) {
+	switch v := m.(type) {
+	case map[string]string:
+		for k := range v {
+			keys = append(keys, k)
+		}
+	case map[string]interface{}:
+		for k := range v {
+			keys = append(keys, k)
+		}
+	case map[string]data.Metric:
+		for k := range v {
+			keys = append(keys, k)
+		}
+	case map[string]map[string]interface{}:
+		for k := range v {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return
+}
@@ -0,0 +1,62 @@
+package main
+
+//------------------------------------------------------------------------------------------
+//-----------------------------------------IMPORTS------------------------------------------
+//------------------------------------------------------------------------------------------
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/fccn/gofetch-snmp/config"
+	"github.com/fccn/gofetch-snmp/data"
+	. "github.com/fccn/gofetch-snmp/log"
+)
+
+//------------------------------------------------------------------------------------------
+//----------------------------------------FUNCTIONS-----------------------------------------
+//------------------------------------------------------------------------------------------
+//Writes The Data Stored In The Archive Between Two Times To The Configured Outputs
+func replayCommand(args []string) {
+	var confFile, dbConfFile, from, to string
+	timeout := 5 * time.Minute
+	flags := flag.NewFlagSet("replay", flag.ExitOnError)
+	flags.StringVar(&confFile, "c", confFile, "General - Configuration File")
+	flags.StringVar(&dbConfFile, "d", dbConfFile, "Database - Configuration File")
+	flags.StringVar(&from, "from", from, "Oldest Time Of The Data, In RFC 3339, Defaults To The Oldest In The Archive")
+	flags.StringVar(&to, "to", to, "Newest Time Of The Data, In RFC 3339, Defaults To Now")
+	flags.DurationVar(&timeout, "timeout", timeout, "Maximum Time To Write The Data, After Which What Is Left Goes To The Write-Ahead Log")
+	flags.Parse(args)
+
+	conf, err := config.GetConfigs(confFile)
+	must(err)
+	must(Configure(conf.Logging.Level, conf.Logging.Format, conf.Logging.Target))
+	if stream := conf.Outputs.Stream; stream != nil && data.IsStdout(stream.Path) {
+		SetOutput(os.Stderr)
+	}
+
+	start, end := time.Time{}, time.Now()
+	if from != "" {
+		if start, err = time.Parse(time.RFC3339, from); err != nil {
+			FatalLog(fmt.Sprintf("Invalid Time \"%s\": %v", from, err))
+		}
+	}
+	if to != "" {
+		if end, err = time.Parse(time.RFC3339, to); err != nil {
+			FatalLog(fmt.Sprintf("Invalid Time \"%s\": %v", to, err))
+		}
+	}
+
+	//The Outputs Are Written Through Their Buffers, As When Collecting
+	initOutputs(conf, dbConfFile)
+	files, err := data.ArchiveReplay(start, end, data.BufferWrite)
+	if err != nil {
+		ErrorLog(err.Error())
+	}
+	Log(fmt.Sprintf("Replayed %d Archive Files", files))
+	if !data.BufferClose(timeout) || err != nil {
+		ErrorLog("Replay Ended, Some Data Was Lost")
+		os.Exit(1)
+	}
+}
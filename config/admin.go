@@ -0,0 +1,12 @@
+package config
+
+//------------------------------------------------------------------------------------------
+//-----------------------------------------STRUCTS------------------------------------------
+//------------------------------------------------------------------------------------------
+//Struct That Receives The Admin API Configurations
+type Admin struct {
+	Address  string `yaml:"address"`  //HTTP Address Of The API, Such As ":8080"
+	Username string `yaml:"username"` //Basic Authentication, Optional
+	Password string `yaml:"password"`
+	Token    string `yaml:"token"` //Bearer Token, Optional
+}
@@ -0,0 +1,11 @@
+package config
+
+//------------------------------------------------------------------------------------------
+//-----------------------------------------STRUCTS------------------------------------------
+//------------------------------------------------------------------------------------------
+//Struct That Receives The Alerts Configurations
+type Alerts struct {
+	Rules        string   `yaml:"rules"`        //File With The Alert Rules, Reloaded When It Changes
+	Webhook      *Webhook `yaml:"webhook"`      //Where Alerts Are Posted When They Fire Or Resolve
+	Alertmanager *Webhook `yaml:"alertmanager"` //Address Of An Alertmanager, Alerts Are Posted To "/api/v2/alerts"
+}
@@ -0,0 +1,14 @@
+package config
+
+//------------------------------------------------------------------------------------------
+//-----------------------------------------STRUCTS------------------------------------------
+//------------------------------------------------------------------------------------------
+//Struct That Receives The Local Archive Configurations, Where Data Is Stored When It Can Not Be Written
+type Archive struct {
+	Directory string      `yaml:"directory"` //Directory Of The Archive, Defaults To The Working Directory
+	Gzip      bool        `yaml:"gzip"`      //Compresses Each File
+	Rotation  string      `yaml:"rotation"`  //"hourly" Or "daily" Groups The Files In A Directory Per Period
+	MaxAge    interface{} `yaml:"maxage"`    //Files Older Than This Are Removed
+	MaxSize   int         `yaml:"maxsize"`   //Total Size In Megabytes Above Which The Oldest Files Are Removed
+	MaxFiles  int         `yaml:"maxfiles"`  //Number Of Files Above Which The Oldest Files Are Removed
+}
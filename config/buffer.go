@@ -0,0 +1,13 @@
+package config
+
+//------------------------------------------------------------------------------------------
+//-----------------------------------------STRUCTS------------------------------------------
+//------------------------------------------------------------------------------------------
+//Struct That Receives The Output Buffers Configurations, Which Hold The Data Until Each Output Writes It
+type Buffer struct {
+	MaxPoints  int         `yaml:"maxpoints"`  //Points Kept In Memory Per Output, Above Which The Oldest Overflow To Disk
+	Directory  string      `yaml:"directory"`  //Directory Of The Write-Ahead Log, Defaults To "wal" In The Archive Directory
+	MaxSize    int         `yaml:"maxsize"`    //Size In Megabytes Of The Write-Ahead Log Per Output, Above Which The Oldest Is Dropped
+	MinBackoff interface{} `yaml:"minbackoff"` //Delay Before The First Retry Of A Failed Write
+	MaxBackoff interface{} `yaml:"maxbackoff"` //Maximum Delay Between Retries
+}
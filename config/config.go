@@ -13,17 +13,35 @@ type Config struct {
 	Debug       bool
 	Interval    time.Duration
 	Timeout     time.Duration
+	Grace       time.Duration
 	MaxRoutines int64
+	Outputs     Outputs
+	Archive     Archive
+	Buffer      Buffer
+	Traps       *Traps
+	Events      Events
+	Alerts      Alerts
+	Admin       *Admin
+	Logging     Logging
 }
 
 type config struct {
 	Debug       bool        `yaml:"debug"`
 	Interval    interface{} `yaml:"interval"`
 	Timeout     interface{} `yaml:"timeout"`
+	Grace       interface{} `yaml:"grace"`
 	MaxRoutines int64       `yaml:"maxroutines"`
+	Outputs     Outputs     `yaml:"outputs"`
+	Archive     Archive     `yaml:"archive"`
+	Buffer      Buffer      `yaml:"buffer"`
+	Traps       *Traps      `yaml:"traps"`
+	Events      Events      `yaml:"events"`
+	Alerts      Alerts      `yaml:"alerts"`
+	Admin       *Admin      `yaml:"admin"`
+	Logging     Logging     `yaml:"logging"`
 }
 
-func getDuration(i interface{}) (time.Duration, error) {
+func GetDuration(i interface{}) (time.Duration, error) {
 	switch i.(type) {
 	case int:
 		if i.(int) > 0 {
@@ -35,9 +53,9 @@ func getDuration(i interface{}) (time.Duration, error) {
 	return -1, fmt.Errorf("Error: %v Is Not A Valid Time Value", i)
 }
 
-func GetConfigs(configFile string) (c *Config) {
+func GetConfigs(configFile string) (c *Config, err error) {
 	//Initialize Struct With Default Values
-	c = &Config{}
+	c = &Config{Grace: 30 * time.Second}
 
 	//Use Auxiliary Struct To Receive Unprocessed Values
 	aux := config{}
@@ -45,22 +63,37 @@ func GetConfigs(configFile string) (c *Config) {
 	//Decode The Configurations File To The Config Struct
 	if conf, err := ioutil.ReadFile(configFile); err == nil {
 		if err := yaml.Unmarshal(conf, &aux); err != nil {
-			FatalLog(fmt.Sprintf("Could Not Decode Configuration File: %v", err))
+			return nil, fmt.Errorf("Could Not Decode Configuration File: %v", err)
 		}
-		if t, err := getDuration(aux.Interval); err == nil {
+		if t, err := GetDuration(aux.Interval); err == nil {
 			c.Interval = t
 		} else {
-			Log(err.Error())
+			WarnLog(err.Error())
 		}
-		if t, err := getDuration(aux.Timeout); err == nil {
+		if t, err := GetDuration(aux.Timeout); err == nil {
 			c.Timeout = t
 		} else {
-			Log(err.Error())
+			WarnLog(err.Error())
+		}
+		if aux.Grace != nil {
+			if t, err := GetDuration(aux.Grace); err == nil {
+				c.Grace = t
+			} else {
+				WarnLog(err.Error())
+			}
 		}
 		c.Debug = aux.Debug
 		c.MaxRoutines = aux.MaxRoutines
+		c.Outputs = aux.Outputs
+		c.Archive = aux.Archive
+		c.Buffer = aux.Buffer
+		c.Traps = aux.Traps
+		c.Events = aux.Events
+		c.Alerts = aux.Alerts
+		c.Admin = aux.Admin
+		c.Logging = aux.Logging
 	} else {
-		FatalLog(fmt.Sprintf("Could Not Decode Configuration File: %v", err))
+		return nil, fmt.Errorf("Could Not Decode Configuration File: %v", err)
 	}
 	return
 }
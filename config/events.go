@@ -0,0 +1,16 @@
+package config
+
+//------------------------------------------------------------------------------------------
+//-----------------------------------------STRUCTS------------------------------------------
+//------------------------------------------------------------------------------------------
+//Struct That Receives The Events Configurations
+type Events struct {
+	Webhook *Webhook `yaml:"webhook"` //Where The Events Are Posted, Besides Being Written To The Outputs
+}
+
+//Struct That Receives A Webhook's Configurations
+type Webhook struct {
+	Url     string            `yaml:"url"`
+	Headers map[string]string `yaml:"headers"` //Headers Sent With Each Request, Such As "Authorization"
+	Timeout interface{}       `yaml:"timeout"` //Maximum Duration Of Each Request
+}
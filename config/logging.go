@@ -0,0 +1,12 @@
+package config
+
+//------------------------------------------------------------------------------------------
+//-----------------------------------------STRUCTS------------------------------------------
+//------------------------------------------------------------------------------------------
+//Struct That Receives The Logging Configurations
+type Logging struct {
+	Level      string   `yaml:"level"`      //"debug", "info", "warn" Or "error"
+	Format     string   `yaml:"format"`     //"text" Or "json"
+	Target     string   `yaml:"target"`     //"stdout", "stderr", "syslog" Or "journald"
+	DebugHosts []string `yaml:"debughosts"` //IPs Of The Hosts Whose Debug Messages Are Printed, Even Without The Debug Flag
+}
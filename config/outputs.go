@@ -0,0 +1,76 @@
+package config
+
+//------------------------------------------------------------------------------------------
+//-----------------------------------------STRUCTS------------------------------------------
+//------------------------------------------------------------------------------------------
+//Struct That Receives The Optional Outputs' Configurations, Nil When An Output Is Not Used
+type Outputs struct {
+	Otlp     *Otlp     `yaml:"otlp"`
+	Kafka    *Kafka    `yaml:"kafka"`
+	Mqtt     *Mqtt     `yaml:"mqtt"`
+	Graphite *Graphite `yaml:"graphite"`
+	Stream   *Stream   `yaml:"stream"`
+}
+
+//Struct That Receives TLS Configurations, Shared By The Outputs
+type Tls struct {
+	Ca                 string `yaml:"ca"`   //CA Certificate File, To Verify The Server
+	Cert               string `yaml:"cert"` //Client Certificate File
+	Key                string `yaml:"key"`  //Client Key File
+	InsecureSkipVerify bool   `yaml:"insecureskipverify"`
+}
+
+//Struct That Receives The OpenTelemetry Collector Configurations
+type Otlp struct {
+	Protocol string            `yaml:"protocol"` //"grpc" Or "http"
+	Endpoint string            `yaml:"endpoint"` //Address Of The Collector, "host:port" For gRPC Or A URL For HTTP
+	Insecure bool              `yaml:"insecure"` //Disables TLS
+	Headers  map[string]string `yaml:"headers"`  //Headers Sent With Each Request
+	Timeout  interface{}       `yaml:"timeout"`  //Maximum Duration Of Each Request
+	Batch    int               `yaml:"batch"`    //Maximum Number Of Data Points Per Request
+	Retries  int               `yaml:"retries"`  //Maximum Number Of Retries Per Request
+	Tls      *Tls              `yaml:"tls"`
+}
+
+//Struct That Receives The Kafka Producer Configurations
+type Kafka struct {
+	Brokers  []string    `yaml:"brokers"` //Addresses Of The Brokers, "host:port"
+	Topic    string      `yaml:"topic"`   //Topic To Which The Messages Are Published
+	Format   string      `yaml:"format"`  //"json" Or "line" (Influx Line Protocol)
+	Points   bool        `yaml:"points"`  //Publishes Each Point Instead Of Each Device
+	Retries  int         `yaml:"retries"` //Maximum Number Of Retries Per Message
+	Timeout  interface{} `yaml:"timeout"` //Maximum Duration Of Each Request
+	Tls      *Tls        `yaml:"tls"`
+	Username string      `yaml:"username"` //SASL/PLAIN Credentials, Optional
+	Password string      `yaml:"password"`
+}
+
+//Struct That Receives The MQTT Publisher Configurations
+type Mqtt struct {
+	Broker   string      `yaml:"broker"`   //Address Of The Broker, e.g. "tcp://host:1883" Or "ssl://host:8883"
+	ClientID string      `yaml:"clientid"` //Client Identifier, Defaults To "gofetch-snmp"
+	Username string      `yaml:"username"`
+	Password string      `yaml:"password"`
+	Topic    string      `yaml:"topic"`    //Topic Template, With Tags, {metric} And {index} Between Braces
+	Qos      byte        `yaml:"qos"`      //Quality Of Service, 0, 1 Or 2
+	Retained bool        `yaml:"retained"` //Broker Keeps The Last Value Of Each Topic
+	Timeout  interface{} `yaml:"timeout"`  //Maximum Duration Of Each Publish
+	Buffer   int         `yaml:"buffer"`   //Maximum Number Of Messages Kept While Disconnected
+	Tls      *Tls        `yaml:"tls"`
+}
+
+//Struct That Receives The Graphite/Carbon Configurations
+type Graphite struct {
+	Address  string      `yaml:"address"`  //Address Of The Carbon Receiver, "host:port"
+	Protocol string      `yaml:"protocol"` //"plaintext", "pickle" Or "tagged"
+	Template string      `yaml:"template"` //Metric Path Template, With Tags, {metric}, {index} And {field} Between Braces
+	Timeout  interface{} `yaml:"timeout"`  //Maximum Duration Of The Connection And Of Each Write
+}
+
+//Struct That Receives The Line Protocol/NDJSON Stream Configurations
+type Stream struct {
+	Path    string `yaml:"path"`    //File To Which The Data Is Appended, Stdout If Empty Or "-"
+	Format  string `yaml:"format"`  //"line" (Influx Line Protocol) Or "json" (Newline-Delimited JSON)
+	MaxSize int    `yaml:"maxsize"` //Size In Megabytes At Which The File Is Rotated, 0 Never Rotates
+	Keep    int    `yaml:"keep"`    //Number Of Rotated Files Kept
+}
@@ -0,0 +1,11 @@
+package config
+
+//------------------------------------------------------------------------------------------
+//-----------------------------------------STRUCTS------------------------------------------
+//------------------------------------------------------------------------------------------
+//Struct That Receives The Trap Receiver Configurations
+type Traps struct {
+	Address   string `yaml:"address"`   //UDP Address On Which Traps And Informs Are Received, Defaults To ":162"
+	Unknown   bool   `yaml:"unknown"`   //Accepts v1/v2c Traps From Addresses That Are Not In The Hosts File
+	Community string `yaml:"community"` //Community The v1/v2c Traps Must Have, Unless The Host Sets Its Own, Any If Empty
+}
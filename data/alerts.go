@@ -0,0 +1,399 @@
+package data
+
+//------------------------------------------------------------------------------------------
+//-----------------------------------------IMPORTS------------------------------------------
+//------------------------------------------------------------------------------------------
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fccn/gofetch-snmp/config"
+	. "github.com/fccn/gofetch-snmp/log"
+	"gopkg.in/yaml.v2"
+)
+
+//------------------------------------------------------------------------------------------
+//----------------------------------------CONSTANTS-----------------------------------------
+//------------------------------------------------------------------------------------------
+//Complete Collections In A Row An Index Must Be Missing From Before Its Alert Resolves
+const alertMissingCycles = 3
+
+//------------------------------------------------------------------------------------------
+//-----------------------------------------STRUCTS------------------------------------------
+//------------------------------------------------------------------------------------------
+//Struct That Receives The Rules File
+type alertRules struct {
+	Rules []*alertRule `yaml:"rules"`
+}
+
+//Condition Over The Fields Of An Index, Such As "cpu_five_minutes_percent > 90 for 3 cycles"
+type alertRule struct {
+	Name     string            `yaml:"name"`
+	Expr     string            `yaml:"expr"`
+	Metric   string            `yaml:"metric"`   //Only Evaluated On This Metric, Optional
+	Severity string            `yaml:"severity"` //Defaults To WARNING
+	Match    map[string]string `yaml:"match"`    //Regular Expressions The Tags Must Match, Such As "device_name"
+	Message  string            `yaml:"message"`  //Template With The Tags, "{value}" And "{rule}" As Placeholders
+
+	any      [][]alertCondition //Fires If All The Conditions Of Any Group Hold
+	expr     string             //Expression Without The "for" Clause
+	cycles   int                //Collections In A Row The Conditions Must Hold Before Firing
+	matchers map[string]*regexp.Regexp
+}
+
+type alertCondition struct {
+	left, op, right string
+}
+
+//Alert Of A Rule On An Index Of A Device, As Posted To The Webhook
+type Alert struct {
+	Rule     string            `json:"rule"`
+	Status   string            `json:"status"` //"firing" Or "resolved"
+	Severity string            `json:"severity"`
+	Message  string            `json:"message"`
+	Value    float64           `json:"value"` //Value Of The First Field Of The Expression
+	Labels   map[string]string `json:"labels"`
+	StartsAt time.Time         `json:"starts_at"`
+	EndsAt   *time.Time        `json:"ends_at,omitempty"`
+}
+
+type alertState struct {
+	Alert
+	device  string
+	count   int //Collections In A Row The Conditions Held
+	missing int //Complete Collections In A Row The Index Was Missing From
+	seen    bool
+}
+
+//Body Of The Requests To The Alerts Webhook
+type alertsPayload struct {
+	Alerts []Alert `json:"alerts"`
+}
+
+//Alert As Expected By The Alertmanager API
+type alertmanagerAlert struct {
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations"`
+	StartsAt    time.Time         `json:"startsAt"`
+	EndsAt      *time.Time        `json:"endsAt,omitempty"`
+}
+
+//------------------------------------------------------------------------------------------
+//----------------------------------------VARIABLES-----------------------------------------
+//------------------------------------------------------------------------------------------
+var alerts config.Alerts
+var alertsWebhook, alertsAlertmanager *Webhook
+var alertsModTime time.Time
+var alertsMutex sync.Mutex
+
+var rules []*alertRule
+var alertStates = map[string]*alertState{}
+
+var alertConditionRegex = regexp.MustCompile(`^([\w.\-]+)\s*(>=|<=|==|!=|>|<)\s*([\w.\-]+)$`)
+var alertCyclesRegex = regexp.MustCompile(`^(.+?)\s+for\s+(\d+)\s+cycles?$`)
+
+//------------------------------------------------------------------------------------------
+//----------------------------------------FUNCTIONS-----------------------------------------
+//------------------------------------------------------------------------------------------
+//Loads The Alert Rules And Sets Where The Alerts Are Posted, If Configured
+func AlertsInit(conf config.Alerts) error {
+	if conf.Rules == "" {
+		return nil
+	}
+	alerts = conf
+	alertsWebhook = NewWebhook(conf.Webhook)
+	if conf.Alertmanager != nil && conf.Alertmanager.Url != "" {
+		am := *conf.Alertmanager
+		am.Url = strings.TrimSuffix(am.Url, "/") + "/api/v2/alerts"
+		alertsAlertmanager = NewWebhook(&am)
+	}
+	if err := loadAlertRules(); err != nil {
+		return fmt.Errorf("Could Not Load Alert Rules: %v", err)
+	}
+	return nil
+}
+
+//Reads The Rules File, Replacing The Current Rules Only If All Of Them Are Valid
+func loadAlertRules() error {
+	info, err := os.Stat(alerts.Rules)
+	if err != nil {
+		return err
+	}
+	content, err := ioutil.ReadFile(alerts.Rules)
+	if err != nil {
+		return err
+	}
+	var r alertRules
+	if err := yaml.Unmarshal(content, &r); err != nil {
+		return err
+	}
+	names := map[string]bool{}
+	for _, rule := range r.Rules {
+		if err := rule.compile(); err != nil {
+			return fmt.Errorf("Rule \"%s\": %v", rule.Name, err)
+		}
+		if names[rule.Name] {
+			return fmt.Errorf("Rule \"%s\" Is Defined More Than Once", rule.Name)
+		}
+		names[rule.Name] = true
+	}
+	rules, alertsModTime = r.Rules, info.ModTime()
+	Log(fmt.Sprintf("Loaded %d Alert Rules From %s", len(rules), alerts.Rules))
+	return nil
+}
+
+//Reloads The Rules If The File Changed Since It Was Loaded, Keeping The Current Rules If It Is Invalid
+func reloadAlertRules() {
+	info, err := os.Stat(alerts.Rules)
+	if err != nil {
+		ErrorLog("Could Not Check Alert Rules: " + err.Error())
+		return
+	}
+	if info.ModTime().Equal(alertsModTime) {
+		return
+	}
+	if err := loadAlertRules(); err != nil {
+		alertsModTime = info.ModTime()
+		ErrorLog(fmt.Sprintf("Could Not Reload Alert Rules, Keeping The Previous Ones: %v", err))
+	}
+}
+
+//Parses The Expression, "<condition> [and|or <condition>...] [for <n> cycles]", And The Matchers
+func (r *alertRule) compile() error {
+	if r.Name == "" {
+		return fmt.Errorf("Missing Name")
+	}
+	expr := strings.TrimSpace(r.Expr)
+	r.cycles = 1
+	if m := alertCyclesRegex.FindStringSubmatch(expr); m != nil {
+		expr = m[1]
+		r.cycles, _ = strconv.Atoi(m[2])
+		if r.cycles < 1 {
+			r.cycles = 1
+		}
+	}
+	r.any, r.expr = nil, expr
+	for _, group := range strings.Split(expr, " or ") {
+		var all []alertCondition
+		for _, cond := range strings.Split(group, " and ") {
+			m := alertConditionRegex.FindStringSubmatch(strings.TrimSpace(cond))
+			if m == nil {
+				return fmt.Errorf("Invalid Condition \"%s\"", strings.TrimSpace(cond))
+			}
+			all = append(all, alertCondition{m[1], m[2], m[3]})
+		}
+		r.any = append(r.any, all)
+	}
+
+	r.Severity = strings.ToLower(r.Severity)
+	if r.Severity == "" {
+		r.Severity = WARNING
+	}
+	r.matchers = map[string]*regexp.Regexp{}
+	for tag, expr := range r.Match {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return fmt.Errorf("Invalid Match Of \"%s\": %v", tag, err)
+		}
+		r.matchers[tag] = re
+	}
+	return nil
+}
+
+//Evaluates The Rule On The Fields Of An Index, Returns False In ok If Any Field Is Missing Or Not A Number
+func (r *alertRule) eval(fields map[string]interface{}) (holds bool, value float64, ok bool) {
+	operand := func(s string) (float64, bool) {
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f, true
+		}
+		return toFloat(fields[s])
+	}
+	first := true
+	for _, all := range r.any {
+		groupHolds := true
+		for _, c := range all {
+			left, lok := operand(c.left)
+			right, rok := operand(c.right)
+			if !lok || !rok {
+				return false, 0, false
+			}
+			if first {
+				value, first = left, false
+			}
+			switch c.op {
+			case ">":
+				groupHolds = groupHolds && left > right
+			case ">=":
+				groupHolds = groupHolds && left >= right
+			case "<":
+				groupHolds = groupHolds && left < right
+			case "<=":
+				groupHolds = groupHolds && left <= right
+			case "==":
+				groupHolds = groupHolds && left == right
+			case "!=":
+				groupHolds = groupHolds && left != right
+			}
+		}
+		holds = holds || groupHolds
+	}
+	return holds, value, true
+}
+
+func (r *alertRule) matches(tags map[string]string) bool {
+	for tag, re := range r.matchers {
+		if !re.MatchString(tags[tag]) {
+			return false
+		}
+	}
+	return true
+}
+
+//Evaluates The Rules On The Collected Data, Posting The Alerts That Fired Or Resolved
+func EvaluateAlerts(d []*Data) {
+	if alerts.Rules == "" {
+		return
+	}
+	alertsMutex.Lock()
+	defer alertsMutex.Unlock()
+	reloadAlertRules()
+	notifyAlerts(evaluateAlerts(d, time.Now()))
+}
+
+//Evaluates The Rules On The Collected Data, Returning The Alerts That Fired Or Resolved, Call With The Mutex Locked
+func evaluateAlerts(d []*Data, now time.Time) (changed []Alert) {
+	resolve := func(key string, s *alertState) {
+		if s.Status == "firing" {
+			s.Status, s.EndsAt = "resolved", &now
+			changed = append(changed, s.Alert)
+			Log(fmt.Sprintf("Alert %s Resolved: %s", s.Rule, s.Message))
+		}
+		delete(alertStates, key)
+	}
+
+	complete := map[string]bool{}
+	for _, dat := range d {
+		device := dat.GetTag("device_ip")
+		complete[device] = dat.Complete()
+		for _, rule := range rules {
+			r := rule
+			dat.ForEachIndex(func(metric, index string, tags map[string]string, fields map[string]interface{}) {
+				if (r.Metric != "" && r.Metric != metric) || !r.matches(tags) {
+					return
+				}
+				holds, value, ok := r.eval(fields)
+				if !ok {
+					return
+				}
+				key := strings.Join([]string{r.Name, device, metric, index}, "|")
+				s := alertStates[key]
+				if !holds {
+					if s != nil {
+						s.Value = value
+						resolve(key, s)
+					}
+					return
+				}
+				if s == nil {
+					s = &alertState{device: device}
+					alertStates[key] = s
+				}
+				s.seen, s.missing, s.Value = true, 0, value
+				if s.count++; s.count < r.cycles || s.Status == "firing" {
+					return
+				}
+				s.Rule, s.Status, s.Severity, s.StartsAt = r.Name, "firing", r.Severity, now
+				s.Labels = map[string]string{"alertname": r.Name, "severity": r.Severity, "metric": metric, "index": index}
+				for k, v := range tags {
+					s.Labels[k] = v
+				}
+				s.Message = r.message(s.Labels, value)
+				changed = append(changed, s.Alert)
+				Log(fmt.Sprintf("Alert %s Firing: %s", s.Rule, s.Message))
+			})
+		}
+	}
+
+	//Resolve What Was Not Seen On The Collected Devices For A Few Collections, Or Whose Rule Was Removed
+	names := map[string]bool{}
+	for _, r := range rules {
+		names[r.Name] = true
+	}
+	for key, s := range alertStates {
+		switch {
+		case !names[strings.SplitN(key, "|", 2)[0]]:
+			resolve(key, s)
+		//A Collection That Timed Out Or Failed May Have Missed An Index That Is Still There, So It Doesn't Count
+		case complete[s.device] && !s.seen:
+			if s.missing++; s.missing >= alertMissingCycles {
+				resolve(key, s)
+			}
+		}
+		s.seen = false
+	}
+	return
+}
+
+func (r *alertRule) message(labels map[string]string, value float64) string {
+	template := r.Message
+	if template == "" {
+		template = fmt.Sprintf("%s On {device_name} (Value {value})", r.expr)
+	}
+	return FillTemplate(template, func(key string) string {
+		switch key {
+		case "value":
+			return strconv.FormatFloat(value, 'f', -1, 64)
+		case "rule":
+			return r.Name
+		}
+		return labels[key]
+	})
+}
+
+//Posts The Alerts That Changed To The Webhook, And Every Firing Alert To The Alertmanager, Which Expects Them Repeatedly, Call With The Mutex Locked
+func notifyAlerts(changed []Alert) {
+	if alertsWebhook != nil && len(changed) > 0 {
+		go func() {
+			if err := alertsWebhook.Post(alertsPayload{changed}); err != nil {
+				ErrorLog(fmt.Sprintf("Could Not Post %d Alerts To Webhook: %s", len(changed), err.Error()))
+			}
+		}()
+	}
+
+	if alertsAlertmanager == nil {
+		return
+	}
+	var am []alertmanagerAlert
+	add := func(a Alert) {
+		am = append(am, alertmanagerAlert{
+			Labels:      a.Labels,
+			Annotations: map[string]string{"summary": a.Message, "value": strconv.FormatFloat(a.Value, 'f', -1, 64)},
+			StartsAt:    a.StartsAt,
+			EndsAt:      a.EndsAt,
+		})
+	}
+	for _, a := range changed {
+		if a.Status == "resolved" {
+			add(a)
+		}
+	}
+	for _, s := range alertStates {
+		if s.Status == "firing" {
+			add(s.Alert)
+		}
+	}
+	if len(am) == 0 {
+		return
+	}
+	go func() {
+		if err := alertsAlertmanager.Post(am); err != nil {
+			ErrorLog(fmt.Sprintf("Could Not Post %d Alerts To Alertmanager: %s", len(am), err.Error()))
+		}
+	}()
+}
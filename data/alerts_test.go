@@ -0,0 +1,154 @@
+package data
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestAlertRuleCompile(t *testing.T) {
+	tests := []struct {
+		expr   string
+		groups int
+		cycles int
+		valid  bool
+	}{
+		{"cpu_percent > 90", 1, 1, true},
+		{"cpu_percent > 90 for 3 cycles", 1, 3, true},
+		{"cpu_percent > 90 for 1 cycle", 1, 1, true},
+		{"cpu_percent > 90 for 0 cycles", 1, 1, true},
+		{"a > 1 and b <= 2 or c == 3", 2, 1, true},
+		{"a >= 1 and b != 2 and c < 3 for 2 cycles", 1, 2, true},
+		{"a >", 0, 0, false},
+		{"a => 1", 0, 0, false},
+		{"a > 1 and", 0, 0, false},
+		{"", 0, 0, false},
+	}
+	for _, test := range tests {
+		r := &alertRule{Name: "test", Expr: test.expr}
+		err := r.compile()
+		if (err == nil) != test.valid {
+			t.Errorf("%q - Expected Valid %v, Got %v", test.expr, test.valid, err)
+			continue
+		}
+		if test.valid && (len(r.any) != test.groups || r.cycles != test.cycles) {
+			t.Errorf("%q - Expected %d Groups And %d Cycles, Got %d And %d", test.expr, test.groups, test.cycles, len(r.any), r.cycles)
+		}
+	}
+
+	for _, r := range []*alertRule{{Expr: "a > 1"}, {Name: "test", Expr: "a > 1", Match: map[string]string{"device_name": "("}}} {
+		if err := r.compile(); err == nil {
+			t.Errorf("Expected An Error For %+v", r)
+		}
+	}
+	if r := (&alertRule{Name: "test", Expr: "a > 1"}); r.compile() == nil && r.Severity != WARNING {
+		t.Errorf("Expected The Default Severity, Got %q", r.Severity)
+	}
+}
+
+func TestAlertRuleEval(t *testing.T) {
+	fields := map[string]interface{}{"a": 5, "b": uint64(10), "c": float32(0.5), "up": true, "name": "x"}
+	tests := []struct {
+		expr  string
+		holds bool
+		value float64
+		ok    bool
+	}{
+		{"a > 4", true, 5, true},
+		{"a > 5", false, 5, true},
+		{"a >= 5", true, 5, true},
+		{"a < b", true, 5, true},
+		{"b <= 10", true, 10, true},
+		{"c == 0.5", true, 0.5, true},
+		{"a != 5", false, 5, true},
+		{"up == 1", true, 1, true},
+		{"a > 10 or b > 5", true, 5, true},
+		{"a > 1 and b > 50", false, 5, true},
+		{"a > 1 and b > 5 or c > 1", true, 5, true},
+		{"missing > 1", false, 0, false},
+		{"name > 1", false, 0, false},
+		{"a > 10 or missing > 1", false, 0, false},
+	}
+	for _, test := range tests {
+		r := &alertRule{Name: "test", Expr: test.expr}
+		if err := r.compile(); err != nil {
+			t.Fatal(err)
+		}
+		holds, value, ok := r.eval(fields)
+		if holds != test.holds || value != test.value || ok != test.ok {
+			t.Errorf("%q - Expected (%v, %v, %v), Got (%v, %v, %v)", test.expr, test.holds, test.value, test.ok, holds, value, ok)
+		}
+	}
+}
+
+func TestAlertTransitions(t *testing.T) {
+	r := &alertRule{Name: "high", Expr: "percent > 90 for 2 cycles", Metric: "test_info"}
+	if err := r.compile(); err != nil {
+		t.Fatal(err)
+	}
+	rules, alertStates = []*alertRule{r}, map[string]*alertState{}
+	defer func() { rules, alertStates = nil, map[string]*alertState{} }()
+
+	//Collects The Device With The Given Value On Index "1", Missing If Negative
+	collect := func(value float64, complete bool) []*Data {
+		d := NewData()
+		d.AddTag("device_ip", "alerts")
+		m := d.GetOrAddMetric("test_info")
+		m.AddGauge("0", "percent", 0)
+		if value >= 0 {
+			m.AddGauge("1", "percent", value)
+		}
+		if complete {
+			d.SetComplete()
+		}
+		return []*Data{&d}
+	}
+
+	steps := []struct {
+		value    float64
+		complete bool
+		expected string //Statuses Of The Alerts That Changed
+	}{
+		{95, true, "[]"},
+		{95, true, "[firing]"},
+		{95, true, "[]"},
+		{50, true, "[resolved]"},
+		{95, true, "[]"},
+		{95, true, "[firing]"},
+		//Missing From Collections That Did Not Complete, The Alert Stays
+		{-1, false, "[]"},
+		{-1, false, "[]"},
+		{-1, false, "[]"},
+		{-1, false, "[]"},
+		//Missing From Complete Collections, The Alert Resolves After A Few
+		{-1, true, "[]"},
+		{-1, true, "[]"},
+		{-1, true, "[resolved]"},
+		{95, true, "[]"},
+		{95, true, "[firing]"},
+		//Seen Again Before Resolving, The Count Of Missing Collections Starts Over
+		{-1, true, "[]"},
+		{-1, true, "[]"},
+		{95, false, "[]"},
+		{-1, true, "[]"},
+		{-1, true, "[]"},
+		{-1, true, "[resolved]"},
+	}
+	for i, step := range steps {
+		var statuses []string
+		for _, a := range evaluateAlerts(collect(step.value, step.complete), time.Now()) {
+			statuses = append(statuses, a.Status)
+		}
+		if got := fmt.Sprint(statuses); got != step.expected {
+			t.Errorf("Step %d - Expected %s, Got %s", i, step.expected, got)
+		}
+	}
+
+	//A Firing Alert Whose Rule Was Removed Resolves
+	evaluateAlerts(collect(95, true), time.Now())
+	evaluateAlerts(collect(95, true), time.Now())
+	rules = nil
+	if changed := evaluateAlerts(collect(95, true), time.Now()); len(changed) != 1 || changed[0].Status != "resolved" {
+		t.Errorf("Expected The Alert Of The Removed Rule To Resolve, Got %v", changed)
+	}
+}
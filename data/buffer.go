@@ -0,0 +1,412 @@
+package data
+
+//------------------------------------------------------------------------------------------
+//-----------------------------------------IMPORTS------------------------------------------
+//------------------------------------------------------------------------------------------
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fccn/gofetch-snmp/config"
+	. "github.com/fccn/gofetch-snmp/log"
+)
+
+//------------------------------------------------------------------------------------------
+//-----------------------------------------STRUCTS------------------------------------------
+//------------------------------------------------------------------------------------------
+//Queue Of The Data Waiting To Be Written To One Output, Written By Its Own Routine
+type sink struct {
+	name    string
+	write   func(d []*Data) (failed []*Data) //Returns The Data That Could Not Be Written
+	retry   bool                             //False If The Output Keeps The Data It Could Not Write
+	wal     string                           //Directory Of The Write-Ahead Log
+	wake    chan struct{}
+	backoff time.Duration
+	busy    bool //Writing A Batch Taken From The Queue
+	closed  bool //Not Writing Anymore, What Is Queued Is Stored On Disk
+
+	batch       []*Data //Batch Being Written, Stored On Disk If The Write Is Abandoned On Close
+	batchPoints int
+
+	mutex   sync.Mutex
+	queue   []*Data //Oldest First
+	points  int     //Number Of Points In The Queue
+	written int     //Statistics, Since The Start
+	dropped int
+	failed  int
+	spilled int
+}
+
+//------------------------------------------------------------------------------------------
+//----------------------------------------VARIABLES-----------------------------------------
+//------------------------------------------------------------------------------------------
+var sinks []*sink
+var buffer = config.Buffer{MaxPoints: 100000}
+var minBackoff, maxBackoff = time.Second, 5 * time.Minute
+
+//------------------------------------------------------------------------------------------
+//----------------------------------------FUNCTIONS-----------------------------------------
+//------------------------------------------------------------------------------------------
+//Creates A Buffer For The InfluxDB, If Enabled, And For Each Output, Call After Initializing Them
+func BufferInit(conf config.Buffer) error {
+	if conf.MaxPoints <= 0 {
+		conf.MaxPoints = 100000
+	}
+	if conf.MinBackoff != nil {
+		if t, err := config.GetDuration(conf.MinBackoff); err == nil {
+			minBackoff = t
+		} else {
+			WarnLog(err.Error())
+		}
+	}
+	if conf.MaxBackoff != nil {
+		if t, err := config.GetDuration(conf.MaxBackoff); err == nil {
+			maxBackoff = t
+		} else {
+			WarnLog(err.Error())
+		}
+	}
+	//What Overflows Is Always Written Again, So It Goes To The Write-Ahead Log, By Default Next To The Archive
+	if conf.Directory == "" {
+		conf.Directory = filepath.Join(archive.Directory, "wal")
+	}
+	buffer = conf
+
+	if InfluxEnabled() {
+		if err := addSink("InfluxDB", true, func(d []*Data) []*Data {
+			if !InfluxTestConnection() {
+				return d
+			}
+			return InfluxWrite(d)
+		}); err != nil {
+			return err
+		}
+	}
+	for _, output := range outputs {
+		o := output
+		retainer, ok := o.(Retainer)
+		if err := addSink(o.Name(), !ok || !retainer.Retains(), func(d []*Data) []*Data {
+			if partial, ok := o.(PartialWriter); ok {
+				return partial.WritePartial(d)
+			}
+			if o.Write(d) {
+				return nil
+			}
+			return d
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func addSink(name string, retry bool, write func(d []*Data) []*Data) error {
+	s := &sink{name: name, write: write, retry: retry, wake: make(chan struct{}, 1)}
+	s.wal = filepath.Join(buffer.Directory, strings.ToLower(name))
+	if err := os.MkdirAll(s.wal, 0755); err != nil {
+		return fmt.Errorf("Could Not Create Write-Ahead Log Directory: %v", err)
+	}
+	sinks = append(sinks, s)
+
+	//Starts Right Away, To Write What Was Left In The Write-Ahead Log
+	go s.run()
+	s.signal()
+	return nil
+}
+
+//Queues The Data On Every Buffer, Never Waiting For The Outputs
+func BufferWrite(d []*Data) {
+	for _, s := range sinks {
+		s.mutex.Lock()
+		s.queue = append(s.queue, d...)
+		s.points += countPoints(d)
+		s.overflow()
+		s.mutex.Unlock()
+		s.signal()
+	}
+}
+
+//Builds The Statistics Of The Buffers, With An Index Per Output
+func BufferStats() *Data {
+	d := NewData()
+	d.SetTimestamp(time.Now())
+	d.AddTag("device_name", "gofetch")
+	if host, err := os.Hostname(); err == nil {
+		d.AddTag("collector_host", host)
+	}
+	d.AddMetric("buffer_info")
+	m := d.GetMetric("buffer_info")
+	for _, s := range sinks {
+		files, size := s.walFiles()
+		s.mutex.Lock()
+		m.AddTag(s.name, "output", s.name)
+		m.AddField(s.name, "queue_devices", len(s.queue))
+		m.AddField(s.name, "queue_points", s.points)
+		m.AddField(s.name, "wal_files", len(files))
+		m.AddField(s.name, "wal_bytes", size)
+		m.AddCounter(s.name, "written_points", uint64(s.written))
+		m.AddCounter(s.name, "dropped_points", uint64(s.dropped))
+		m.AddCounter(s.name, "spilled_points", uint64(s.spilled))
+		m.AddCounter(s.name, "failed_writes", uint64(s.failed))
+		m.AddGauge(s.name, "backoff_seconds", s.backoff.Seconds())
+		s.mutex.Unlock()
+	}
+	return &d
+}
+
+//Writes What Is Queued Until The Deadline, Then Stores What Is Left On Disk And Closes The Outputs, Returns False If Any Data Was Lost
+func BufferClose(timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	results := make([]bool, len(sinks))
+	var wg sync.WaitGroup
+	for i, s := range sinks {
+		wg.Add(1)
+		go func(i int, s *sink) {
+			defer wg.Done()
+			results[i] = s.close(deadline)
+		}(i, s)
+	}
+	wg.Wait()
+
+	ok := true
+	for _, result := range results {
+		ok = ok && result
+	}
+	for _, o := range outputs {
+		if closer, isCloser := o.(Closer); isCloser {
+			if err := closer.Close(); err != nil {
+				ErrorLog(fmt.Sprintf("Could Not Close %s: %s", o.Name(), err.Error()))
+				ok = false
+			}
+		}
+	}
+	return ok
+}
+
+//Waits Until The Queue And The Batch Being Written Are Written Or The Deadline, Then Stores What Is Left On Disk
+func (s *sink) close(deadline time.Time) bool {
+	s.signal()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	dropped := s.dropped
+	for (s.busy || len(s.queue) > 0) && time.Now().Before(deadline) {
+		s.mutex.Unlock()
+		time.Sleep(100 * time.Millisecond)
+		s.mutex.Lock()
+	}
+	s.closed = true
+
+	//A Write Still Running Is Abandoned, Its Batch Being Stored Ahead Of The Queue, As It Is Older
+	queue, points := s.queue, s.points
+	if s.busy {
+		queue, points = append(append([]*Data{}, s.batch...), queue...), points+s.batchPoints
+	}
+	if len(queue) > 0 {
+		Log(fmt.Sprintf("%s Buffer Was Not Written In Time, Storing %d Points On Disk", s.name, points))
+		s.persist(queue, points)
+		s.queue, s.points = nil, 0
+	}
+	return s.dropped == dropped
+}
+
+func (s *sink) signal() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+//Writes Whatever Is Queued Each Time It Is Signaled
+func (s *sink) run() {
+	for range s.wake {
+		for s.flush() {
+		}
+	}
+}
+
+//Writes The Queue, Or The Oldest Write-Ahead Log File If The Queue Is Empty, Returns False If There Was Nothing To Write
+func (s *sink) flush() bool {
+	s.mutex.Lock()
+	if s.closed || (len(s.queue) == 0 && !s.replay()) {
+		s.mutex.Unlock()
+		return false
+	}
+	batch, points := s.queue, s.points
+	s.queue, s.points, s.busy = nil, 0, true
+	s.batch, s.batchPoints = batch, points
+	s.mutex.Unlock()
+
+	failed := s.write(batch)
+	failedPoints := countPoints(failed)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.busy, s.batch, s.batchPoints = false, nil, 0
+
+	//The Write Was Abandoned On Close, Its Batch Is Already On Disk
+	if s.closed {
+		return false
+	}
+	s.written += points - failedPoints
+	if len(failed) == 0 {
+		DebugLog(fmt.Sprintf("Successfully Wrote %d Points To %s", points, s.name))
+		s.backoff = 0
+		return true
+	}
+	s.failed++
+	if !s.retry {
+		return true
+	}
+
+	//Put The Failed Data Back In Front Of The Queue And Wait Before Retrying
+	s.queue = append(failed, s.queue...)
+	s.points += failedPoints
+	s.overflow()
+	if s.backoff = s.backoff * 2; s.backoff < minBackoff {
+		s.backoff = minBackoff
+	} else if s.backoff > maxBackoff {
+		s.backoff = maxBackoff
+	}
+	delay := s.backoff/2 + time.Duration(rand.Int63n(int64(s.backoff/2)+1))
+	DebugLog(fmt.Sprintf("Could Not Write %d Points To %s, Retrying In %s", failedPoints, s.name, delay.Round(time.Millisecond)))
+
+	s.mutex.Unlock()
+	time.Sleep(delay)
+	s.mutex.Lock()
+	return true
+}
+
+//Moves The Oldest Data To Disk While The Queue Holds More Points Than Allowed, Call With The Mutex Locked
+func (s *sink) overflow() {
+	var spill []*Data
+	var points int
+	for len(s.queue) > 0 && s.points-points > buffer.MaxPoints {
+		points += countPoints(s.queue[:1])
+		spill, s.queue = append(spill, s.queue[0]), s.queue[1:]
+	}
+	if len(spill) == 0 {
+		return
+	}
+	s.points -= points
+	s.persist(spill, points)
+}
+
+//Stores Data In The Write-Ahead Log, Returns False If It Was Dropped, Call With The Mutex Locked
+func (s *sink) persist(spill []*Data, points int) bool {
+	var b bytes.Buffer
+	err := gob.NewEncoder(&b).Encode(spill)
+	if err == nil {
+		err = atomicWrite(filepath.Join(s.wal, fmt.Sprintf("%d-%d.wal", time.Now().UnixNano(), points)), b.Bytes())
+	}
+	if err != nil {
+		ErrorLog(fmt.Sprintf("%s Buffer Could Not Store %d Points On Disk: %s", s.name, points, err.Error()))
+		s.dropped += points
+		return false
+	}
+	DebugLog(fmt.Sprintf("%s Buffer Stored %d Points On Disk", s.name, points))
+	s.spilled += points
+	s.trim()
+	return true
+}
+
+//Removes The Oldest Write-Ahead Log Files While It Is Larger Than Allowed, Call With The Mutex Locked
+func (s *sink) trim() {
+	if buffer.MaxSize <= 0 {
+		return
+	}
+	files, size := s.walFiles()
+	for _, f := range files {
+		if size <= int64(buffer.MaxSize)<<20 {
+			break
+		}
+		if err := os.Remove(filepath.Join(s.wal, f.Name())); err != nil {
+			ErrorLog(fmt.Sprintf("Could Not Remove Write-Ahead Log File: %s", err.Error()))
+			break
+		}
+		size -= f.Size()
+		s.dropped += walPoints(f.Name())
+		Log(fmt.Sprintf("%s Write-Ahead Log Is Full, Dropped %d Points", s.name, walPoints(f.Name())))
+	}
+}
+
+//Loads The Oldest Write-Ahead Log File Into The Queue, Removing It, Call With The Mutex Locked
+func (s *sink) replay() bool {
+	files, _ := s.walFiles()
+	for _, f := range files {
+		path := filepath.Join(s.wal, f.Name())
+		var d []*Data
+		content, err := ioutil.ReadFile(path)
+		if err == nil {
+			err = gob.NewDecoder(bytes.NewReader(content)).Decode(&d)
+		}
+		if err != nil {
+			ErrorLog(fmt.Sprintf("Could Not Read Write-Ahead Log File %s: %s", f.Name(), err.Error()))
+			s.dropped += walPoints(f.Name())
+		}
+		os.Remove(path)
+		if len(d) > 0 {
+			s.queue, s.points = d, countPoints(d)
+			DebugLog(fmt.Sprintf("Replaying %d Points From %s Write-Ahead Log", s.points, s.name))
+			return true
+		}
+	}
+	return false
+}
+
+//Lists The Write-Ahead Log Files, Oldest First, With Their Total Size
+func (s *sink) walFiles() (files []os.FileInfo, size int64) {
+	if s.wal == "" {
+		return
+	}
+	infos, err := ioutil.ReadDir(s.wal)
+	if err != nil {
+		ErrorLog(fmt.Sprintf("Could Not Read Write-Ahead Log Directory: %s", err.Error()))
+		return
+	}
+	for _, info := range infos {
+		if !info.IsDir() && strings.HasSuffix(info.Name(), ".wal") {
+			files = append(files, info)
+			size += info.Size()
+		}
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return walTime(files[i].Name()) < walTime(files[j].Name())
+	})
+	return
+}
+
+//Gets The Creation Time From A Write-Ahead Log File's Name, "<time>-<points>.wal"
+func walTime(name string) int64 {
+	t, _ := strconv.ParseInt(strings.SplitN(name, "-", 2)[0], 10, 64)
+	return t
+}
+
+//Gets The Number Of Points From A Write-Ahead Log File's Name, "<time>-<points>.wal"
+func walPoints(name string) int {
+	parts := strings.SplitN(strings.TrimSuffix(name, ".wal"), "-", 2)
+	if len(parts) < 2 {
+		return 0
+	}
+	points, _ := strconv.Atoi(parts[1])
+	return points
+}
+
+//Counts The Points Of The Data, One Per Index Of Each Metric
+func countPoints(d []*Data) (points int) {
+	for _, dat := range d {
+		for _, m := range dat.Metrics {
+			points += len(m.Fields)
+		}
+	}
+	return
+}
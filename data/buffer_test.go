@@ -0,0 +1,84 @@
+package data
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fccn/gofetch-snmp/config"
+)
+
+func TestBufferCloseAbandonsHungWrite(t *testing.T) {
+	hung := make(chan struct{})
+	defer close(hung)
+	s := &sink{name: "test", retry: true, wal: t.TempDir(), wake: make(chan struct{}, 1)}
+	s.write = func(d []*Data) []*Data {
+		<-hung
+		return nil
+	}
+	go s.run()
+
+	collect := func(index string) []*Data {
+		d := NewData()
+		d.GetOrAddMetric("test_info").AddGauge(index, "percent", 1)
+		return []*Data{&d}
+	}
+
+	//The First Batch Hangs In The Write, The Second Waits In The Queue
+	s.mutex.Lock()
+	s.queue, s.points = collect("1"), 1
+	s.mutex.Unlock()
+	s.signal()
+	for busy := false; !busy; {
+		time.Sleep(10 * time.Millisecond)
+		s.mutex.Lock()
+		busy = s.busy
+		s.mutex.Unlock()
+	}
+	s.mutex.Lock()
+	s.queue, s.points = collect("2"), 1
+	s.mutex.Unlock()
+
+	//The Close Keeps To Its Deadline, Storing Both The Batch And The Queue
+	start := time.Now()
+	if !s.close(start.Add(300 * time.Millisecond)) {
+		t.Errorf("Expected No Data To Be Lost")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected The Close To Keep To Its Deadline, Took %s", elapsed)
+	}
+	files, _ := s.walFiles()
+	if len(files) != 1 || walPoints(files[0].Name()) != 2 {
+		t.Fatalf("Expected One Write-Ahead Log File With 2 Points, Got %d Files", len(files))
+	}
+
+	//The Batch Is Stored Ahead Of The Queue
+	s.mutex.Lock()
+	s.replay()
+	queue := s.queue
+	s.mutex.Unlock()
+	if len(queue) != 2 || len(queue[0].GetMetric("test_info").GetFields("1")) == 0 {
+		t.Errorf("Expected The Abandoned Batch First, Got %d Data", len(queue))
+	}
+}
+
+func TestBufferDefaultWal(t *testing.T) {
+	saved := archive
+	defer func() { archive, buffer, sinks = saved, config.Buffer{MaxPoints: 100000}, nil }()
+	archive.Directory = t.TempDir()
+
+	//Without A Directory, The Overflow Still Goes To A Write-Ahead Log, Which Is Written Again
+	if err := BufferInit(config.Buffer{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := addSink("Test", true, func(d []*Data) []*Data { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if expected := filepath.Join(archive.Directory, "wal", "test"); sinks[0].wal != expected {
+		t.Errorf("Expected The Write-Ahead Log In %s, Got %q", expected, sinks[0].wal)
+	}
+	if _, err := os.Stat(sinks[0].wal); err != nil {
+		t.Errorf("Expected The Write-Ahead Log Directory: %v", err)
+	}
+}
@@ -0,0 +1,328 @@
+package data
+
+//------------------------------------------------------------------------------------------
+//-----------------------------------------IMPORTS------------------------------------------
+//------------------------------------------------------------------------------------------
+import (
+	"fmt"
+	"sync"
+)
+
+//------------------------------------------------------------------------------------------
+//-----------------------------------------STRUCTS------------------------------------------
+//------------------------------------------------------------------------------------------
+//Builds The Event Of A Field Whose Value Changed Between Collections, Returns Nil If There Is None
+type change func(index string, before, after float64, tags map[string]string) *Event
+
+//A Field Of A Metric Watched For Changes
+type detector struct {
+	Metric string
+	Field  string
+	Change change
+}
+
+//------------------------------------------------------------------------------------------
+//----------------------------------------VARIABLES-----------------------------------------
+//------------------------------------------------------------------------------------------
+//Data Of Each Device From The Previous Collection, By IP, Only Replaced By Complete Collections
+var previous = map[string]*Data{}
+var previousMutex sync.Mutex
+
+//Inventory Of Each Device From Its Last Collection That Had It, By IP, Since It Is Collected Seldom, Only Replaced By Complete Collections
+var previousInventory = map[string]Metric{}
+
+var detectors = []detector{
+	{"interface_info", "interface_oper_status", interfaceStatusChange},
+	{"bgp_info", "bgp_accepted_prefixes", bgpPrefixesChange},
+	{"sensor_info", "sensor_state", sensorStateChange},
+	{"uptime_info", "uptime_seconds", uptimeChange},
+	{"cell_info", "cell_modem_connected", cellModemChange},
+}
+
+//Names Of The IF-MIB ifOperStatus Values
+var operStatus = map[float64]string{
+	1: "up",
+	2: "down",
+	3: "testing",
+	4: "unknown",
+	5: "dormant",
+	6: "notPresent",
+	7: "lowerLayerDown",
+}
+
+//Names And Severities Of The CISCO-ENVMON-MIB Sensor States
+var sensorStates = map[float64]string{
+	1: "normal",
+	2: "warning",
+	3: "critical",
+	4: "shutdown",
+	5: "notPresent",
+	6: "notFunctioning",
+}
+
+var sensorSeverities = map[float64]string{
+	2: WARNING,
+	3: CRITICAL,
+	4: CRITICAL,
+	5: WARNING,
+	6: CRITICAL,
+}
+
+//------------------------------------------------------------------------------------------
+//----------------------------------------FUNCTIONS-----------------------------------------
+//------------------------------------------------------------------------------------------
+//Compares The Data With The Previous Collection Of The Same Device, Returning The Events Of What Changed
+func DetectEvents(d *Data) (events []Event) {
+	ip := d.GetTag("device_ip")
+	if ip == "" {
+		return
+	}
+	events = inventoryChanges(ip, d)
+
+	previousMutex.Lock()
+	prev := previous[ip]
+	previous[ip] = baseline(prev, d)
+	previousMutex.Unlock()
+	if prev == nil {
+		return
+	}
+
+	for _, det := range detectors {
+		before, after := prev.Metrics[det.Metric], d.Metrics[det.Metric]
+		for index, fields := range after.Fields {
+			a, ok := toFloat(fields[det.Field])
+			if !ok {
+				continue
+			}
+			b, ok := toFloat(before.Fields[index][det.Field])
+			if !ok || a == b {
+				continue
+			}
+			tags := map[string]string{}
+			for k, v := range after.Tags[index] {
+				tags[k] = v
+			}
+			if e := det.Change(index, b, a, tags); e != nil {
+				e.Source = "poll"
+				events = append(events, *e)
+			}
+		}
+	}
+	return
+}
+
+//Compares The Inventory With The Last One Of The Same Device, Returning The Parts Inserted, Removed Or Swapped
+//Only The Parts With A Serial Number Are Compared, The Others Being Ports, Sensors Or Containers
+func inventoryChanges(ip string, d *Data) (events []Event) {
+	after, ok := d.Metrics["inventory_info"]
+	if !ok || len(after.Fields) == 0 {
+		return
+	}
+	previousMutex.Lock()
+	before, ok := previousInventory[ip]
+	if ok && !d.Complete() {
+		previousInventory[ip] = mergeMetric(before, after)
+	} else {
+		previousInventory[ip] = after
+	}
+	previousMutex.Unlock()
+	if !ok {
+		return
+	}
+
+	for index := range after.Fields {
+		serial, _ := after.Fields[index]["inventory_serial"].(string)
+		serialBefore, _ := before.Fields[index]["inventory_serial"].(string)
+		if serial == "" || serial == serialBefore {
+			continue
+		}
+		tags := inventoryTags(after, index)
+		fields := map[string]interface{}{"inventory_serial": serial}
+		if model, ok := after.Fields[index]["inventory_model"].(string); ok {
+			fields["inventory_model"] = model
+		}
+		e := Event{
+			Type:     "inventory_inserted",
+			Severity: INFO,
+			Message:  fmt.Sprintf("Part %s Was Inserted, With Serial Number %s", tags["inventory_name"], serial),
+			Tags:     tags,
+			Fields:   fields,
+			Source:   "poll",
+		}
+		if serialBefore != "" {
+			e.Type, e.Severity = "inventory_swapped", WARNING
+			e.Message = fmt.Sprintf("Part %s Was Swapped, Serial Number Went From %s To %s", tags["inventory_name"], serialBefore, serial)
+			fields["inventory_serial_before"] = serialBefore
+			if model, ok := before.Fields[index]["inventory_model"].(string); ok {
+				fields["inventory_model_before"] = model
+			}
+		}
+		events = append(events, e)
+	}
+
+	//A Part Missing From A Collection That Did Not Complete May Just Not Have Been Walked
+	if !d.Complete() {
+		return
+	}
+	for index := range before.Fields {
+		serialBefore, _ := before.Fields[index]["inventory_serial"].(string)
+		if serial, _ := after.Fields[index]["inventory_serial"].(string); serialBefore == "" || serial != "" {
+			continue
+		}
+		tags := inventoryTags(before, index)
+		fields := map[string]interface{}{"inventory_serial_before": serialBefore}
+		if model, ok := before.Fields[index]["inventory_model"].(string); ok {
+			fields["inventory_model_before"] = model
+		}
+		events = append(events, Event{
+			Type:     "inventory_removed",
+			Severity: WARNING,
+			Message:  fmt.Sprintf("Part %s Was Removed, With Serial Number %s", tags["inventory_name"], serialBefore),
+			Tags:     tags,
+			Fields:   fields,
+			Source:   "poll",
+		})
+	}
+	return
+}
+
+//Returns What The Next Collection Is Compared With, The Data If It Completed
+//Otherwise Only The Indexes It Got Are Updated, So What It Missed Is Still Compared Next Time
+func baseline(prev, d *Data) *Data {
+	if prev == nil || d.Complete() {
+		return d
+	}
+	merged := &Data{Tags: d.Tags, Metrics: map[string]Metric{}}
+	for name, m := range prev.Metrics {
+		merged.Metrics[name] = m
+	}
+	for name, m := range d.Metrics {
+		merged.Metrics[name] = mergeMetric(merged.Metrics[name], m)
+	}
+	return merged
+}
+
+//Copies The Tags And Fields Of A Metric, Replacing The Indexes That Another One Has
+func mergeMetric(before, after Metric) Metric {
+	m := Metric{Tags: map[string]map[string]string{}, Fields: map[string]map[string]interface{}{}}
+	for _, from := range []Metric{before, after} {
+		for index, tags := range from.Tags {
+			m.Tags[index] = tags
+		}
+		for index, fields := range from.Fields {
+			m.Fields[index] = fields
+		}
+	}
+	return m
+}
+
+//Copies The Tags Of A Part Of The Inventory, Naming It By Its Index If It Has No Name
+func inventoryTags(m Metric, index string) map[string]string {
+	tags := map[string]string{"inventory_index": index}
+	for k, v := range m.Tags[index] {
+		tags[k] = v
+	}
+	if tags["inventory_name"] == "" {
+		tags["inventory_name"] = index
+	}
+	return tags
+}
+
+func interfaceStatusChange(index string, before, after float64, tags map[string]string) *Event {
+	tags["interface_index"] = index
+	iface := index
+	for _, tag := range []string{"interface_descr", "interface_name"} {
+		if tags[tag] != "" {
+			iface = tags[tag]
+		}
+	}
+	e := &Event{
+		Type:     "interface_status_change",
+		Severity: WARNING,
+		Message:  fmt.Sprintf("Interface %s Changed From %s To %s", iface, operStatus[before], operStatus[after]),
+		Tags:     tags,
+		Fields:   map[string]interface{}{"interface_oper_status": int(after), "interface_oper_status_before": int(before)},
+	}
+	if after == 1 {
+		e.Severity = INFO
+	}
+	return e
+}
+
+func bgpPrefixesChange(index string, before, after float64, tags map[string]string) *Event {
+	fields := map[string]interface{}{"bgp_accepted_prefixes": int(after), "bgp_accepted_prefixes_before": int(before)}
+	switch {
+	case after == 0:
+		return &Event{
+			Type:     "bgp_prefixes_lost",
+			Severity: CRITICAL,
+			Message:  fmt.Sprintf("BGP Neighbour %s Went From %d To 0 Accepted Prefixes", tags["bgp_neighbour"], int(before)),
+			Tags:     tags,
+			Fields:   fields,
+		}
+	case before == 0:
+		return &Event{
+			Type:     "bgp_prefixes_restored",
+			Severity: INFO,
+			Message:  fmt.Sprintf("BGP Neighbour %s Has %d Accepted Prefixes Again", tags["bgp_neighbour"], int(after)),
+			Tags:     tags,
+			Fields:   fields,
+		}
+	}
+	return nil
+}
+
+func sensorStateChange(index string, before, after float64, tags map[string]string) *Event {
+	if before != 1 && after != 1 {
+		return nil
+	}
+	e := &Event{
+		Type:     "sensor_state_change",
+		Severity: sensorSeverities[after],
+		Message:  fmt.Sprintf("Sensor %s Changed From %s To %s", tags["sensor_descr"], sensorStates[before], sensorStates[after]),
+		Tags:     tags,
+		Fields:   map[string]interface{}{"sensor_state": int(after), "sensor_state_before": int(before)},
+	}
+	if after == 1 {
+		e.Severity = INFO
+	} else if e.Severity == "" {
+		e.Severity = WARNING
+	}
+	return e
+}
+
+func uptimeChange(index string, before, after float64, tags map[string]string) *Event {
+	if after > before {
+		return nil
+	}
+	return &Event{
+		Type:     "device_restart",
+		Severity: WARNING,
+		Message:  fmt.Sprintf("Device Restarted, Uptime Went From %d To %d Seconds", int64(before), int64(after)),
+		Tags:     tags,
+		Fields:   map[string]interface{}{"uptime_seconds": int64(after), "uptime_seconds_before": int64(before)},
+	}
+}
+
+func cellModemChange(index string, before, after float64, tags map[string]string) *Event {
+	fields := map[string]interface{}{"cell_modem_connected": int(after)}
+	switch {
+	case before == 1:
+		return &Event{
+			Type:     "cell_modem_disconnected",
+			Severity: WARNING,
+			Message:  "Cell Modem Disconnected",
+			Tags:     tags,
+			Fields:   fields,
+		}
+	case after == 1:
+		return &Event{
+			Type:     "cell_modem_connected",
+			Severity: INFO,
+			Message:  "Cell Modem Connected",
+			Tags:     tags,
+			Fields:   fields,
+		}
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package data
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+)
+
+func TestDetectors(t *testing.T) {
+	tests := []struct {
+		metric   string
+		field    string
+		tags     map[string]string
+		before   float64
+		after    float64
+		expected string //Type, Severity And Message Of The Event, Empty If None
+	}{
+		{"interface_info", "interface_oper_status", map[string]string{"interface_name": "Gi0/1"}, 1, 2, "interface_status_change warning Interface Gi0/1 Changed From up To down"},
+		{"interface_info", "interface_oper_status", nil, 7, 1, "interface_status_change info Interface 1 Changed From lowerLayerDown To up"},
+		//BGP Prefixes Only Matter When Dropping To Zero Or Coming Back
+		{"bgp_info", "bgp_accepted_prefixes", map[string]string{"bgp_neighbour": "10.0.0.1"}, 120, 0, "bgp_prefixes_lost critical BGP Neighbour 10.0.0.1 Went From 120 To 0 Accepted Prefixes"},
+		{"bgp_info", "bgp_accepted_prefixes", map[string]string{"bgp_neighbour": "10.0.0.1"}, 0, 118, "bgp_prefixes_restored info BGP Neighbour 10.0.0.1 Has 118 Accepted Prefixes Again"},
+		{"bgp_info", "bgp_accepted_prefixes", map[string]string{"bgp_neighbour": "10.0.0.1"}, 120, 118, ""},
+		//Sensor States Only Matter When Leaving Or Returning To Normal
+		{"sensor_info", "sensor_state", map[string]string{"sensor_descr": "Inlet"}, 1, 3, "sensor_state_change critical Sensor Inlet Changed From normal To critical"},
+		{"sensor_info", "sensor_state", map[string]string{"sensor_descr": "Inlet"}, 1, 2, "sensor_state_change warning Sensor Inlet Changed From normal To warning"},
+		{"sensor_info", "sensor_state", map[string]string{"sensor_descr": "Inlet"}, 2, 1, "sensor_state_change info Sensor Inlet Changed From warning To normal"},
+		{"sensor_info", "sensor_state", map[string]string{"sensor_descr": "Inlet"}, 2, 3, ""},
+		//Uptime Going Back Means A Reboot
+		{"uptime_info", "uptime_seconds", nil, 86400, 120, "device_restart warning Device Restarted, Uptime Went From 86400 To 120 Seconds"},
+		{"uptime_info", "uptime_seconds", nil, 86400, 86460, ""},
+		{"cell_info", "cell_modem_connected", nil, 1, 0, "cell_modem_disconnected warning Cell Modem Disconnected"},
+		{"cell_info", "cell_modem_connected", nil, 0, 1, "cell_modem_connected info Cell Modem Connected"},
+	}
+	for i, test := range tests {
+		ip := fmt.Sprintf("detector-%d", i)
+		var events []Event
+		for _, value := range []float64{test.before, test.after} {
+			d := NewData()
+			d.AddTag("device_ip", ip)
+			m := d.GetOrAddMetric(test.metric)
+			for k, v := range test.tags {
+				m.AddTag("1", k, v)
+			}
+			m.AddGauge("1", test.field, value)
+			d.SetComplete()
+			events = DetectEvents(&d)
+		}
+
+		got := ""
+		if len(events) > 1 {
+			t.Errorf("Case %d - Expected At Most 1 Event, Got %v", i, events)
+		} else if len(events) == 1 {
+			e := events[0]
+			got = e.Type + " " + e.Severity + " " + e.Message
+			if e.Source != "poll" {
+				t.Errorf("Case %d - Expected The Poll As Source, Got %q", i, e.Source)
+			}
+		}
+		if got != test.expected {
+			t.Errorf("Case %d - Expected %q, Got %q", i, test.expected, got)
+		}
+	}
+}
+
+func TestInventoryChanges(t *testing.T) {
+	collect := func(serials map[string]string, complete bool) *Data {
+		d := NewData()
+		d.AddTag("device_ip", "inventory")
+		if serials != nil {
+			m := d.GetOrAddMetric("inventory_info")
+			for index, serial := range serials {
+				m.AddTag(index, "inventory_name", "part "+index)
+				m.AddString(index, "inventory_serial", serial)
+			}
+		}
+		if complete {
+			d.SetComplete()
+		}
+		return &d
+	}
+
+	//The Collections Without Inventory Are Not Compared, The Inventory Being Collected Seldom
+	//The Parts Missing From A Collection That Did Not Complete Are Not Removed, Nor Forgotten
+	steps := []struct {
+		serials  map[string]string
+		complete bool
+		expected map[string]string
+	}{
+		{map[string]string{"1": "A", "2": "B"}, true, map[string]string{}},
+		{nil, true, map[string]string{}},
+		{map[string]string{"1": "A"}, false, map[string]string{}},
+		{map[string]string{"1": "C", "3": "D", "4": ""}, true, map[string]string{"1": "inventory_swapped", "2": "inventory_removed", "3": "inventory_inserted"}},
+	}
+	for i, step := range steps {
+		types := map[string]string{}
+		for _, e := range DetectEvents(collect(step.serials, step.complete)) {
+			types[e.Tags["inventory_index"]] = e.Type
+		}
+		if fmt.Sprint(types) != fmt.Sprint(step.expected) {
+			t.Errorf("Collection %d - Expected %v, Got %v", i, step.expected, types)
+		}
+	}
+}
+
+func TestChangesBaseline(t *testing.T) {
+	collect := func(statuses map[string]float64, complete bool) *Data {
+		d := NewData()
+		d.AddTag("device_ip", "baseline")
+		m := d.GetOrAddMetric("interface_info")
+		for index, status := range statuses {
+			m.AddGauge(index, "interface_oper_status", status)
+		}
+		if complete {
+			d.SetComplete()
+		}
+		return &d
+	}
+
+	//A Collection That Did Not Complete Only Updates The Interfaces It Got, So None Is Reported Twice Or Missed
+	steps := []struct {
+		statuses map[string]float64
+		complete bool
+		expected string //Indexes Of The Interfaces That Changed
+	}{
+		{map[string]float64{"1": 1, "2": 1}, true, "[]"},
+		{map[string]float64{"1": 2}, false, "[1]"},
+		{map[string]float64{"1": 2, "2": 2}, true, "[2]"},
+		{map[string]float64{}, false, "[]"},
+		{map[string]float64{"1": 1, "2": 1}, true, "[1 2]"},
+	}
+	for i, step := range steps {
+		var indexes []string
+		for _, e := range DetectEvents(collect(step.statuses, step.complete)) {
+			indexes = append(indexes, e.Tags["interface_index"])
+		}
+		sort.Strings(indexes)
+		if got := fmt.Sprint(indexes); got != step.expected {
+			t.Errorf("Collection %d - Expected %s, Got %s", i, step.expected, got)
+		}
+	}
+}
@@ -1,55 +1,104 @@
 package data
 
 import (
+	"sync"
 	"time"
 
 	. "github.com/fccn/gofetch-snmp/log"
-	"github.com/fccn/gofetch-snmp/snmp"
+	client "github.com/influxdata/influxdb1-client/v2"
 	g "github.com/soniah/gosnmp"
 )
 
+/*
+ * Data Of A Device, Built By Its Collection And Then Handed To The Outputs
+ *
+ * While It Is Built, The Methods Can Be Called Concurrently, Since The Features May Run Concurrently
+ * A Collection That Timed Out May Still Be Writing, So The Outputs Are Handed A Snapshot, Which Is Only Read
+ */
 type Data struct {
 	Timestamp time.Time         `json:"timestamp"`
 	Tags      map[string]string `json:"tags"`
 	Metrics   map[string]Metric `json:"metrics"`
+
+	mutex     *sync.RWMutex //Guards The Maps, Missing If The Data Was Decoded, Since It Is Only Read Then
+	functions *sync.Mutex   //Runs The Functions Of AddFromEntries One At A Time, As They May Read What They Write
+	complete  bool          //The Collection Ended Without Errors, So What Is Missing Is Gone From The Device
 }
 
 func NewData() (d Data) {
 	d = Data{}
 	d.Tags = map[string]string{}
 	d.Metrics = map[string]Metric{}
+	d.mutex, d.functions = &sync.RWMutex{}, &sync.Mutex{}
 	return
 }
 
 func (d *Data) AddTag(name, value string) {
+	defer lock(d.mutex)()
 	d.Tags[name] = value
 }
 
 func (d *Data) GetTag(name string) string {
+	defer rlock(d.mutex)()
 	return d.Tags[name]
 }
 
+/*
+ * Adds An Empty Metric, Replacing The Metric With The Same Name
+ */
 func (d *Data) AddMetric(metric string) {
+	defer lock(d.mutex)()
 	d.Metrics[metric] = newMetric()
 }
 
+/*
+ * Gets A Metric, Which Is Empty If It Wasn't Added, Its Methods Change The Metric In The Data
+ */
 func (d *Data) GetMetric(metric string) *Metric {
+	defer rlock(d.mutex)()
 	m := d.Metrics[metric]
 	return &m
 }
 
-func (d *Data) AddFromEntries(snmpConf g.GoSNMP, bulk bool, metric string, entries Entries, function Function) {
-	//Initialize The Metric If It Wasn't Initialized Already
-	m := d.GetMetric(metric)
-	if m.IsEmpty() {
-		d.AddMetric(metric)
-		m = d.GetMetric(metric)
+/*
+ * Gets A Metric, Adding It If It Wasn't Added Already
+ */
+func (d *Data) GetOrAddMetric(metric string) *Metric {
+	defer lock(d.mutex)()
+	m, ok := d.Metrics[metric]
+	if !ok {
+		m = newMetric()
+		d.Metrics[metric] = m
 	}
+	return &m
+}
 
-	//Go Through Each Entry, Make SNMP Request, Process It
+/*
+ * Walks The Entries Concurrently, As Many At Once As The Walk Function Allows, And Calls The Function For Each Result
+ */
+func (d *Data) AddFromEntries(walk Walk, metric string, entries Entries, function Function) {
+	results := make([][]g.SnmpPDU, len(entries))
+	var wg sync.WaitGroup
+	for i := range entries {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = walk(entries[i].Oid)
+		}(i)
+	}
+	wg.Wait()
+
+	//Initialize The Metric If It Wasn't Initialized Already
+	m := d.GetOrAddMetric(metric)
+
+	//Process The Results In The Order Of The Entries, One Function At A Time
+	if d.functions != nil {
+		d.functions.Lock()
+		defer d.functions.Unlock()
+	}
 	for i := range entries {
 		entry := entries[i]
-		pdus := snmp.WalkAll(snmpConf, bulk, entry.Oid)
+		pdus := results[i]
 		for j := range pdus {
 			pdu := pdus[j]
 			function(*m, entry, pdu)
@@ -61,65 +110,282 @@ func (d *Data) AddFromEntries(snmpConf g.GoSNMP, bulk bool, metric string, entri
  * Call This When All Features Are Collected, To Associate Them To A Timestamp
  */
 func (d *Data) SetTimestamp(Timestamp time.Time) {
+	defer lock(d.mutex)()
 	d.Timestamp = Timestamp
 }
 
 /*
- * Call This To Write The Data To The InfluxDB
+ * Call This When The Collection Ended Without Errors, So What Is Missing From The Data Can Be Told Gone
  */
-func (d *Data) WriteInflux() bool {
-	bp := InfluxCreateBatch()
+func (d *Data) SetComplete() {
+	defer lock(d.mutex)()
+	d.complete = true
+}
+
+/*
+ * Tells If The Collection Ended Without Errors, Which A Collection That Timed Out Or Failed Did Not
+ */
+func (d *Data) Complete() bool {
+	defer rlock(d.mutex)()
+	return d.complete
+}
+
+/*
+ * Copies The Data, Taking The Locks, So The Copy Can Be Handed Out While The Collection Still Writes To The Data
+ */
+func (d *Data) Snapshot() *Data {
+	defer rlock(d.mutex)()
+	s := NewData()
+	s.Timestamp, s.complete = d.Timestamp, d.complete
+	for k, v := range d.Tags {
+		s.Tags[k] = v
+	}
 	for name := range d.Metrics {
 		m := d.Metrics[name]
-		for index := range m.Fields {
-			tags := m.Tags[index]
-			fields := m.Fields[index]
+		s.Metrics[name] = m.snapshot()
+	}
+	return &s
+}
 
-			//Tags Can Be Nil, Initialize In That Case
-			if tags == nil {
-				tags = map[string]string{}
+/*
+ * Call This To Write The Data To The InfluxDB
+ */
+func (d *Data) WriteInflux() bool {
+	return len(InfluxWrite([]*Data{d})) == 0
+}
+
+/*
+ * Calls The Function For Each Index Of Each Metric, With The Data Main Tags Added To The Index's Tags
+ */
+func (d *Data) ForEachIndex(function func(metric, index string, tags map[string]string, fields map[string]interface{})) {
+	//The Function Is Called On A Copy, Without The Locks, As It May Call The Methods Of The Data
+	s := d.Snapshot()
+	for name := range s.Metrics {
+		m := s.Metrics[name]
+		for index := range m.Fields {
+			tags := map[string]string{}
+			for k, v := range m.Tags[index] {
+				tags[k] = v
 			}
-			//Add Data Main Tags To The Metric Defined Tags
-			for k, v := range d.Tags {
+			for k, v := range s.Tags {
 				tags[k] = v
 			}
-			InfluxAddPoint(bp, name, &tags, &fields, d.Timestamp)
+			function(name, index, tags, m.Fields[index])
 		}
 	}
-	return InfluxWriteBatch(bp)
 }
 
+/*
+ * Flattens The Data Into Points
+ */
+func (d *Data) InfluxPoints() (points []*client.Point) {
+	d.ForEachIndex(func(metric, index string, tags map[string]string, fields map[string]interface{}) {
+		if pt := influxNewPoint(metric, tags, fields, d.Timestamp); pt != nil {
+			points = append(points, pt)
+		}
+	})
+	return
+}
+
+/*
+ * Point In Its JSON Representation, Used By The Outputs That Write Points As JSON
+ */
+type JsonPoint struct {
+	Measurement string                 `json:"measurement"`
+	Tags        map[string]string      `json:"tags"`
+	Fields      map[string]interface{} `json:"fields"`
+	Timestamp   time.Time              `json:"timestamp"`
+}
+
+func NewJsonPoint(pt *client.Point) JsonPoint {
+	fields, _ := pt.Fields()
+	return JsonPoint{
+		Measurement: pt.Name(),
+		Tags:        pt.Tags(),
+		Fields:      fields,
+		Timestamp:   pt.Time(),
+	}
+}
+
+/*
+ * Tags And Fields Of A Metric, By Index
+ *
+ * A Metric Is A Handle, Its Copies Share The Maps And The Mutex, So A Function Given A Metric Changes The Data
+ * Its Methods Can Be Called Concurrently, While Its Maps Can Only Be Read Directly Once The Data Is Built
+ */
 type Metric struct {
 	Tags   map[string]map[string]string      `json:"tags"`
 	Fields map[string]map[string]interface{} `json:"fields"`
+
+	kinds map[string]Kind //Kinds Of The Fields Added With A Kind, By Name
+	mutex *sync.RWMutex   //Guards The Maps, Missing If The Data Was Decoded, Since It Is Only Read Then
 }
 
+//Kind Of The Value Of A Field
+type Kind int
+
+const (
+	Gauge   Kind = iota //Number That Goes Up And Down, Stored As A float64 When Added With AddGauge
+	Counter             //Number That Only Goes Up, Until It Wraps Or Resets, Stored As An int64
+	Bool
+	String
+)
+
 //"Constructor"
 func newMetric() (m Metric) {
 	m = Metric{}
 	m.Tags = map[string]map[string]string{}
 	m.Fields = map[string]map[string]interface{}{}
+	m.kinds = map[string]Kind{}
+	m.mutex = &sync.RWMutex{}
 	return
 }
 
+//Copies The Metric, Taking Its Lock
+func (m *Metric) snapshot() Metric {
+	defer rlock(m.mutex)()
+	s := newMetric()
+	for index, tags := range m.Tags {
+		s.Tags[index] = map[string]string{}
+		for k, v := range tags {
+			s.Tags[index][k] = v
+		}
+	}
+	for index, fields := range m.Fields {
+		s.Fields[index] = map[string]interface{}{}
+		for k, v := range fields {
+			s.Fields[index][k] = v
+		}
+	}
+	for k, v := range m.kinds {
+		s.kinds[k] = v
+	}
+	return s
+}
+
 //Add Tag For Given Index
 func (m *Metric) AddTag(index string, name, value string) {
+	defer lock(m.mutex)()
 	m.initTag(index)
 	m.Tags[index][name] = value
 }
 
-//Add Field For Given Index
+//Add Field For Given Index, Its Kind Being Told By Its Value
 func (m *Metric) AddField(index string, name string, value interface{}) {
-	m.initField(index)
-
 	switch value.(type) {
 	case uint64:
 		value = int64(value.(uint64))
 	}
 
+	defer lock(m.mutex)()
+	m.initField(index)
 	m.Fields[index][name] = value
 }
 
+//Add Counter Field For Given Index
+func (m *Metric) AddCounter(index string, name string, value uint64) {
+	m.addKind(index, name, int64(value), Counter)
+}
+
+//Add Gauge Field For Given Index
+func (m *Metric) AddGauge(index string, name string, value float64) {
+	m.addKind(index, name, value, Gauge)
+}
+
+//Add Boolean Field For Given Index
+func (m *Metric) AddBool(index string, name string, value bool) {
+	m.addKind(index, name, value, Bool)
+}
+
+//Add String Field For Given Index
+func (m *Metric) AddString(index string, name string, value string) {
+	m.addKind(index, name, value, String)
+}
+
+func (m *Metric) addKind(index string, name string, value interface{}, kind Kind) {
+	defer lock(m.mutex)()
+	m.initField(index)
+	m.Fields[index][name] = value
+	if m.kinds == nil {
+		m.kinds = map[string]Kind{}
+	}
+	m.kinds[name] = kind
+}
+
+//Sets The Kind Of A Field, Such As Counter For A Field Added With AddField From A Counter PDU
+func (m *Metric) SetKind(name string, kind Kind) {
+	defer lock(m.mutex)()
+	if m.kinds == nil {
+		m.kinds = map[string]Kind{}
+	}
+	m.kinds[name] = kind
+}
+
+//Gets The Kind Of A Field, As Added, Or Told By Its Value, Numbers Being Gauges
+func (m *Metric) GetKind(name string) Kind {
+	defer rlock(m.mutex)()
+	if kind, ok := m.kinds[name]; ok {
+		return kind
+	}
+	for index := range m.Fields {
+		if value, ok := m.Fields[index][name]; ok {
+			return kindOf(value)
+		}
+	}
+	return Gauge
+}
+
+//Get Tag For Given Index
+func (m *Metric) GetTag(index string, name string) string {
+	defer rlock(m.mutex)()
+	return m.Tags[index][name]
+}
+
+//Get A Copy Of The Tags For Given Index
+func (m *Metric) GetTags(index string) map[string]string {
+	defer rlock(m.mutex)()
+	tags := map[string]string{}
+	for k, v := range m.Tags[index] {
+		tags[k] = v
+	}
+	return tags
+}
+
+//Get Field For Given Index
+func (m *Metric) GetField(index string, name string) (value interface{}, ok bool) {
+	defer rlock(m.mutex)()
+	value, ok = m.Fields[index][name]
+	return
+}
+
+//Get A Copy Of The Fields For Given Index
+func (m *Metric) GetFields(index string) map[string]interface{} {
+	defer rlock(m.mutex)()
+	fields := map[string]interface{}{}
+	for k, v := range m.Fields[index] {
+		fields[k] = v
+	}
+	return fields
+}
+
+//Get Field For Given Index As A Number, False If It Is Missing Or Not Numeric
+func (m *Metric) GetFloat(index string, name string) (float64, bool) {
+	value, ok := m.GetField(index, name)
+	if !ok {
+		return 0, false
+	}
+	return toFloat(value)
+}
+
+//Get The Indexes That Have Fields
+func (m *Metric) Indexes() (indexes []string) {
+	defer rlock(m.mutex)()
+	for index := range m.Fields {
+		indexes = append(indexes, index)
+	}
+	return
+}
+
 func (m *Metric) IsEmpty() bool {
 	return m.Tags == nil && m.Fields == nil
 }
@@ -155,3 +421,63 @@ func (m *Metric) hasFields(index string) bool {
 	}
 	return true
 }
+
+/*
+ * Converts A Field Value To A Float, Returns False If It Is Not Numeric, Booleans Being 0 Or 1
+ */
+func toFloat(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+/*
+ * Tells The Kind Of A Field By Its Value
+ */
+func kindOf(v interface{}) Kind {
+	switch v.(type) {
+	case bool:
+		return Bool
+	case string, []byte:
+		return String
+	}
+	return Gauge
+}
+
+/*
+ * Locks A Mutex, Which Is Missing In Decoded Data, Returning The Function That Unlocks It
+ */
+func lock(mutex *sync.RWMutex) func() {
+	if mutex == nil {
+		return func() {}
+	}
+	mutex.Lock()
+	return mutex.Unlock
+}
+
+func rlock(mutex *sync.RWMutex) func() {
+	if mutex == nil {
+		return func() {}
+	}
+	mutex.RLock()
+	return mutex.RUnlock
+}
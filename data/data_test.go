@@ -0,0 +1,207 @@
+package data
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	g "github.com/soniah/gosnmp"
+)
+
+//Walks A Table Of Fake Results, As The Devices Do Over SNMP
+func fakeWalk(table map[string][]g.SnmpPDU) Walk {
+	return func(oid string) []g.SnmpPDU {
+		return table[oid]
+	}
+}
+
+func TestMetricConcurrentWrites(t *testing.T) {
+	d := NewData()
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			index := fmt.Sprint(i)
+			m := d.GetOrAddMetric("test_info")
+			m.AddTag(index, "name", "if"+index)
+			m.AddField(index, "status", i)
+			m.AddCounter(index, "bytes", uint64(i))
+			m.AddGauge(index, "percent", float64(i))
+			m.GetTag(index, "name")
+			m.GetKind("bytes")
+			d.AddTag("device_name", "test")
+			d.GetTag("device_name")
+		}(i)
+	}
+	wg.Wait()
+
+	m := d.GetMetric("test_info")
+	if len(m.Fields) != 50 || len(m.Tags) != 50 {
+		t.Fatalf("Expected 50 Indexes, Got %d Fields And %d Tags", len(m.Fields), len(m.Tags))
+	}
+	if v, _ := m.GetField("7", "bytes"); v != int64(7) {
+		t.Errorf("Expected Counter int64(7), Got %#v", v)
+	}
+	if v, _ := m.GetField("7", "percent"); v != float64(7) {
+		t.Errorf("Expected Gauge float64(7), Got %#v", v)
+	}
+}
+
+func TestSnapshotWhileWriting(t *testing.T) {
+	d := NewData()
+	d.GetOrAddMetric("test_info").AddGauge("0", "percent", 0)
+
+	//A Collection That Timed Out Keeps Writing While Its Data Is Handed To The Outputs
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 200; i++ {
+			index := fmt.Sprint(i)
+			m := d.GetOrAddMetric("test_info")
+			m.AddTag(index, "name", "if"+index)
+			m.AddGauge(index, "percent", float64(i))
+			d.AddTag("device_name", "test")
+			d.SetTimestamp(time.Now())
+		}
+	}()
+	for i := 0; i < 50; i++ {
+		s := d.Snapshot()
+		if _, err := json.Marshal(s); err != nil {
+			t.Fatal(err)
+		}
+		d.ForEachIndex(func(metric, index string, tags map[string]string, fields map[string]interface{}) {
+			_ = fields["percent"]
+		})
+	}
+	<-done
+
+	//The Snapshot Does Not Change With The Data
+	s := d.Snapshot()
+	d.GetMetric("test_info").AddGauge("0", "percent", 100)
+	if v, _ := s.GetMetric("test_info").GetField("0", "percent"); v != float64(0) {
+		t.Errorf("Expected The Value Of The Snapshot, Got %#v", v)
+	}
+	if s.GetMetric("test_info").GetKind("percent") != Gauge || len(s.GetMetric("test_info").Indexes()) != 200 {
+		t.Errorf("Expected The Kinds And The 200 Indexes In The Snapshot")
+	}
+}
+
+func TestAddFromEntriesConcurrent(t *testing.T) {
+	const ifDescr, ipAddrIfIndex = ".1.3.6.1.2.1.2.2.1.2", ".1.3.6.1.2.1.4.20.1.2"
+	table := map[string][]g.SnmpPDU{ifDescr: {}, ipAddrIfIndex: {}}
+	for i := 1; i <= 20; i++ {
+		table[ifDescr] = append(table[ifDescr], g.SnmpPDU{Name: fmt.Sprintf("%s.%d", ifDescr, i), Type: g.OctetString, Value: []byte(fmt.Sprintf("eth%d", i))})
+		for j := 1; j <= 3; j++ {
+			table[ipAddrIfIndex] = append(table[ipAddrIfIndex], g.SnmpPDU{Name: fmt.Sprintf("%s.10.%d.0.%d", ipAddrIfIndex, i, j), Type: g.Integer, Value: i})
+		}
+	}
+
+	//Reads What It Writes, As The Interface Addresses Do
+	function := Function(func(m Metric, entry Entry, pdu g.SnmpPDU) {
+		switch entry.Oid {
+		case ifDescr:
+			AddTags(m, entry, pdu)
+		case ipAddrIfIndex:
+			index := fmt.Sprint(pdu.Value)
+			var ip string
+			if tag := m.GetTag(index, entry.Name); tag != "" {
+				ip = tag + ";"
+			}
+			m.AddTag(index, entry.Name, ip+strings.TrimPrefix(pdu.Name, entry.Oid+"."))
+		}
+	})
+
+	d := NewData()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if i%2 == 0 {
+				d.AddFromEntries(fakeWalk(table), "interface_info", Entries{{"interface_descr", ifDescr}}, function)
+			} else {
+				d.AddFromEntries(fakeWalk(table), fmt.Sprintf("other_%d_info", i), Entries{{"interface_addr", ipAddrIfIndex}}, function)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if len(d.Metrics) != 6 {
+		t.Fatalf("Expected 6 Metrics, Got %d", len(d.Metrics))
+	}
+	if tag := d.GetMetric("interface_info").GetTag("3", "interface_descr"); tag != "eth3" {
+		t.Errorf("Expected eth3, Got %q", tag)
+	}
+	if tag := d.GetMetric("other_1_info").GetTag("3", "interface_addr"); tag != "10.3.0.1;10.3.0.2;10.3.0.3" {
+		t.Errorf("Expected The Addresses In Order, Got %q", tag)
+	}
+}
+
+func TestFieldKinds(t *testing.T) {
+	d := NewData()
+	walk := fakeWalk(map[string][]g.SnmpPDU{
+		".1.1": {{Name: ".1.1.1", Type: g.Counter64, Value: uint64(10)}},
+		".1.2": {{Name: ".1.2.1", Type: g.Integer, Value: 1}},
+	})
+	d.AddFromEntries(walk, "test_info", Entries{{"test_bytes", ".1.1"}, {"test_status", ".1.2"}}, AddFields)
+	m := d.GetMetric("test_info")
+	m.AddBool("1", "test_up", true)
+	m.AddString("1", "test_state", "up")
+
+	//The Values Stay As The Drivers Added Them, Besides uint64 Being Written As int64
+	if v, _ := m.GetField("1", "test_bytes"); v != int64(10) {
+		t.Errorf("Expected int64(10), Got %#v", v)
+	}
+	if v, _ := m.GetField("1", "test_status"); v != 1 {
+		t.Errorf("Expected int(1), Got %#v", v)
+	}
+	kinds := map[string]Kind{"test_bytes": Counter, "test_status": Gauge, "test_up": Bool, "test_state": String, "missing": Gauge}
+	for name, kind := range kinds {
+		if got := m.GetKind(name); got != kind {
+			t.Errorf("Expected Kind %d For %s, Got %d", kind, name, got)
+		}
+	}
+}
+
+func TestDecodedData(t *testing.T) {
+	d := NewData()
+	d.AddTag("device_name", "test")
+	d.GetOrAddMetric("test_info").AddField("1", "test_status", 1)
+
+	content, err := json.Marshal(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(content), "mutex") || strings.Contains(string(content), "kinds") {
+		t.Errorf("Unexpected Unexported Fields In %s", content)
+	}
+	var decoded Data
+	if err := json.Unmarshal(content, &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	var b bytes.Buffer
+	if err := gob.NewEncoder(&b).Encode([]*Data{&d}); err != nil {
+		t.Fatal(err)
+	}
+	var spilled []*Data
+	if err := gob.NewDecoder(&b).Decode(&spilled); err != nil {
+		t.Fatal(err)
+	}
+
+	//Decoded Data Has No Mutexes, But Is Still Read Through The Methods
+	for _, dat := range []*Data{&decoded, spilled[0]} {
+		if dat.GetTag("device_name") != "test" {
+			t.Errorf("Expected The Device Name, Got %q", dat.GetTag("device_name"))
+		}
+		if v, ok := dat.GetMetric("test_info").GetField("1", "test_status"); !ok || fmt.Sprint(v) != "1" {
+			t.Errorf("Expected The Field, Got %#v", v)
+		}
+	}
+}
@@ -11,11 +11,14 @@ type Entry struct {
 	Oid  string
 }
 
-//Function Called To Obtain A Tag/Field Value From An Entry
+//Function Called To Obtain A Tag/Field Value From An Entry, Writing Them To The Metric, Which Is Shared
 type Function func(metric Metric, entry Entry, pdu g.SnmpPDU)
 
 type Entries []Entry
 
+//Function Called To Walk An Oid, Limiting How Many Requests Are Made To The Device At Once
+type Walk func(oid string) []g.SnmpPDU
+
 var AddTags = Function(func(metric Metric, entry Entry, pdu g.SnmpPDU) {
 	index := snmp.GetIndex(pdu, entry.Oid)
 	var value string
@@ -33,4 +36,8 @@ var AddTags = Function(func(metric Metric, entry Entry, pdu g.SnmpPDU) {
 var AddFields = Function(func(metric Metric, entry Entry, pdu g.SnmpPDU) {
 	index := snmp.GetIndex(pdu, entry.Oid)
 	metric.AddField(index, entry.Name, pdu.Value)
+	switch pdu.Type {
+	case g.Counter32, g.Counter64:
+		metric.SetKind(entry.Name, Counter)
+	}
 })
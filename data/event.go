@@ -0,0 +1,96 @@
+package data
+
+//------------------------------------------------------------------------------------------
+//-----------------------------------------IMPORTS------------------------------------------
+//------------------------------------------------------------------------------------------
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/fccn/gofetch-snmp/config"
+	. "github.com/fccn/gofetch-snmp/log"
+)
+
+//------------------------------------------------------------------------------------------
+//----------------------------------------CONSTANTS-----------------------------------------
+//------------------------------------------------------------------------------------------
+const EVENT = "event_info"
+
+//Severities Of The Events
+const (
+	INFO     = "info"
+	WARNING  = "warning"
+	CRITICAL = "critical"
+)
+
+//------------------------------------------------------------------------------------------
+//-----------------------------------------STRUCTS------------------------------------------
+//------------------------------------------------------------------------------------------
+//Something That Happened On A Device, Received As A Trap Or Detected Between Collections
+type Event struct {
+	Type     string                 `json:"type"`     //Such As "link_down" Or "bgp_backward_transition"
+	Severity string                 `json:"severity"` //INFO, WARNING Or CRITICAL
+	Source   string                 `json:"source"`   //"trap" Or "poll"
+	Message  string                 `json:"message"`  //Human Readable Description
+	Tags     map[string]string      `json:"tags"`     //What The Event Is About, Such As "interface_name"
+	Fields   map[string]interface{} `json:"fields"`   //Values Related To The Event, Such As "interface_oper_status"
+}
+
+//Body Of The Requests To The Events Webhook
+type eventsPayload struct {
+	Timestamp time.Time         `json:"timestamp"`
+	Device    map[string]string `json:"device"`
+	Events    []Event           `json:"events"`
+}
+
+//------------------------------------------------------------------------------------------
+//----------------------------------------VARIABLES-----------------------------------------
+//------------------------------------------------------------------------------------------
+var eventsWebhook *Webhook
+
+//------------------------------------------------------------------------------------------
+//----------------------------------------FUNCTIONS-----------------------------------------
+//------------------------------------------------------------------------------------------
+//Sets Where The Events Are Posted, If Configured
+func EventsInit(conf config.Events) {
+	eventsWebhook = NewWebhook(conf.Webhook)
+}
+
+//Posts The Events Of A Device To The Webhook In The Background, If Configured
+func NotifyEvents(d *Data, events []Event) {
+	if eventsWebhook == nil || len(events) == 0 {
+		return
+	}
+	payload := eventsPayload{Timestamp: d.Timestamp, Device: map[string]string{}, Events: events}
+	for k, v := range d.Tags {
+		payload.Device[k] = v
+	}
+	go func() {
+		if err := eventsWebhook.Post(payload); err != nil {
+			ErrorLog(fmt.Sprintf("Could Not Post %d Events Of %s To Webhook: %s", len(events), payload.Device["device_name"], err.Error()))
+		}
+	}()
+}
+
+//Adds The Events As Indexes Of The Events Metric, Each With Its Type, Severity And Source As Tags
+func (d *Data) AddEvents(events []Event) {
+	if len(events) == 0 {
+		return
+	}
+	m := d.GetOrAddMetric(EVENT)
+	offset := len(m.Fields)
+	for i, e := range events {
+		index := strconv.Itoa(offset + i)
+		for name, value := range e.Tags {
+			m.AddTag(index, name, value)
+		}
+		m.AddTag(index, "event_type", e.Type)
+		m.AddTag(index, "event_severity", e.Severity)
+		m.AddTag(index, "event_source", e.Source)
+		for name, value := range e.Fields {
+			m.AddField(index, name, value)
+		}
+		m.AddField(index, "event_message", e.Message)
+	}
+}
@@ -0,0 +1,233 @@
+package data
+
+//------------------------------------------------------------------------------------------
+//-----------------------------------------IMPORTS------------------------------------------
+//------------------------------------------------------------------------------------------
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fccn/gofetch-snmp/config"
+	. "github.com/fccn/gofetch-snmp/log"
+)
+
+//------------------------------------------------------------------------------------------
+//-----------------------------------------STRUCTS------------------------------------------
+//------------------------------------------------------------------------------------------
+type graphite struct {
+	conf    config.Graphite
+	timeout time.Duration
+}
+
+//A Single Value Of A Metric Path
+type graphiteMetric struct {
+	Path      string
+	Value     float64
+	Timestamp int64
+}
+
+//------------------------------------------------------------------------------------------
+//----------------------------------------VARIABLES-----------------------------------------
+//------------------------------------------------------------------------------------------
+//Matches The Characters That Are Not Allowed In Graphite Path Nodes
+var graphiteIllegal = regexp.MustCompile(`[^A-Za-z0-9_:\-]+`)
+
+//Replaces The Characters That Are Not Allowed In Graphite Tag Values
+var graphiteTagSanitizer = strings.NewReplacer(";", "_", "~", "_", " ", "_")
+
+//Maximum Number Of Metrics Per Pickle, To Stay Below Carbon's Maximum Message Size
+const graphitePickleBatch = 500
+
+//------------------------------------------------------------------------------------------
+//----------------------------------------FUNCTIONS-----------------------------------------
+//------------------------------------------------------------------------------------------
+//Creates The Graphite Output, If Configured
+func GraphiteInit(conf *config.Graphite) error {
+	if conf == nil {
+		return nil
+	}
+	gr := &graphite{conf: *conf, timeout: 10 * time.Second}
+
+	//Default Values
+	if gr.conf.Template == "" {
+		gr.conf.Template = "gofetch.{device_name}.{metric}.{index}.{field}"
+	}
+	switch strings.ToLower(gr.conf.Protocol) {
+	case "":
+		gr.conf.Protocol = "plaintext"
+	case "plaintext", "pickle", "tagged":
+		gr.conf.Protocol = strings.ToLower(gr.conf.Protocol)
+	default:
+		return fmt.Errorf("Unknown Graphite Protocol \"%s\", Must Be \"plaintext\", \"pickle\" Or \"tagged\"", conf.Protocol)
+	}
+	if gr.conf.Timeout != nil {
+		if t, err := config.GetDuration(gr.conf.Timeout); err == nil {
+			gr.timeout = t
+		} else {
+			WarnLog(err.Error())
+		}
+	}
+
+	AddOutput(gr)
+	return nil
+}
+
+func (gr *graphite) Name() string {
+	return "Graphite"
+}
+
+//Flattens The Data Into Metric Paths And Sends Them To Carbon
+func (gr *graphite) Write(d []*Data) bool {
+	var metrics []graphiteMetric
+	for _, dat := range d {
+		metrics = append(metrics, gr.metrics(dat)...)
+	}
+	if len(metrics) == 0 {
+		return true
+	}
+
+	conn, err := net.DialTimeout("tcp", gr.conf.Address, gr.timeout)
+	if err != nil {
+		ErrorLog(fmt.Sprintf("Could Not Connect To Graphite: %s", err.Error()))
+		return false
+	}
+	defer conn.Close()
+	conn.SetWriteDeadline(time.Now().Add(gr.timeout))
+
+	var buffer bytes.Buffer
+	if gr.conf.Protocol == "pickle" {
+		for i := 0; i < len(metrics); i += graphitePickleBatch {
+			end := i + graphitePickleBatch
+			if end > len(metrics) {
+				end = len(metrics)
+			}
+			payload := graphitePickle(metrics[i:end])
+			binary.Write(&buffer, binary.BigEndian, uint32(len(payload)))
+			buffer.Write(payload)
+		}
+	} else {
+		for _, m := range metrics {
+			fmt.Fprintf(&buffer, "%s %s %d\n", m.Path, strconv.FormatFloat(m.Value, 'f', -1, 64), m.Timestamp)
+		}
+	}
+
+	if _, err := conn.Write(buffer.Bytes()); err != nil {
+		ErrorLog(fmt.Sprintf("Could Not Write To Graphite: %s", err.Error()))
+		return false
+	}
+	DebugLog(fmt.Sprintf("%d Metrics Were Written To Graphite", len(metrics)))
+	return true
+}
+
+//Builds A Metric For Each Numeric Field Of Each Index Of A Device
+func (gr *graphite) metrics(d *Data) (metrics []graphiteMetric) {
+	timestamp := d.Timestamp.Unix()
+	d.ForEachIndex(func(metric, index string, tags map[string]string, fields map[string]interface{}) {
+		for field, v := range fields {
+			value, ok := toFloat(v)
+			if !ok {
+				continue
+			}
+			path := gr.path(metric, index, field, tags)
+			if gr.conf.Protocol == "tagged" {
+				path += graphiteTags(tags)
+			}
+			metrics = append(metrics, graphiteMetric{path, value, timestamp})
+		}
+	})
+	return
+}
+
+//Fills The Path Template, Leaving Out The Nodes That End Up Empty
+func (gr *graphite) path(metric, index, field string, tags map[string]string) string {
+	path := FillTemplate(gr.conf.Template, func(key string) string {
+		switch key {
+		case "metric":
+			return graphiteSanitize(metric)
+		case "index":
+			return graphiteSanitize(index)
+		case "field":
+			return graphiteSanitize(field)
+		default:
+			return graphiteSanitize(tags[key])
+		}
+	})
+
+	var nodes []string
+	for _, node := range strings.Split(path, ".") {
+		if node != "" {
+			nodes = append(nodes, node)
+		}
+	}
+	return strings.Join(nodes, ".")
+}
+
+//Builds The Tags Of The Graphite 1.1 Tagged Series Syntax, Sorted By Name
+func graphiteTags(tags map[string]string) string {
+	names := []string{}
+	for name, value := range tags {
+		if value != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		b.WriteString(";" + graphiteSanitize(name) + "=" + graphiteTagSanitizer.Replace(tags[name]))
+	}
+	return b.String()
+}
+
+//Replaces The Characters That Are Not Allowed In A Path Node
+func graphiteSanitize(node string) string {
+	return strings.Trim(graphiteIllegal.ReplaceAllString(node, "_"), "_")
+}
+
+//Encodes The Metrics As A Pickle (Protocol 2) List Of (Path, (Timestamp, Value)) Tuples
+func graphitePickle(metrics []graphiteMetric) []byte {
+	var b bytes.Buffer
+	b.Write([]byte{0x80, 0x02}) //PROTO 2
+	b.WriteByte(']')            //EMPTY_LIST
+	b.WriteByte('(')            //MARK
+	for _, m := range metrics {
+		//Path As BINUNICODE
+		b.WriteByte('X')
+		binary.Write(&b, binary.LittleEndian, uint32(len(m.Path)))
+		b.WriteString(m.Path)
+
+		//Timestamp As LONG1, Value As BINFLOAT
+		timestamp := graphiteLong(m.Timestamp)
+		b.WriteByte(0x8a)
+		b.WriteByte(byte(len(timestamp)))
+		b.Write(timestamp)
+		b.WriteByte('G')
+		binary.Write(&b, binary.BigEndian, math.Float64bits(m.Value))
+
+		b.WriteByte(0x86) //TUPLE2 (Timestamp, Value)
+		b.WriteByte(0x86) //TUPLE2 (Path, (Timestamp, Value))
+	}
+	b.WriteByte('e') //APPENDS
+	b.WriteByte('.') //STOP
+	return b.Bytes()
+}
+
+//Encodes An Integer As Little Endian Two's Complement With The Minimum Number Of Bytes, As LONG1 Expects
+func graphiteLong(n int64) (b []byte) {
+	for {
+		b = append(b, byte(n))
+		n >>= 8
+		last := b[len(b)-1]
+		if (n == 0 && last&0x80 == 0) || (n == -1 && last&0x80 != 0) {
+			return
+		}
+	}
+}
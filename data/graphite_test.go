@@ -0,0 +1,91 @@
+package data
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/fccn/gofetch-snmp/config"
+)
+
+func TestGraphitePath(t *testing.T) {
+	gr := &graphite{conf: config.Graphite{Template: "gofetch.{device_name}.{site}.{metric}.{index}.{field}"}}
+	tests := []struct {
+		index    string
+		tags     map[string]string
+		expected string
+	}{
+		{"1", map[string]string{"device_name": "core", "site": "lisbon"}, "gofetch.core.lisbon.test_info.1.test_percent"},
+		//The Dots And Other Illegal Characters Do Not Add Nodes, Nor Leave Them Padded
+		{"1.2", map[string]string{"device_name": "core/1.lisbon", "site": " (north) "}, "gofetch.core_1_lisbon.north.test_info.1_2.test_percent"},
+		//The Nodes That End Up Empty Are Left Out
+		{"1", map[string]string{"device_name": "core"}, "gofetch.core.test_info.1.test_percent"},
+		{"1", map[string]string{"device_name": "core", "site": "..."}, "gofetch.core.test_info.1.test_percent"},
+		{"", map[string]string{}, "gofetch.test_info.test_percent"},
+	}
+	for _, test := range tests {
+		if got := gr.path("test_info", test.index, "test_percent", test.tags); got != test.expected {
+			t.Errorf("%v - Expected %s, Got %s", test.tags, test.expected, got)
+		}
+	}
+}
+
+func TestGraphiteTags(t *testing.T) {
+	tests := []struct {
+		tags     map[string]string
+		expected string
+	}{
+		{map[string]string{"b": "2", "a": "1"}, ";a=1;b=2"},
+		//The Values Keep Their Dots And Slashes, But Not The Separators Of The Syntax
+		{map[string]string{"device_name": "core/1.lisbon", "descr": "Gi0/1 uplink;to~isp"}, ";descr=Gi0/1_uplink_to_isp;device_name=core/1.lisbon"},
+		//Names Are Sanitized Like Nodes, Empty Values Are Left Out
+		{map[string]string{"if.name": "eth0", "empty": ""}, ";if_name=eth0"},
+		{map[string]string{}, ""},
+	}
+	for _, test := range tests {
+		if got := graphiteTags(test.tags); got != test.expected {
+			t.Errorf("%v - Expected %s, Got %s", test.tags, test.expected, got)
+		}
+	}
+}
+
+func TestGraphiteLong(t *testing.T) {
+	tests := []struct {
+		n        int64
+		expected []byte
+	}{
+		{0, []byte{0x00}},
+		{127, []byte{0x7f}},
+		{128, []byte{0x80, 0x00}},
+		{-1, []byte{0xff}},
+		{-128, []byte{0x80}},
+		{-129, []byte{0x7f, 0xff}},
+		{1700000000, []byte{0x00, 0xf1, 0x53, 0x65}},
+	}
+	for _, test := range tests {
+		if got := graphiteLong(test.n); !bytes.Equal(got, test.expected) {
+			t.Errorf("%d - Expected % x, Got % x", test.n, test.expected, got)
+		}
+	}
+}
+
+func TestGraphitePickle(t *testing.T) {
+	got := graphitePickle([]graphiteMetric{
+		{"a.b", 1.5, 1700000000},
+		{"c", -2, -129},
+	})
+	expected := []byte{
+		0x80, 0x02, ']', '(', //PROTO 2, EMPTY_LIST, MARK
+		'X', 0x03, 0x00, 0x00, 0x00, 'a', '.', 'b', //BINUNICODE "a.b"
+		0x8a, 0x04, 0x00, 0xf1, 0x53, 0x65, //LONG1 1700000000
+		'G', 0x3f, 0xf8, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, //BINFLOAT 1.5
+		0x86, 0x86, //TUPLE2, TUPLE2
+		'X', 0x01, 0x00, 0x00, 0x00, 'c', //BINUNICODE "c"
+		0x8a, 0x02, 0x7f, 0xff, //LONG1 -129
+		'G', 0xc0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, //BINFLOAT -2
+		0x86, 0x86, //TUPLE2, TUPLE2
+		'e', '.', //APPENDS, STOP
+	}
+	if !bytes.Equal(got, expected) {
+		t.Errorf("Expected\n% x\nGot\n% x", expected, got)
+	}
+}
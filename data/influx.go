@@ -4,10 +4,17 @@ package data
 //-----------------------------------------IMPORTS------------------------------------------
 //------------------------------------------------------------------------------------------
 import (
+	"bytes"
+	"compress/gzip"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
 	"time"
 
+	"github.com/fccn/gofetch-snmp/config"
 	. "github.com/fccn/gofetch-snmp/log"
 	client "github.com/influxdata/influxdb1-client/v2"
 	"gopkg.in/yaml.v2"
@@ -17,85 +24,250 @@ import (
 //-----------------------------------------STRUCTS------------------------------------------
 //------------------------------------------------------------------------------------------
 type influx struct {
-	Server   string `yaml:"server"`
-	Username string `yaml:"username"`
-	Password string `yaml:"password"`
-	Database string `yaml:"database"`
-	Ping     int    `yaml:"ping"`
+	Version   int         `yaml:"version"` //1 For The v1 API, 2 For The v2 API (InfluxDB 2.x And 3.x)
+	Server    string      `yaml:"server"`
+	Username  string      `yaml:"username"` //v1 Only
+	Password  string      `yaml:"password"` //v1 Only
+	Database  string      `yaml:"database"` //v1 Only
+	Org       string      `yaml:"org"`      //v2 Only
+	Bucket    string      `yaml:"bucket"`   //v2 Only
+	Token     string      `yaml:"token"`    //v2 Only
+	Precision string      `yaml:"precision"`
+	Gzip      bool        `yaml:"gzip"`
+	Batch     int         `yaml:"batch"` //Maximum Number Of Points Per Write
+	Tls       *config.Tls `yaml:"tls"`
+	Ping      int         `yaml:"ping"`
+	Timeout   interface{} `yaml:"timeout"` //Maximum Duration Of Each Write
+}
+
+//Adds A No-Op Close To A Writer, So It Can Be Used In Place Of A Gzip Writer
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
 }
 
 //------------------------------------------------------------------------------------------
 //----------------------------------------VARIABLES-----------------------------------------
 //------------------------------------------------------------------------------------------
 var db influx
+var enabled bool
 var c client.Client
+var h *http.Client
+var influxTimeout = 10 * time.Second
+
+//Maps The v2 API Precisions To The Ones Used By The v1 API
+var influxV1Precisions = map[string]string{
+	"ns": "n",
+	"us": "u",
+	"ms": "ms",
+	"s":  "s",
+}
 
 //------------------------------------------------------------------------------------------
 //----------------------------------------FUNCTIONS-----------------------------------------
 //------------------------------------------------------------------------------------------
 
 //Creates The InfluxDB Connection And Checks Server
-func InfluxInit(dbConfigFile string) {
+func InfluxInit(dbConfigFile string) error {
 	//Decode The Configurations File To The DB Struct
-	if conf, err := ioutil.ReadFile(dbConfigFile); err == nil {
-		if err := yaml.Unmarshal(conf, &db); err != nil {
-			FatalLog(fmt.Sprintf("Could Not Decode InfluxDB Configuration File: %v", err))
+	conf, err := ioutil.ReadFile(dbConfigFile)
+	if err == nil {
+		err = yaml.Unmarshal(conf, &db)
+	}
+	if err != nil {
+		return fmt.Errorf("Could Not Decode InfluxDB Configuration File: %v", err)
+	}
+
+	//Default Values
+	if db.Version == 0 {
+		db.Version = 1
+	}
+	if db.Precision == "" {
+		db.Precision = "s"
+	}
+	if db.Batch <= 0 {
+		db.Batch = 5000
+	}
+	if db.Timeout != nil {
+		if t, err := config.GetDuration(db.Timeout); err == nil {
+			influxTimeout = t
+		} else {
+			WarnLog(err.Error())
+		}
+	}
+	if _, ok := influxV1Precisions[db.Precision]; !ok {
+		return fmt.Errorf("Invalid InfluxDB Precision \"%s\", Must Be One Of: ns, us, ms, s", db.Precision)
+	}
+
+	tlsConfig, err := newTlsConfig(db.Tls)
+	if err != nil {
+		return fmt.Errorf("Could Not Load InfluxDB TLS Configurations: %v", err)
+	}
+
+	//Use The Configurations From The File To Initialize The DB Connection
+	switch db.Version {
+	case 1:
+		encoding := client.DefaultEncoding
+		if db.Gzip {
+			encoding = client.GzipEncoding
 		}
-		//Use The Configurations From The File To Initialize The DB Connection
 		if c, err = client.NewHTTPClient(
 			client.HTTPConfig{
-				Addr:     db.Server,
-				Username: db.Username,
-				Password: db.Password,
+				Addr:          db.Server,
+				Username:      db.Username,
+				Password:      db.Password,
+				TLSConfig:     tlsConfig,
+				WriteEncoding: encoding,
+				Timeout:       influxTimeout,
 			}); err != nil {
-			FatalLog(fmt.Sprintf("Could Not Initialize InfluxDB Client: %v", err))
+			return fmt.Errorf("Could Not Initialize InfluxDB Client: %v", err)
 		}
-	} else {
-		FatalLog(fmt.Sprintf("Could Not Decode InfluxDB Configuration File: %v", err))
+	case 2:
+		//Keeps The Proxy From The Environment And The Dial Timeouts Of The Default Transport
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = tlsConfig
+		h = &http.Client{Transport: transport, Timeout: influxTimeout}
+	default:
+		return fmt.Errorf("Invalid InfluxDB Version %d, Must Be 1 Or 2", db.Version)
+	}
+	enabled = true
+	return nil
+}
+
+//Gives The Effective InfluxDB Configurations, Nil If It Was Not Initialized
+func InfluxConfig() interface{} {
+	if !enabled {
+		return nil
 	}
+	return db
+}
+
+//Checks If The InfluxDB Was Initialized
+func InfluxEnabled() bool {
+	return enabled
 }
 
 func InfluxTestConnection() bool {
 	var err error
-	if _, _, err = c.Ping(time.Duration(db.Ping) * time.Second); err != nil {
-		Log(fmt.Sprintf("Could Not Estabilish InfluxDB Connection: %s", err.Error()))
+	timeout := time.Duration(db.Ping) * time.Second
+	switch db.Version {
+	case 1:
+		_, _, err = c.Ping(timeout)
+	case 2:
+		var resp *http.Response
+		ping := &http.Client{Transport: h.Transport, Timeout: timeout}
+		if resp, err = ping.Get(strings.TrimSuffix(db.Server, "/") + "/ping"); err == nil {
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+				err = fmt.Errorf("Unexpected Status %s", resp.Status)
+			}
+		}
+	}
+	if err != nil {
+		ErrorLog(fmt.Sprintf("Could Not Estabilish InfluxDB Connection: %s", err.Error()))
 	}
 	return err == nil
 }
 
-//Creates A Batch To An InfluxDB
-func InfluxCreateBatch() (bp client.BatchPoints) {
-	var err error
-	if bp, err = client.NewBatchPoints(client.BatchPointsConfig{
-		Database:  db.Database,
-		Precision: "s",
-	}); err != nil {
-		Log(fmt.Sprintf("Could Not Create A BatchPoints Instance: %s", err.Error()))
+//Writes The Data In Batches Of Whole Devices With At Most "Batch" Points, Returns The Data That Could Not Be Written
+func InfluxWrite(d []*Data) (failed []*Data) {
+	var batch []*Data
+	var points []*client.Point
+	flush := func() {
+		if len(batch) > 0 && !influxWritePoints(points) {
+			failed = append(failed, batch...)
+		}
+		batch, points = nil, nil
+	}
+	for _, dat := range d {
+		pts := dat.InfluxPoints()
+		if len(points) > 0 && len(points)+len(pts) > db.Batch {
+			flush()
+		}
+		batch = append(batch, dat)
+		points = append(points, pts...)
 	}
+	flush()
 	return
 }
 
-//Creates A Point And Adds It To A Batch
-func InfluxAddPoint(bp client.BatchPoints, name string, tags *map[string]string, fields *map[string]interface{}, timestamp time.Time) {
-	pt, err := client.NewPoint(name, *tags, *fields, timestamp)
+//Writes The Points Through The Configured API Version
+func influxWritePoints(points []*client.Point) bool {
+	var err error
+	switch db.Version {
+	case 1:
+		var bp client.BatchPoints
+		if bp, err = client.NewBatchPoints(client.BatchPointsConfig{
+			Database:  db.Database,
+			Precision: influxV1Precisions[db.Precision],
+		}); err == nil {
+			bp.AddPoints(points)
+			err = c.Write(bp)
+		}
+	case 2:
+		err = influxWriteV2(points)
+	}
 	if err != nil {
-		DebugLog(fmt.Sprintf("Could Not Add Point %s: %s", name, err.Error()))
-		return
+		ErrorLog(fmt.Sprintf("Could Not Write BatchPoints: %s", err.Error()))
+		return false
 	}
-	bp.AddPoint(pt)
+	DebugLog(fmt.Sprintf("Batch Of %d Points Was Written To DB", len(points)))
+	return true
 }
 
-//Writes The Batch Point And Closes The Connection
-func InfluxWriteBatch(bp client.BatchPoints) bool {
-	var err error
-	if err = c.Write(bp); err != nil {
-		Log(fmt.Sprintf("Could Not Write BatchPoints: %s", err.Error()))
-		return false
+//Writes The Points In Line Protocol To The v2 Write API
+func influxWriteV2(points []*client.Point) error {
+	var body bytes.Buffer
+	var w io.WriteCloser = nopCloser{&body}
+	if db.Gzip {
+		w = gzip.NewWriter(&body)
 	}
-	if err = c.Close(); err != nil {
-		Log(fmt.Sprintf("Could Not Close InfluxDB Connection: %s", err.Error()))
-		return false
+	for _, pt := range points {
+		if _, err := w.Write([]byte(pt.PrecisionString(influxV1Precisions[db.Precision]) + "\n")); err != nil {
+			return err
+		}
 	}
-	DebugLog("Batch Was Written To DB")
-	return true
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	query := url.Values{}
+	query.Set("org", db.Org)
+	query.Set("bucket", db.Bucket)
+	query.Set("precision", db.Precision)
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(db.Server, "/")+"/api/v2/write?"+query.Encode(), &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if db.Token != "" {
+		req.Header.Set("Authorization", "Token "+db.Token)
+	}
+	if db.Gzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+
+	resp, err := h.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("%s - %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+//Creates A Point, Returns Nil If It Is Not Valid
+func influxNewPoint(name string, tags map[string]string, fields map[string]interface{}, timestamp time.Time) *client.Point {
+	pt, err := client.NewPoint(name, tags, fields, timestamp)
+	if err != nil {
+		DebugLog(fmt.Sprintf("Could Not Add Point %s: %s", name, err.Error()))
+		return nil
+	}
+	return pt
 }
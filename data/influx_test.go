@@ -0,0 +1,122 @@
+package data
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+//Initializes The InfluxDB From A Configuration File, Restoring The Previous One Afterwards
+func testInflux(t *testing.T, content string) {
+	t.Helper()
+	previous, previousEnabled, previousClient, previousTimeout := db, enabled, h, influxTimeout
+	t.Cleanup(func() { db, enabled, h, influxTimeout = previous, previousEnabled, previousClient, previousTimeout })
+	db = influx{}
+	file := filepath.Join(t.TempDir(), "db.yml")
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := InfluxInit(file); err != nil {
+		t.Fatal(err)
+	}
+}
+
+//A Device With A Point For Each Of Two Interfaces
+func influxDevice(name string) *Data {
+	d := NewData()
+	d.AddTag("device_name", name)
+	m := d.GetOrAddMetric("test_info")
+	for i := 1; i <= 2; i++ {
+		m.AddTag(fmt.Sprint(i), "test_name", fmt.Sprintf("eth%d", i))
+		m.AddGauge(fmt.Sprint(i), "test_percent", float64(i))
+	}
+	d.SetTimestamp(time.Unix(100, 0))
+	return &d
+}
+
+func TestInfluxWriteV2(t *testing.T) {
+	var mutex sync.Mutex
+	var requests []string //Lines Of Each Request, Sorted
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if r.URL.Path != "/api/v2/write" || query.Get("org") != "fccn" || query.Get("bucket") != "gofetch" || query.Get("precision") != "ms" {
+			t.Errorf("Unexpected Request To %s", r.URL)
+		}
+		if r.Header.Get("Authorization") != "Token secret" || r.Header.Get("Content-Encoding") != "gzip" {
+			t.Errorf("Unexpected Headers %v", r.Header)
+		}
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		body, err := ioutil.ReadAll(gz)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		lines := strings.Split(strings.TrimSpace(string(body)), "\n")
+		sort.Strings(lines)
+		mutex.Lock()
+		requests = append(requests, strings.Join(lines, "\n"))
+		mutex.Unlock()
+
+		//The Batch Of Device "c" Is Refused
+		if strings.Contains(string(body), "device_name=c") {
+			http.Error(w, `{"code":"invalid"}`, http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	testInflux(t, "version: 2\nserver: "+server.URL+"/\norg: fccn\nbucket: gofetch\ntoken: secret\nprecision: ms\ngzip: true\nbatch: 4\n")
+
+	//Two Points Per Device, So A Batch Of Four Holds Two Devices
+	a, b, c, d := influxDevice("a"), influxDevice("b"), influxDevice("c"), influxDevice("d")
+	failed := InfluxWrite([]*Data{a, b, c, d})
+	if len(failed) != 2 || failed[0] != c || failed[1] != d {
+		t.Errorf("Expected Devices c And d To Fail, Got %d Devices", len(failed))
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if len(requests) != 2 {
+		t.Fatalf("Expected 2 Requests, Got %d", len(requests))
+	}
+	expected := strings.Join([]string{
+		"test_info,device_name=a,test_name=eth1 test_percent=1 100000",
+		"test_info,device_name=a,test_name=eth2 test_percent=2 100000",
+		"test_info,device_name=b,test_name=eth1 test_percent=1 100000",
+		"test_info,device_name=b,test_name=eth2 test_percent=2 100000",
+	}, "\n")
+	if requests[0] != expected {
+		t.Errorf("Expected The Line Protocol\n%s\nGot\n%s", expected, requests[0])
+	}
+	if !strings.Contains(requests[1], "device_name=c") || !strings.Contains(requests[1], "device_name=d") {
+		t.Errorf("Expected Devices c And d In The Second Batch, Got\n%s", requests[1])
+	}
+}
+
+func TestInfluxTransport(t *testing.T) {
+	testInflux(t, "version: 2\nserver: http://localhost:8086\ntimeout: 3s\n")
+
+	//The Default Transport's Proxy And Dial Timeouts Are Kept
+	transport := h.Transport.(*http.Transport)
+	defaults := http.DefaultTransport.(*http.Transport)
+	if transport == defaults || transport.Proxy == nil || transport.DialContext == nil || transport.TLSHandshakeTimeout != defaults.TLSHandshakeTimeout {
+		t.Errorf("Expected A Clone Of The Default Transport, Got %+v", transport)
+	}
+	if h.Timeout != 3*time.Second || db.Precision != "s" || db.Batch != 5000 {
+		t.Errorf("Unexpected Defaults: Timeout %s, Precision %s, Batch %d", h.Timeout, db.Precision, db.Batch)
+	}
+}
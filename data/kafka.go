@@ -0,0 +1,209 @@
+package data
+
+//------------------------------------------------------------------------------------------
+//-----------------------------------------IMPORTS------------------------------------------
+//------------------------------------------------------------------------------------------
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Shopify/sarama"
+	"github.com/fccn/gofetch-snmp/config"
+	. "github.com/fccn/gofetch-snmp/log"
+)
+
+//------------------------------------------------------------------------------------------
+//-----------------------------------------STRUCTS------------------------------------------
+//------------------------------------------------------------------------------------------
+type kafka struct {
+	conf     config.Kafka
+	config   *sarama.Config
+	producer sarama.SyncProducer //Nil Until The Brokers Are Reachable
+}
+
+//------------------------------------------------------------------------------------------
+//----------------------------------------FUNCTIONS-----------------------------------------
+//------------------------------------------------------------------------------------------
+//Creates The Kafka Output, If Configured
+func KafkaInit(conf *config.Kafka) error {
+	if conf == nil {
+		return nil
+	}
+	k := &kafka{conf: *conf}
+
+	switch strings.ToLower(k.conf.Format) {
+	case "":
+		k.conf.Format = "json"
+	case "json", "line":
+		k.conf.Format = strings.ToLower(k.conf.Format)
+	default:
+		return fmt.Errorf("Unknown Kafka Format \"%s\", Must Be \"json\" Or \"line\"", conf.Format)
+	}
+	if k.conf.Topic == "" {
+		return fmt.Errorf("Kafka Topic Was Not Configured")
+	}
+
+	//Messages Are Keyed By Device Name, The Hash Partitioner Keeps Each Device On One Partition
+	k.config = sarama.NewConfig()
+	k.config.ClientID = "gofetch-snmp"
+	k.config.Producer.RequiredAcks = sarama.WaitForAll
+	k.config.Producer.Partitioner = sarama.NewHashPartitioner
+	k.config.Producer.Retry.Max = k.conf.Retries
+	k.config.Producer.Return.Successes = true
+	//A Retried Request Could Otherwise Land After The Next One, Reordering The Device's Messages
+	k.config.Net.MaxOpenRequests = 1
+	if k.conf.Timeout != nil {
+		if t, err := config.GetDuration(k.conf.Timeout); err == nil {
+			k.config.Net.DialTimeout = t
+			k.config.Producer.Timeout = t
+		} else {
+			WarnLog(err.Error())
+		}
+	}
+	tlsConfig, err := newTlsConfig(k.conf.Tls)
+	if err != nil {
+		return fmt.Errorf("Could Not Load Kafka TLS Configurations: %v", err)
+	}
+	if tlsConfig != nil {
+		k.config.Net.TLS.Enable = true
+		k.config.Net.TLS.Config = tlsConfig
+	}
+	if k.conf.Username != "" {
+		k.config.Net.SASL.Enable = true
+		k.config.Net.SASL.User = k.conf.Username
+		k.config.Net.SASL.Password = k.conf.Password
+	}
+
+	//The Brokers May Not Be Reachable Yet, Connection Is Retried On Each Write
+	k.connect()
+
+	AddOutput(k)
+	return nil
+}
+
+func (k *kafka) Name() string {
+	return "Kafka"
+}
+
+//The Data That Could Not Be Published Is Stored In The Archive
+func (k *kafka) Retains() bool {
+	return true
+}
+
+//Creates The Producer, If It Wasn't Created Already
+func (k *kafka) connect() bool {
+	if k.producer != nil {
+		return true
+	}
+	var err error
+	if k.producer, err = sarama.NewSyncProducer(k.conf.Brokers, k.config); err != nil {
+		ErrorLog(fmt.Sprintf("Could Not Connect To Kafka Brokers: %s", err.Error()))
+		k.producer = nil
+		return false
+	}
+	return true
+}
+
+//Closes The Producer, If It Was Created
+func (k *kafka) Close() error {
+	if k.producer == nil {
+		return nil
+	}
+	return k.producer.Close()
+}
+
+//Publishes The Data, Storing Locally The Data That Could Not Be Delivered
+func (k *kafka) Write(d []*Data) bool {
+	if !k.connect() {
+		k.spool(d)
+		return false
+	}
+
+	var messages []*sarama.ProducerMessage
+	for _, dat := range d {
+		messages = append(messages, k.messages(dat)...)
+	}
+	if len(messages) == 0 {
+		return true
+	}
+
+	err := k.producer.SendMessages(messages)
+	if err == nil {
+		DebugLog(fmt.Sprintf("%d Messages Were Published To Kafka", len(messages)))
+		return true
+	}
+	ErrorLog(fmt.Sprintf("Could Not Publish To Kafka: %s", err.Error()))
+
+	//Store Only The Devices Whose Messages Failed, Or Everything If It Is Unknown Which Failed
+	var producerErrors sarama.ProducerErrors
+	if !errors.As(err, &producerErrors) {
+		k.spool(d)
+		return false
+	}
+	failed := map[*Data]bool{}
+	var spool []*Data
+	for _, e := range producerErrors {
+		if dat, ok := e.Msg.Metadata.(*Data); ok && !failed[dat] {
+			failed[dat] = true
+			spool = append(spool, dat)
+		}
+	}
+	k.spool(spool)
+	return false
+}
+
+//Builds The Messages Of A Device, One Per Device Or One Per Point
+func (k *kafka) messages(d *Data) (messages []*sarama.ProducerMessage) {
+	key := sarama.StringEncoder(d.GetTag("device_name"))
+	points := d.InfluxPoints()
+
+	newMessage := func(value []byte) *sarama.ProducerMessage {
+		return &sarama.ProducerMessage{
+			Topic:     k.conf.Topic,
+			Key:       key,
+			Value:     sarama.ByteEncoder(value),
+			Timestamp: d.Timestamp,
+			Metadata:  d,
+		}
+	}
+
+	switch {
+	case k.conf.Points:
+		for _, pt := range points {
+			var value []byte
+			if k.conf.Format == "line" {
+				value = []byte(pt.String())
+			} else if v, err := json.Marshal(NewJsonPoint(pt)); err == nil {
+				value = v
+			} else {
+				ErrorLog(fmt.Sprintf("Could Not Encode Point %s: %s", pt.Name(), err.Error()))
+				continue
+			}
+			messages = append(messages, newMessage(value))
+		}
+	case k.conf.Format == "line":
+		lines := make([]string, 0, len(points))
+		for _, pt := range points {
+			lines = append(lines, pt.String())
+		}
+		if len(lines) > 0 {
+			messages = append(messages, newMessage([]byte(strings.Join(lines, "\n"))))
+		}
+	default:
+		if value, err := json.Marshal(d); err == nil {
+			messages = append(messages, newMessage(value))
+		} else {
+			ErrorLog(fmt.Sprintf("Could Not Encode Data Of %s: %s", d.GetTag("device_name"), err.Error()))
+		}
+	}
+	return
+}
+
+//Stores Locally The Data That Could Not Be Published
+func (k *kafka) spool(d []*Data) {
+	if len(d) > 0 && localWrite("kafka-", d) {
+		DebugLog(fmt.Sprintf("Stored %d Devices' Data Locally After Kafka Failure", len(d)))
+	}
+}
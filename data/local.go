@@ -1,36 +1,305 @@
 package data
 
+//------------------------------------------------------------------------------------------
+//-----------------------------------------IMPORTS------------------------------------------
+//------------------------------------------------------------------------------------------
 import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/fccn/gofetch-snmp/config"
 	. "github.com/fccn/gofetch-snmp/log"
 )
 
+//------------------------------------------------------------------------------------------
+//-----------------------------------------STRUCTS------------------------------------------
+//------------------------------------------------------------------------------------------
+//Line Of The Archive Index, Describing One File
+type ArchiveEntry struct {
+	File    string    `json:"file"` //Path Relative To The Archive Directory
+	From    time.Time `json:"from"` //Oldest Timestamp Of The Data In The File
+	To      time.Time `json:"to"`   //Newest Timestamp Of The Data In The File
+	Devices int       `json:"devices"`
+	Size    int64     `json:"size"`
+}
+
+//------------------------------------------------------------------------------------------
+//----------------------------------------VARIABLES-----------------------------------------
+//------------------------------------------------------------------------------------------
+var archive = config.Archive{Directory: "."}
+var archiveMaxAge time.Duration
+var archiveMutex sync.Mutex
+
+//Name Of The Index File, In The Archive Directory
+const archiveIndex = "index.json"
+
+//Layouts Of The Directories That Group The Files Of Each Period
+var archiveRotations = map[string]string{
+	"":       "",
+	"hourly": "2006-01-02T15",
+	"daily":  "2006-01-02",
+}
+
 //------------------------------------------------------------------------------------------
 //----------------------------------------FUNCTIONS-----------------------------------------
 //------------------------------------------------------------------------------------------
-func LocalWrite(d []*Data) bool {
-	//Marshal The Data And Check For Errors
-	if data, err := json.MarshalIndent(d, "", " "); err == nil {
-		//Get The Current Time In Nanoseconds For The File's Name
-		fileName := fmt.Sprint(time.Now().UnixNano()) + ".json"
+//Sets The Archive Configurations, Creating Its Directory
+func ArchiveInit(conf config.Archive) error {
+	if conf.Directory == "" {
+		conf.Directory = "."
+	}
+	conf.Rotation = strings.ToLower(conf.Rotation)
+	if _, ok := archiveRotations[conf.Rotation]; !ok {
+		return fmt.Errorf("Unknown Archive Rotation \"%s\", Must Be \"hourly\" Or \"daily\"", conf.Rotation)
+	}
+	if conf.MaxAge != nil {
+		if t, err := config.GetDuration(conf.MaxAge); err == nil {
+			archiveMaxAge = t
+		} else {
+			WarnLog(err.Error())
+		}
+	}
+	if err := os.MkdirAll(conf.Directory, 0755); err != nil {
+		return fmt.Errorf("Could Not Create Archive Directory: %v", err)
+	}
+	archive = conf
+	return nil
+}
 
-		//Create A Write-Only File With The Specified name
-		if f, err := os.OpenFile(fileName, os.O_WRONLY|os.O_CREATE, 0755); err == nil {
-			//Close On Return
-			defer f.Close()
+//Writes The Data To The Archive, With The File's Name Starting With The Given Prefix
+func localWrite(prefix string, d []*Data) bool {
+	if len(d) == 0 {
+		return true
+	}
 
-			//Write The Content To The File
-			if err := ioutil.WriteFile(fileName, data, 0755); err != nil {
-				Log("Could not write to file: " + err.Error())
-			}
+	//Marshal The Data And Compress It, If Configured
+	content, err := json.MarshalIndent(d, "", " ")
+	if err != nil {
+		ErrorLog("Could Not Encode Data: " + err.Error())
+		return false
+	}
+	ext := ".json"
+	if archive.Gzip {
+		var b bytes.Buffer
+		w := gzip.NewWriter(&b)
+		w.Write(content)
+		w.Close()
+		content, ext = b.Bytes(), ".json.gz"
+	}
 
-			return true
+	//Get The Current Time In Nanoseconds For The File's Name, And The Period For Its Directory
+	now := time.Now().UTC()
+	file := prefix + fmt.Sprint(now.UnixNano()) + ext
+	if layout := archiveRotations[archive.Rotation]; layout != "" {
+		file = filepath.Join(now.Format(layout), file)
+	}
+
+	archiveMutex.Lock()
+	defer archiveMutex.Unlock()
+
+	if err := atomicWrite(filepath.Join(archive.Directory, file), content); err != nil {
+		ErrorLog("Could Not Write To Archive: " + err.Error())
+		return false
+	}
+
+	//Register The File In The Index And Apply The Retention Limits
+	entry := ArchiveEntry{File: file, Devices: len(d), Size: int64(len(content))}
+	for _, dat := range d {
+		if dat.Timestamp.IsZero() {
+			continue
+		}
+		if entry.From.IsZero() || dat.Timestamp.Before(entry.From) {
+			entry.From = dat.Timestamp
+		}
+		if dat.Timestamp.After(entry.To) {
+			entry.To = dat.Timestamp
+		}
+	}
+	if entry.From.IsZero() {
+		entry.From, entry.To = now, now
+	}
+	if err := appendArchiveIndex(entry); err != nil {
+		ErrorLog("Could Not Update Archive Index: " + err.Error())
+	}
+	archiveRetention()
+
+	return true
+}
+
+//Writes A Temporary File In The Same Directory And Renames It, So Readers Never See A Partial File
+func atomicWrite(path string, content []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err = f.Write(content); err == nil {
+		err = f.Sync()
+	}
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	if err == nil {
+		err = os.Chmod(f.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	return err
+}
+
+func appendArchiveIndex(entry ArchiveEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(archive.Directory, archiveIndex), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(line, '\n'))
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	return err
+}
+
+func readArchiveIndex() (entries []ArchiveEntry, err error) {
+	f, err := os.Open(filepath.Join(archive.Directory, archiveIndex))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry ArchiveEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			DebugLog(fmt.Sprintf("Skipping Invalid Archive Index Line: %s", err.Error()))
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+//Removes The Oldest Files While Any Retention Limit Is Exceeded
+func archiveRetention() {
+	if archiveMaxAge <= 0 && archive.MaxSize <= 0 && archive.MaxFiles <= 0 {
+		return
+	}
+	entries, err := readArchiveIndex()
+	if err != nil {
+		ErrorLog("Could Not Read Archive Index: " + err.Error())
+		return
+	}
+
+	var size int64
+	for _, entry := range entries {
+		size += entry.Size
+	}
+	removed := 0
+	for _, entry := range entries {
+		if !(archive.MaxFiles > 0 && len(entries)-removed > archive.MaxFiles) &&
+			!(archive.MaxSize > 0 && size > int64(archive.MaxSize)<<20) &&
+			!(archiveMaxAge > 0 && time.Since(entry.To) > archiveMaxAge) {
+			break
+		}
+		path := filepath.Join(archive.Directory, entry.File)
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			ErrorLog("Could Not Remove Archive File: " + err.Error())
+			break
+		}
+		//Removes The Period's Directory Once It Is Empty
+		if dir := filepath.Dir(path); dir != filepath.Clean(archive.Directory) {
+			os.Remove(dir)
+		}
+		DebugLog("Removed Archive File " + entry.File)
+		size -= entry.Size
+		removed++
+	}
+	if removed == 0 {
+		return
+	}
+
+	var index bytes.Buffer
+	for _, entry := range entries[removed:] {
+		line, _ := json.Marshal(entry)
+		index.Write(append(line, '\n'))
+	}
+	if err := atomicWrite(filepath.Join(archive.Directory, archiveIndex), index.Bytes()); err != nil {
+		ErrorLog("Could Not Update Archive Index: " + err.Error())
+	}
+}
+
+//Returns The Index Entries Of The Files Holding Data Between The Given Times, Oldest First
+func ArchiveFind(from, to time.Time) (found []ArchiveEntry, err error) {
+	archiveMutex.Lock()
+	entries, err := readArchiveIndex()
+	archiveMutex.Unlock()
+
+	for _, entry := range entries {
+		if !entry.To.Before(from) && !entry.From.After(to) {
+			found = append(found, entry)
+		}
+	}
+	return
+}
+
+//Reads The Data Of An Archive File, Given Its Path Relative To The Archive Directory
+func ArchiveRead(file string) (d []*Data, err error) {
+	f, err := os.Open(filepath.Join(archive.Directory, file))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(file, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	}
+	err = json.NewDecoder(r).Decode(&d)
+	return
+}
+
+//Hands The Data Of The Archive Files Holding Data Between The Given Times To The Write Function, Oldest First, Returning How Many Files Were Read
+func ArchiveReplay(from, to time.Time, write func(d []*Data)) (files int, err error) {
+	entries, err := ArchiveFind(from, to)
+	if err != nil {
+		return 0, fmt.Errorf("Could Not Read Archive Index: %v", err)
+	}
+	for _, entry := range entries {
+		d, err := ArchiveRead(entry.File)
+		if os.IsNotExist(err) {
+			//Removed By The Retention Limits Since The Index Was Read
+			DebugLog("Skipping Removed Archive File " + entry.File)
+			continue
+		} else if err != nil {
+			return files, fmt.Errorf("Could Not Read Archive File %s: %v", entry.File, err)
 		}
+		write(d)
+		files++
 	}
-	return false
+	return files, nil
 }
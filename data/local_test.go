@@ -0,0 +1,187 @@
+package data
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fccn/gofetch-snmp/config"
+)
+
+//Sets The Archive Up In A Temporary Directory, Restoring The Previous One Afterwards
+func testArchive(t *testing.T, conf config.Archive) string {
+	t.Helper()
+	previous, previousMaxAge := archive, archiveMaxAge
+	t.Cleanup(func() { archive, archiveMaxAge = previous, previousMaxAge })
+	conf.Directory = t.TempDir()
+	if err := ArchiveInit(conf); err != nil {
+		t.Fatal(err)
+	}
+	return conf.Directory
+}
+
+//A Device Collected At The Given Time, With A Tag Of The Given Size
+func archiveDevice(name string, timestamp time.Time, size int) *Data {
+	d := NewData()
+	d.AddTag("device_name", name)
+	d.AddTag("padding", strings.Repeat("x", size))
+	d.GetOrAddMetric("test_info").AddGauge("1", "test_percent", 1)
+	d.SetTimestamp(timestamp)
+	return &d
+}
+
+//Lists The Files Under The Directory, Relative To It, Without The Index
+func archiveFiles(t *testing.T, directory string) (files []string) {
+	t.Helper()
+	filepath.Walk(directory, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() && info.Name() != archiveIndex {
+			relative, _ := filepath.Rel(directory, path)
+			files = append(files, relative)
+		}
+		return nil
+	})
+	sort.Strings(files)
+	return
+}
+
+func TestArchiveAtomicWrite(t *testing.T) {
+	directory := testArchive(t, config.Archive{Gzip: true})
+	if !localWrite("kafka-", []*Data{archiveDevice("a", time.Now(), 0)}) {
+		t.Fatal("Expected The Write To Succeed")
+	}
+
+	//Only The Renamed File Is Left, Readable By Everyone
+	files := archiveFiles(t, directory)
+	if len(files) != 1 || !strings.HasPrefix(files[0], "kafka-") || !strings.HasSuffix(files[0], ".json.gz") {
+		t.Fatalf("Expected A Single Compressed File, Got %v", files)
+	}
+	info, err := os.Stat(filepath.Join(directory, files[0]))
+	if err != nil || info.Mode().Perm() != 0644 {
+		t.Errorf("Expected Mode 0644, Got %v", info)
+	}
+	d, err := ArchiveRead(files[0])
+	if err != nil || len(d) != 1 || d[0].GetTag("device_name") != "a" {
+		t.Errorf("Expected The Device Back, Got %v: %v", d, err)
+	}
+
+	//A Failed Write Leaves Nothing Behind
+	if err := atomicWrite(filepath.Join(directory, "missing", "file"), []byte("{}")); err != nil {
+		t.Fatal(err)
+	}
+	os.Chmod(directory, 0555)
+	defer os.Chmod(directory, 0755)
+	if err := atomicWrite(filepath.Join(directory, "denied"), []byte("{}")); err == nil && os.Getuid() != 0 {
+		t.Error("Expected The Write To Fail In A Read-Only Directory")
+	}
+	for _, file := range archiveFiles(t, directory) {
+		if strings.Contains(file, ".tmp") {
+			t.Errorf("Unexpected Temporary File %s", file)
+		}
+	}
+}
+
+func TestArchiveRotation(t *testing.T) {
+	for rotation, layout := range map[string]string{"hourly": "2006-01-02T15", "daily": "2006-01-02"} {
+		directory := testArchive(t, config.Archive{Rotation: rotation})
+		before := time.Now().UTC()
+		localWrite("", []*Data{archiveDevice("a", time.Now(), 0)})
+		after := time.Now().UTC()
+
+		//The Period Is That Of The Write, The Same Unless It Just Changed
+		files := archiveFiles(t, directory)
+		if len(files) != 1 {
+			t.Fatalf("%s - Expected A Single File, Got %v", rotation, files)
+		}
+		if dir := filepath.Dir(files[0]); dir != before.Format(layout) && dir != after.Format(layout) {
+			t.Errorf("%s - Expected The Directory %s, Got %s", rotation, before.Format(layout), dir)
+		}
+	}
+	if err := ArchiveInit(config.Archive{Directory: t.TempDir(), Rotation: "weekly"}); err == nil {
+		t.Error("Expected An Unknown Rotation To Fail")
+	}
+}
+
+func TestArchiveRetention(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		conf     config.Archive
+		written  []*Data
+		expected []string //Devices Left, Oldest First
+	}{
+		//The Oldest Files Beyond The Number Of Files
+		{config.Archive{MaxFiles: 2}, []*Data{
+			archiveDevice("a", now, 0), archiveDevice("b", now, 0), archiveDevice("c", now, 0),
+		}, []string{"b", "c"}},
+		//The Oldest Files Beyond The Size, Of 1 MB
+		{config.Archive{MaxSize: 1}, []*Data{
+			archiveDevice("a", now, 400<<10), archiveDevice("b", now, 400<<10), archiveDevice("c", now, 400<<10),
+		}, []string{"b", "c"}},
+		//The Files Whose Newest Data Is Older Than The Age
+		{config.Archive{MaxAge: "1h", Rotation: "daily"}, []*Data{
+			archiveDevice("a", now.Add(-2*time.Hour), 0), archiveDevice("b", now, 0), archiveDevice("c", now, 0),
+		}, []string{"b", "c"}},
+	}
+	for i, test := range tests {
+		directory := testArchive(t, test.conf)
+		for _, d := range test.written {
+			if !localWrite("", []*Data{d}) {
+				t.Fatalf("Case %d - Expected The Write To Succeed", i)
+			}
+		}
+
+		//The Index Only Lists The Files Left, Which Are The Only Ones On Disk
+		entries, err := readArchiveIndex()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var left []string
+		for _, entry := range entries {
+			d, err := ArchiveRead(entry.File)
+			if err != nil {
+				t.Fatalf("Case %d - %v", i, err)
+			}
+			left = append(left, d[0].GetTag("device_name"))
+		}
+		if strings.Join(left, ",") != strings.Join(test.expected, ",") {
+			t.Errorf("Case %d - Expected %v, Got %v", i, test.expected, left)
+		}
+		if files := archiveFiles(t, directory); len(files) != len(test.expected) {
+			t.Errorf("Case %d - Expected %d Files On Disk, Got %v", i, len(test.expected), files)
+		}
+	}
+}
+
+func TestArchiveReplay(t *testing.T) {
+	testArchive(t, config.Archive{Gzip: true, Rotation: "hourly"})
+	base := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	localWrite("kafka-", []*Data{archiveDevice("a", base, 0)})
+	localWrite("kafka-", []*Data{archiveDevice("b", base.Add(time.Hour), 0), archiveDevice("c", base.Add(2*time.Hour), 0)})
+	localWrite("kafka-", []*Data{archiveDevice("d", base.Add(4*time.Hour), 0)})
+
+	//The Files Whose Data Overlaps The Range Are Replayed, Oldest First
+	var replayed []string
+	files, err := ArchiveReplay(base.Add(90*time.Minute), base.Add(3*time.Hour), func(d []*Data) {
+		for _, dat := range d {
+			replayed = append(replayed, dat.GetTag("device_name"))
+		}
+	})
+	if err != nil || files != 1 || strings.Join(replayed, ",") != "b,c" {
+		t.Errorf("Expected b And c From 1 File, Got %v From %d: %v", replayed, files, err)
+	}
+
+	//Files Removed Since They Were Indexed Are Skipped
+	replayed = nil
+	entries, _ := readArchiveIndex()
+	os.Remove(filepath.Join(archive.Directory, entries[0].File))
+	files, err = ArchiveReplay(time.Time{}, base.Add(24*time.Hour), func(d []*Data) {
+		for _, dat := range d {
+			replayed = append(replayed, dat.GetTag("device_name"))
+		}
+	})
+	if err != nil || files != 2 || strings.Join(replayed, ",") != "b,c,d" {
+		t.Errorf("Expected b, c And d From 2 Files, Got %v From %d: %v", replayed, files, err)
+	}
+}
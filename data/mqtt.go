@@ -0,0 +1,217 @@
+package data
+
+//------------------------------------------------------------------------------------------
+//-----------------------------------------IMPORTS------------------------------------------
+//------------------------------------------------------------------------------------------
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	mqttclient "github.com/eclipse/paho.mqtt.golang"
+	"github.com/fccn/gofetch-snmp/config"
+	. "github.com/fccn/gofetch-snmp/log"
+)
+
+//------------------------------------------------------------------------------------------
+//-----------------------------------------STRUCTS------------------------------------------
+//------------------------------------------------------------------------------------------
+type mqtt struct {
+	conf    config.Mqtt
+	timeout time.Duration
+	client  mqttclient.Client
+	buffer  []mqttMessage //Messages Kept While Disconnected, Oldest First
+	mutex   sync.Mutex
+}
+
+type mqttMessage struct {
+	Topic   string
+	Payload []byte
+}
+
+//Payload Of Each Message, Holding The Fields Of One Index Of A Metric
+type mqttPayload struct {
+	Timestamp time.Time              `json:"timestamp"`
+	Tags      map[string]string      `json:"tags"`
+	Fields    map[string]interface{} `json:"fields"`
+}
+
+//------------------------------------------------------------------------------------------
+//----------------------------------------VARIABLES-----------------------------------------
+//------------------------------------------------------------------------------------------
+//Replaces The Characters That Would Change The Topic Levels Or Act As Wildcards
+var mqttSanitizer = strings.NewReplacer("/", "_", "+", "_", "#", "_")
+
+//------------------------------------------------------------------------------------------
+//----------------------------------------FUNCTIONS-----------------------------------------
+//------------------------------------------------------------------------------------------
+//Creates The MQTT Output, If Configured
+func MqttInit(conf *config.Mqtt) error {
+	if conf == nil {
+		return nil
+	}
+	m := &mqtt{conf: *conf, timeout: 10 * time.Second}
+
+	//Default Values
+	if m.conf.Topic == "" {
+		m.conf.Topic = "gofetch/{device_name}/{metric}/{index}"
+	}
+	if m.conf.ClientID == "" {
+		m.conf.ClientID = "gofetch-snmp"
+	}
+	if m.conf.Buffer <= 0 {
+		m.conf.Buffer = 10000
+	}
+	if m.conf.Qos > 2 {
+		return fmt.Errorf("Invalid MQTT QoS %d, Must Be 0, 1 Or 2", m.conf.Qos)
+	}
+	if m.conf.Timeout != nil {
+		if t, err := config.GetDuration(m.conf.Timeout); err == nil {
+			m.timeout = t
+		} else {
+			WarnLog(err.Error())
+		}
+	}
+	tlsConfig, err := newTlsConfig(m.conf.Tls)
+	if err != nil {
+		return fmt.Errorf("Could Not Load MQTT TLS Configurations: %v", err)
+	}
+
+	//Keeps Reconnecting In The Background, Sending The Buffered Messages Once Connected
+	options := mqttclient.NewClientOptions().
+		AddBroker(m.conf.Broker).
+		SetClientID(m.conf.ClientID).
+		SetUsername(m.conf.Username).
+		SetPassword(m.conf.Password).
+		SetConnectTimeout(m.timeout).
+		SetWriteTimeout(m.timeout).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetMaxReconnectInterval(time.Minute).
+		SetOnConnectHandler(func(mqttclient.Client) {
+			DebugLog("Connected To MQTT Broker")
+			go m.flush()
+		}).
+		SetConnectionLostHandler(func(_ mqttclient.Client, err error) {
+			Log(fmt.Sprintf("Lost Connection To MQTT Broker: %s", err.Error()))
+		})
+	if tlsConfig != nil {
+		options.SetTLSConfig(tlsConfig)
+	}
+
+	m.client = mqttclient.NewClient(options)
+	m.client.Connect()
+
+	AddOutput(m)
+	return nil
+}
+
+func (m *mqtt) Name() string {
+	return "MQTT"
+}
+
+//The Messages That Could Not Be Published Are Kept In Its Own Buffer
+func (m *mqtt) Retains() bool {
+	return true
+}
+
+//Publishes Each Index Of Each Metric, Buffering The Messages While Disconnected
+func (m *mqtt) Write(d []*Data) bool {
+	var messages []mqttMessage
+	for _, dat := range d {
+		messages = append(messages, m.messages(dat)...)
+	}
+
+	m.mutex.Lock()
+	m.buffer = append(m.buffer, messages...)
+	if dropped := len(m.buffer) - m.conf.Buffer; dropped > 0 {
+		Log(fmt.Sprintf("MQTT Buffer Is Full, Dropped %d Messages", dropped))
+		m.buffer = m.buffer[dropped:]
+	}
+	m.mutex.Unlock()
+
+	return m.flush()
+}
+
+//Publishes The Buffered Messages, Stopping At The First Failure, Returns False If Any Was Left
+func (m *mqtt) flush() bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if !m.client.IsConnectionOpen() {
+		if len(m.buffer) > 0 {
+			DebugLog(fmt.Sprintf("Not Connected To MQTT Broker, %d Messages Buffered", len(m.buffer)))
+		}
+		return len(m.buffer) == 0
+	}
+
+	sent := 0
+	for _, message := range m.buffer {
+		token := m.client.Publish(message.Topic, m.conf.Qos, m.conf.Retained, message.Payload)
+		if !token.WaitTimeout(m.timeout) {
+			Log(fmt.Sprintf("Timed Out Publishing To MQTT Broker, %d Messages Buffered", len(m.buffer)-sent))
+			break
+		}
+		if err := token.Error(); err != nil {
+			ErrorLog(fmt.Sprintf("Could Not Publish To MQTT Broker, %d Messages Buffered: %s", len(m.buffer)-sent, err.Error()))
+			break
+		}
+		sent++
+	}
+	m.buffer = m.buffer[sent:]
+
+	if sent > 0 {
+		DebugLog(fmt.Sprintf("%d Messages Were Published To MQTT Broker", sent))
+	}
+	return len(m.buffer) == 0
+}
+
+//Publishes What Is Buffered And Disconnects, Returns An Error If Any Message Was Left
+func (m *mqtt) Close() error {
+	m.flush()
+	m.client.Disconnect(uint(m.timeout / time.Millisecond))
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if len(m.buffer) > 0 {
+		return fmt.Errorf("%d Messages Were Not Published", len(m.buffer))
+	}
+	return nil
+}
+
+//Builds A Message For Each Index Of Each Metric Of A Device
+func (m *mqtt) messages(d *Data) (messages []mqttMessage) {
+	d.ForEachIndex(func(metric, index string, tags map[string]string, fields map[string]interface{}) {
+		payload, err := json.Marshal(mqttPayload{d.Timestamp, tags, fields})
+		if err != nil {
+			ErrorLog(fmt.Sprintf("Could Not Encode Metric %s: %s", metric, err.Error()))
+			return
+		}
+		messages = append(messages, mqttMessage{m.topic(metric, index, tags), payload})
+	})
+	return
+}
+
+//Fills The Topic Template, Leaving Out The Levels That End Up Empty
+func (m *mqtt) topic(metric, index string, tags map[string]string) string {
+	topic := FillTemplate(m.conf.Topic, func(key string) string {
+		switch key {
+		case "metric":
+			return mqttSanitizer.Replace(metric)
+		case "index":
+			return mqttSanitizer.Replace(index)
+		default:
+			return mqttSanitizer.Replace(tags[key])
+		}
+	})
+
+	var levels []string
+	for _, level := range strings.Split(topic, "/") {
+		if level != "" {
+			levels = append(levels, level)
+		}
+	}
+	return strings.Join(levels, "/")
+}
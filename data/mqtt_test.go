@@ -0,0 +1,200 @@
+package data
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eclipse/paho.mqtt.golang/packets"
+	"github.com/fccn/gofetch-snmp/config"
+)
+
+//Broker That Acknowledges Everything And Keeps What Is Published
+type mqttBroker struct {
+	listener  net.Listener
+	mutex     sync.Mutex
+	conns     []net.Conn
+	published []*packets.PublishPacket
+}
+
+func newMqttBroker(t *testing.T, address string) *mqttBroker {
+	t.Helper()
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &mqttBroker{listener: listener}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			b.mutex.Lock()
+			b.conns = append(b.conns, conn)
+			b.mutex.Unlock()
+			go b.serve(conn)
+		}
+	}()
+	return b
+}
+
+func (b *mqttBroker) serve(conn net.Conn) {
+	defer conn.Close()
+	for {
+		packet, err := packets.ReadPacket(conn)
+		if err != nil {
+			return
+		}
+		var reply packets.ControlPacket
+		switch p := packet.(type) {
+		case *packets.ConnectPacket:
+			reply = packets.NewControlPacket(packets.Connack)
+		case *packets.PublishPacket:
+			b.mutex.Lock()
+			b.published = append(b.published, p)
+			b.mutex.Unlock()
+			switch p.Qos {
+			case 1:
+				ack := packets.NewControlPacket(packets.Puback).(*packets.PubackPacket)
+				ack.MessageID = p.MessageID
+				reply = ack
+			case 2:
+				rec := packets.NewControlPacket(packets.Pubrec).(*packets.PubrecPacket)
+				rec.MessageID = p.MessageID
+				reply = rec
+			}
+		case *packets.PubrelPacket:
+			comp := packets.NewControlPacket(packets.Pubcomp).(*packets.PubcompPacket)
+			comp.MessageID = p.MessageID
+			reply = comp
+		case *packets.PingreqPacket:
+			reply = packets.NewControlPacket(packets.Pingresp)
+		case *packets.DisconnectPacket:
+			return
+		}
+		if reply != nil && reply.Write(conn) != nil {
+			return
+		}
+	}
+}
+
+//Stops Listening And Drops The Connections
+func (b *mqttBroker) stop() {
+	b.listener.Close()
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	for _, conn := range b.conns {
+		conn.Close()
+	}
+}
+
+func (b *mqttBroker) received() []*packets.PublishPacket {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return append([]*packets.PublishPacket{}, b.published...)
+}
+
+//Waits Until The Condition Holds, Failing After A While
+func waitFor(t *testing.T, what string, condition func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(10 * time.Second); !condition(); time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed Out Waiting For %s", what)
+		}
+	}
+}
+
+//A Device Whose Metric Has The Given Number Of Indexes
+func mqttDevice(indexes int) *Data {
+	d := NewData()
+	d.AddTag("device_name", "core/1")
+	m := d.GetOrAddMetric("test_info")
+	for i := 1; i <= indexes; i++ {
+		m.AddTag(fmt.Sprint(i), "test_name", fmt.Sprintf("eth%d", i))
+		m.AddGauge(fmt.Sprint(i), "test_percent", float64(i))
+	}
+	return &d
+}
+
+func topics(published []*packets.PublishPacket) []string {
+	var topics []string
+	for _, p := range published {
+		topics = append(topics, p.TopicName)
+	}
+	sort.Strings(topics)
+	return topics
+}
+
+func TestMqttPublish(t *testing.T) {
+	broker := newMqttBroker(t, "127.0.0.1:0")
+	defer func(registered []Output) { outputs = registered }(outputs)
+	err := MqttInit(&config.Mqtt{
+		Broker:   "tcp://" + broker.listener.Addr().String(),
+		Topic:    "test/{device_name}/{metric}/{missing}/{index}",
+		Qos:      1,
+		Retained: true,
+		Timeout:  "2s",
+		Buffer:   3,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := outputs[len(outputs)-1].(*mqtt)
+	defer m.Close()
+	waitFor(t, "The Connection", m.client.IsConnectionOpen)
+
+	//The Tags Are Sanitized And The Empty Levels Left Out
+	if !m.Write([]*Data{mqttDevice(2)}) {
+		t.Fatal("Expected The Write To Succeed")
+	}
+	published := broker.received()
+	if got := fmt.Sprint(topics(published)); got != "[test/core_1/test_info/1 test/core_1/test_info/2]" {
+		t.Errorf("Unexpected Topics %s", got)
+	}
+	for _, p := range published {
+		if p.Qos != 1 || !p.Retain {
+			t.Errorf("Expected QoS 1 And Retained, Got QoS %d And Retained %v", p.Qos, p.Retain)
+		}
+		var payload mqttPayload
+		if err := json.Unmarshal(p.Payload, &payload); err != nil {
+			t.Fatal(err)
+		}
+		if payload.Tags["device_name"] != "core/1" || payload.Tags["test_name"] == "" || payload.Fields["test_percent"] == nil {
+			t.Errorf("Unexpected Payload %s", p.Payload)
+		}
+	}
+
+	//While Disconnected The Messages Are Buffered, Dropping The Oldest Beyond The Buffer Size
+	address := broker.listener.Addr().String()
+	broker.stop()
+	waitFor(t, "The Disconnection", func() bool { return !m.client.IsConnectionOpen() })
+	if m.Write([]*Data{mqttDevice(2)}) || m.Write([]*Data{mqttDevice(2)}) {
+		t.Fatal("Expected The Writes To Fail While Disconnected")
+	}
+	m.mutex.Lock()
+	buffered := len(m.buffer)
+	m.mutex.Unlock()
+	if buffered != 3 {
+		t.Errorf("Expected 3 Buffered Messages, Got %d", buffered)
+	}
+
+	//Once Reconnected, The Buffered Messages Are Published
+	broker = newMqttBroker(t, address)
+	defer broker.stop()
+	waitFor(t, "The Buffered Messages", func() bool { return len(broker.received()) == 3 })
+	for _, p := range broker.received() {
+		if p.TopicName != "test/core_1/test_info/1" && p.TopicName != "test/core_1/test_info/2" {
+			t.Errorf("Unexpected Topic %s", p.TopicName)
+		}
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if len(m.buffer) != 0 {
+		t.Errorf("Expected An Empty Buffer, Got %d Messages", len(m.buffer))
+	}
+}
@@ -0,0 +1,336 @@
+package data
+
+//------------------------------------------------------------------------------------------
+//-----------------------------------------IMPORTS------------------------------------------
+//------------------------------------------------------------------------------------------
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fccn/gofetch-snmp/config"
+	. "github.com/fccn/gofetch-snmp/log"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+//------------------------------------------------------------------------------------------
+//-----------------------------------------STRUCTS------------------------------------------
+//------------------------------------------------------------------------------------------
+type otlp struct {
+	conf    config.Otlp
+	timeout time.Duration
+	start   uint64                            //Start Time Of The Cumulative Sums
+	client  colmetricspb.MetricsServiceClient //Used With The gRPC Protocol
+	url     string                            //Used With The HTTP Protocol
+	http    *http.Client                      //Used With The HTTP Protocol
+}
+
+//------------------------------------------------------------------------------------------
+//----------------------------------------FUNCTIONS-----------------------------------------
+//------------------------------------------------------------------------------------------
+//Creates The OpenTelemetry Collector Output, If Configured
+func OtlpInit(conf *config.Otlp) error {
+	if conf == nil {
+		return nil
+	}
+	o := &otlp{
+		conf:    *conf,
+		timeout: 10 * time.Second,
+		start:   uint64(time.Now().UnixNano()),
+	}
+	if conf.Timeout != nil {
+		if t, err := config.GetDuration(conf.Timeout); err == nil {
+			o.timeout = t
+		} else {
+			WarnLog(err.Error())
+		}
+	}
+	if o.conf.Batch <= 0 {
+		o.conf.Batch = 1000
+	}
+	tlsConfig, err := newTlsConfig(conf.Tls)
+	if err != nil {
+		return fmt.Errorf("Could Not Load OTLP TLS Configurations: %v", err)
+	}
+
+	switch strings.ToLower(conf.Protocol) {
+	case "http":
+		o.url = conf.Endpoint
+		if !strings.Contains(strings.TrimPrefix(strings.TrimPrefix(o.url, "http://"), "https://"), "/") {
+			o.url = strings.TrimSuffix(o.url, "/") + "/v1/metrics"
+		}
+		o.http = &http.Client{Timeout: o.timeout}
+		if tlsConfig != nil {
+			o.http.Transport = &http.Transport{Proxy: http.ProxyFromEnvironment, TLSClientConfig: tlsConfig}
+		}
+	case "grpc", "":
+		if tlsConfig == nil {
+			tlsConfig = &tls.Config{}
+		}
+		creds := credentials.NewTLS(tlsConfig)
+		if conf.Insecure {
+			creds = insecure.NewCredentials()
+		}
+		conn, err := grpc.NewClient(conf.Endpoint, grpc.WithTransportCredentials(creds))
+		if err != nil {
+			return fmt.Errorf("Could Not Initialize OTLP Client: %v", err)
+		}
+		o.client = colmetricspb.NewMetricsServiceClient(conn)
+	default:
+		return fmt.Errorf("Unknown OTLP Protocol \"%s\", Must Be \"grpc\" Or \"http\"", conf.Protocol)
+	}
+
+	AddOutput(o)
+	return nil
+}
+
+func (o *otlp) Name() string {
+	return "OTLP"
+}
+
+//Converts The Data To OTLP And Exports It In Batches Of At Most "Batch" Data Points
+func (o *otlp) Write(d []*Data) bool {
+	return len(o.WritePartial(d)) == 0
+}
+
+//Exports The Data In Batches, Returning Only The Data Of The Batches That Failed, So The Others Are Not Exported Twice
+func (o *otlp) WritePartial(d []*Data) (failed []*Data) {
+	var batch []*metricspb.ResourceMetrics
+	var batchData []*Data
+	var points int
+	export := func() {
+		if !o.export(batch) {
+			failed = append(failed, batchData...)
+		}
+		batch, batchData, points = nil, nil, 0
+	}
+	for _, dat := range d {
+		rm, n := o.resourceMetrics(dat)
+		if n == 0 {
+			continue
+		}
+		if points > 0 && points+n > o.conf.Batch {
+			export()
+		}
+		batch = append(batch, rm)
+		batchData = append(batchData, dat)
+		points += n
+	}
+	if points > 0 {
+		export()
+	}
+	return
+}
+
+//Builds The OTLP Resource Metrics Of A Device, Returning It And Its Number Of Data Points
+func (o *otlp) resourceMetrics(d *Data) (*metricspb.ResourceMetrics, int) {
+	timestamp := uint64(d.Timestamp.UnixNano())
+
+	//Each Field Becomes A Metric, With A Data Point For Each Index
+	metrics := map[string]*metricspb.Metric{}
+	var points int
+	for _, m := range d.Metrics {
+		for index, fields := range m.Fields {
+			attributes := otlpAttributes(m.Tags[index])
+			for field, value := range fields {
+				point := &metricspb.NumberDataPoint{
+					Attributes:   attributes,
+					TimeUnixNano: timestamp,
+				}
+				switch v := value.(type) {
+				case int:
+					point.Value = &metricspb.NumberDataPoint_AsInt{AsInt: int64(v)}
+				case int64:
+					point.Value = &metricspb.NumberDataPoint_AsInt{AsInt: v}
+				case uint:
+					point.Value = &metricspb.NumberDataPoint_AsInt{AsInt: int64(v)}
+				case uint32:
+					point.Value = &metricspb.NumberDataPoint_AsInt{AsInt: int64(v)}
+				case float32:
+					point.Value = &metricspb.NumberDataPoint_AsDouble{AsDouble: float64(v)}
+				case float64:
+					point.Value = &metricspb.NumberDataPoint_AsDouble{AsDouble: v}
+				case bool:
+					point.Value = &metricspb.NumberDataPoint_AsInt{AsInt: map[bool]int64{false: 0, true: 1}[v]}
+				default:
+					continue
+				}
+
+				metric := metrics[field]
+				if metric == nil {
+					//Counters Are Monotonic Sums, All Others Are Gauges, By The Schema Or Else By How They Were Added
+					metric = &metricspb.Metric{Name: field}
+					kind := m.GetKind(field)
+					if schema, ok := LookupField(field); ok {
+						kind, metric.Unit, metric.Description = schema.Kind, schema.Unit, schema.Description
+					}
+					if kind == Counter {
+						metric.Data = &metricspb.Metric_Sum{Sum: &metricspb.Sum{
+							AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE,
+							IsMonotonic:            true,
+						}}
+					} else {
+						metric.Data = &metricspb.Metric_Gauge{Gauge: &metricspb.Gauge{}}
+					}
+					metrics[field] = metric
+				}
+				switch data := metric.Data.(type) {
+				case *metricspb.Metric_Sum:
+					point.StartTimeUnixNano = o.start
+					data.Sum.DataPoints = append(data.Sum.DataPoints, point)
+				case *metricspb.Metric_Gauge:
+					data.Gauge.DataPoints = append(data.Gauge.DataPoints, point)
+				}
+				points++
+			}
+		}
+	}
+
+	names := []string{}
+	for name := range metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	scope := &metricspb.ScopeMetrics{Scope: &commonpb.InstrumentationScope{Name: "gofetch-snmp"}}
+	for _, name := range names {
+		scope.Metrics = append(scope.Metrics, metrics[name])
+	}
+	return &metricspb.ResourceMetrics{
+		Resource:     &resourcepb.Resource{Attributes: otlpAttributes(d.Tags)},
+		ScopeMetrics: []*metricspb.ScopeMetrics{scope},
+	}, points
+}
+
+//Sends A Request, Retrying With Exponential Backoff Or The Delay Asked By The Collector
+func (o *otlp) export(rm []*metricspb.ResourceMetrics) bool {
+	request := &colmetricspb.ExportMetricsServiceRequest{ResourceMetrics: rm}
+	for attempt := 0; ; attempt++ {
+		var response *colmetricspb.ExportMetricsServiceResponse
+		var retry bool
+		var delay time.Duration
+		var err error
+		if o.client != nil {
+			response, retry, delay, err = o.exportGrpc(request)
+		} else {
+			response, retry, delay, err = o.exportHttp(request)
+		}
+
+		if err == nil {
+			if p := response.GetPartialSuccess(); p != nil && p.RejectedDataPoints > 0 {
+				Log(fmt.Sprintf("OTLP Collector Rejected %d Data Points: %s", p.RejectedDataPoints, p.ErrorMessage))
+			}
+			DebugLog("Batch Was Written To OTLP Collector")
+			return true
+		}
+		if !retry || attempt >= o.conf.Retries {
+			ErrorLog(fmt.Sprintf("Could Not Export To OTLP Collector: %s", err.Error()))
+			return false
+		}
+		if delay == 0 {
+			delay = time.Duration(math.Min(math.Pow(2, float64(attempt)), 30)) * time.Second
+		}
+		DebugLog(fmt.Sprintf("Could Not Export To OTLP Collector, Retrying In %s: %s", delay, err.Error()))
+		time.Sleep(delay)
+	}
+}
+
+func (o *otlp) exportGrpc(request *colmetricspb.ExportMetricsServiceRequest) (response *colmetricspb.ExportMetricsServiceResponse, retry bool, delay time.Duration, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), o.timeout)
+	defer cancel()
+	if len(o.conf.Headers) > 0 {
+		ctx = metadata.NewOutgoingContext(ctx, metadata.New(o.conf.Headers))
+	}
+
+	if response, err = o.client.Export(ctx, request); err != nil {
+		s := status.Convert(err)
+		switch s.Code() {
+		case codes.Canceled, codes.DeadlineExceeded, codes.Aborted, codes.OutOfRange, codes.Unavailable, codes.DataLoss:
+			retry = true
+		case codes.ResourceExhausted:
+			//Backpressure, Only Retryable If The Collector Says When
+			for _, detail := range s.Details() {
+				if info, ok := detail.(*errdetails.RetryInfo); ok {
+					retry = true
+					delay = info.RetryDelay.AsDuration()
+				}
+			}
+		}
+	}
+	return
+}
+
+func (o *otlp) exportHttp(request *colmetricspb.ExportMetricsServiceRequest) (response *colmetricspb.ExportMetricsServiceResponse, retry bool, delay time.Duration, err error) {
+	var body []byte
+	if body, err = proto.Marshal(request); err != nil {
+		return
+	}
+	var req *http.Request
+	if req, err = http.NewRequest(http.MethodPost, o.url, bytes.NewReader(body)); err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	for k, v := range o.conf.Headers {
+		req.Header.Set(k, v)
+	}
+
+	var resp *http.Response
+	if resp, err = o.http.Do(req); err != nil {
+		return nil, true, 0, err
+	}
+	defer resp.Body.Close()
+	if body, err = ioutil.ReadAll(resp.Body); err != nil {
+		return nil, true, 0, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		response = &colmetricspb.ExportMetricsServiceResponse{}
+		err = proto.Unmarshal(body, response)
+		return
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		//Backpressure, Wait As Long As The Collector Asks To
+		retry = true
+		if seconds, e := strconv.Atoi(resp.Header.Get("Retry-After")); e == nil {
+			delay = time.Duration(seconds) * time.Second
+		}
+	}
+	err = fmt.Errorf("%s - %s", resp.Status, strings.TrimSpace(string(body)))
+	return
+}
+
+//Converts Tags To OTLP Attributes, Sorted By Key
+func otlpAttributes(tags map[string]string) (attributes []*commonpb.KeyValue) {
+	keys := []string{}
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		attributes = append(attributes, &commonpb.KeyValue{
+			Key:   k,
+			Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: tags[k]}},
+		})
+	}
+	return
+}
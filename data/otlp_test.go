@@ -0,0 +1,246 @@
+package data
+
+import (
+	"context"
+	"encoding/pem"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fccn/gofetch-snmp/config"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+//Collector That Keeps The Requests It Receives Over gRPC
+type metricsStub struct {
+	colmetricspb.UnimplementedMetricsServiceServer
+	mutex    sync.Mutex
+	requests []*colmetricspb.ExportMetricsServiceRequest
+	headers  []metadata.MD
+}
+
+func (s *metricsStub) Export(ctx context.Context, request *colmetricspb.ExportMetricsServiceRequest) (*colmetricspb.ExportMetricsServiceResponse, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	md, _ := metadata.FromIncomingContext(ctx)
+	s.requests = append(s.requests, request)
+	s.headers = append(s.headers, md)
+	return &colmetricspb.ExportMetricsServiceResponse{}, nil
+}
+
+//Initializes An OTLP Output Without Keeping It Among The Registered Outputs
+func newTestOtlp(t *testing.T, conf config.Otlp) *otlp {
+	t.Helper()
+	defer func(registered []Output) { outputs = registered }(outputs)
+	if err := OtlpInit(&conf); err != nil {
+		t.Fatal(err)
+	}
+	return outputs[len(outputs)-1].(*otlp)
+}
+
+//A Device With A Gauge And A Counter On One Index
+func otlpDevice(name string) *Data {
+	d := NewData()
+	d.AddTag("device_name", name)
+	d.AddTag("device_ip", "10.0.0.1")
+	m := d.GetOrAddMetric("test_info")
+	m.AddTag("1", "test_name", "eth0")
+	m.AddGauge("1", "test_percent", 50)
+	m.AddCounter("1", "test_bytes", 10)
+	d.SetTimestamp(time.Unix(100, 0))
+	return &d
+}
+
+func attributesString(attributes []*commonpb.KeyValue) string {
+	var s []string
+	for _, a := range attributes {
+		s = append(s, a.Key+"="+a.Value.GetStringValue())
+	}
+	return strings.Join(s, ",")
+}
+
+func TestOtlpGrpc(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stub := &metricsStub{}
+	server := grpc.NewServer()
+	colmetricspb.RegisterMetricsServiceServer(server, stub)
+	go server.Serve(listener)
+	defer server.Stop()
+
+	o := newTestOtlp(t, config.Otlp{
+		Endpoint: listener.Addr().String(),
+		Insecure: true,
+		Headers:  map[string]string{"x-token": "secret"},
+		Batch:    3,
+	})
+	//Two Points Per Device, So A Batch Of Three Holds One Device Each
+	if !o.Write([]*Data{otlpDevice("a"), otlpDevice("b"), otlpDevice("c")}) {
+		t.Fatal("Expected The Write To Succeed")
+	}
+
+	stub.mutex.Lock()
+	defer stub.mutex.Unlock()
+	if len(stub.requests) != 3 {
+		t.Fatalf("Expected 3 Requests, Got %d", len(stub.requests))
+	}
+	if token := stub.headers[0].Get("x-token"); len(token) != 1 || token[0] != "secret" {
+		t.Errorf("Expected The Header To Be Sent, Got %v", token)
+	}
+
+	rm := stub.requests[0].ResourceMetrics
+	if len(rm) != 1 {
+		t.Fatalf("Expected 1 Resource, Got %d", len(rm))
+	}
+	if got := attributesString(rm[0].Resource.Attributes); got != "device_ip=10.0.0.1,device_name=a" {
+		t.Errorf("Expected The Device Tags As Resource Attributes, Got %s", got)
+	}
+	metrics := rm[0].ScopeMetrics[0].Metrics
+	if len(metrics) != 2 || metrics[0].Name != "test_bytes" || metrics[1].Name != "test_percent" {
+		t.Fatalf("Expected The Metrics Sorted By Name, Got %v", metrics)
+	}
+
+	//Counters Are Cumulative Monotonic Sums
+	sum := metrics[0].GetSum()
+	if sum == nil || !sum.IsMonotonic || sum.AggregationTemporality != metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE {
+		t.Fatalf("Expected A Cumulative Monotonic Sum, Got %v", metrics[0])
+	}
+	point := sum.DataPoints[0]
+	if point.GetAsInt() != 10 || point.StartTimeUnixNano != o.start || point.TimeUnixNano != uint64(time.Unix(100, 0).UnixNano()) {
+		t.Errorf("Unexpected Sum Data Point %v", point)
+	}
+	if got := attributesString(point.Attributes); got != "test_name=eth0" {
+		t.Errorf("Expected The Index Tags As Data Point Attributes, Got %s", got)
+	}
+
+	//Everything Else Is A Gauge
+	gauge := metrics[1].GetGauge()
+	if gauge == nil || gauge.DataPoints[0].GetAsDouble() != 50 || gauge.DataPoints[0].StartTimeUnixNano != 0 {
+		t.Errorf("Expected A Gauge, Got %v", metrics[1])
+	}
+}
+
+func TestOtlpHttpRetry(t *testing.T) {
+	tests := []struct {
+		statuses []int //Answered In Turn, The Last One Repeated
+		retries  int
+		success  bool
+		requests int
+	}{
+		{[]int{http.StatusOK}, 0, true, 1},
+		{[]int{http.StatusTooManyRequests, http.StatusOK}, 1, true, 2},
+		{[]int{http.StatusServiceUnavailable, http.StatusOK}, 1, true, 2},
+		{[]int{http.StatusServiceUnavailable}, 0, false, 1},
+		{[]int{http.StatusBadRequest}, 3, false, 1},
+	}
+	for _, test := range tests {
+		var mutex sync.Mutex
+		var requests int
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			request := &colmetricspb.ExportMetricsServiceRequest{}
+			if r.URL.Path != "/v1/metrics" || r.Header.Get("Content-Type") != "application/x-protobuf" || proto.Unmarshal(body, request) != nil {
+				t.Errorf("Unexpected Request To %s", r.URL.Path)
+			}
+
+			mutex.Lock()
+			status := test.statuses[len(test.statuses)-1]
+			if requests < len(test.statuses) {
+				status = test.statuses[requests]
+			}
+			requests++
+			mutex.Unlock()
+
+			if status != http.StatusOK {
+				w.Header().Set("Retry-After", "0")
+				http.Error(w, "busy", status)
+				return
+			}
+			response, _ := proto.Marshal(&colmetricspb.ExportMetricsServiceResponse{})
+			w.Write(response)
+		}))
+
+		o := newTestOtlp(t, config.Otlp{Protocol: "http", Endpoint: server.URL, Retries: test.retries})
+		success := o.Write([]*Data{otlpDevice("a")})
+		server.Close()
+		if success != test.success || requests != test.requests {
+			t.Errorf("%v - Expected Success %v After %d Requests, Got %v After %d", test.statuses, test.success, test.requests, success, requests)
+		}
+	}
+}
+
+func TestOtlpHttpTls(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response, _ := proto.Marshal(&colmetricspb.ExportMetricsServiceResponse{})
+		w.Write(response)
+	}))
+	defer server.Close()
+
+	//The Server's Certificate Is Only Trusted Through The Configured CA
+	ca := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(ca, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if o := newTestOtlp(t, config.Otlp{Protocol: "http", Endpoint: server.URL}); o.Write([]*Data{otlpDevice("a")}) {
+		t.Error("Expected The Write To Fail Without The CA")
+	}
+	if o := newTestOtlp(t, config.Otlp{Protocol: "http", Endpoint: server.URL, Tls: &config.Tls{Ca: ca}}); !o.Write([]*Data{otlpDevice("a")}) {
+		t.Error("Expected The Write To Succeed With The CA")
+	}
+}
+
+func TestOtlpWritePartial(t *testing.T) {
+	//The Collector Refuses The Batch Of Device "b"
+	var mutex sync.Mutex
+	var exported []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		request := &colmetricspb.ExportMetricsServiceRequest{}
+		if err := proto.Unmarshal(body, request); err != nil {
+			t.Error(err)
+		}
+		var names []string
+		for _, rm := range request.ResourceMetrics {
+			for _, a := range rm.Resource.Attributes {
+				if a.Key == "device_name" {
+					names = append(names, a.Value.GetStringValue())
+				}
+			}
+		}
+		if strings.Join(names, ",") == "b" {
+			http.Error(w, "refused", http.StatusBadRequest)
+			return
+		}
+		mutex.Lock()
+		exported = append(exported, names...)
+		mutex.Unlock()
+		response, _ := proto.Marshal(&colmetricspb.ExportMetricsServiceResponse{})
+		w.Write(response)
+	}))
+	defer server.Close()
+
+	//Only The Data Of The Failed Batch Is Returned, To Be Retried Without Exporting The Others Again
+	o := newTestOtlp(t, config.Otlp{Protocol: "http", Endpoint: server.URL, Batch: 2})
+	a, b, c := otlpDevice("a"), otlpDevice("b"), otlpDevice("c")
+	failed := o.WritePartial([]*Data{a, b, c})
+	if len(failed) != 1 || failed[0] != b {
+		t.Errorf("Expected Only Device b To Fail, Got %d Devices", len(failed))
+	}
+	if got := strings.Join(exported, ","); got != "a,c" {
+		t.Errorf("Expected Devices a And c To Be Exported Once, Got %s", got)
+	}
+}
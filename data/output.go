@@ -0,0 +1,68 @@
+package data
+
+//------------------------------------------------------------------------------------------
+//-----------------------------------------IMPORTS------------------------------------------
+//------------------------------------------------------------------------------------------
+import (
+	"regexp"
+)
+
+//------------------------------------------------------------------------------------------
+//----------------------------------------INTERFACES----------------------------------------
+//------------------------------------------------------------------------------------------
+//Destination To Which The Collected Data Is Written, Besides The InfluxDB
+type Output interface {
+	//Name Used In Log Messages
+	Name() string
+	//Writes The Data Collected In One Cycle, Returns False If It Could Not Be Written
+	Write(d []*Data) bool
+}
+
+//Implemented By The Outputs That Keep The Data They Could Not Write, Which The Output Buffers Then Don't Retry
+type Retainer interface {
+	Output
+	//Returns True If The Output Keeps The Data It Could Not Write
+	Retains() bool
+}
+
+//Implemented By The Outputs That Write In Several Requests, Whose Buffers Then Only Retry The Data Of The Failed Ones
+type PartialWriter interface {
+	Output
+	//Writes The Data Collected In One Cycle, Returns The Data That Could Not Be Written
+	WritePartial(d []*Data) (failed []*Data)
+}
+
+//Implemented By The Outputs That Hold Connections Or Files, Closed When The Application Stops
+type Closer interface {
+	Output
+	//Releases What The Output Holds, Returns An Error If Data Was Lost
+	Close() error
+}
+
+//------------------------------------------------------------------------------------------
+//----------------------------------------VARIABLES-----------------------------------------
+//------------------------------------------------------------------------------------------
+var outputs []Output
+
+//Matches The Placeholders Of The Outputs' Templates, Such As "{device_name}"
+var placeholder = regexp.MustCompile(`\{([^{}]+)\}`)
+
+//------------------------------------------------------------------------------------------
+//----------------------------------------FUNCTIONS-----------------------------------------
+//------------------------------------------------------------------------------------------
+//Registers An Output To Be Written After Each Collection
+func AddOutput(o Output) {
+	outputs = append(outputs, o)
+}
+
+//Returns The Registered Outputs
+func GetOutputs() []Output {
+	return outputs
+}
+
+//Replaces Each Placeholder Of A Template With The Value Returned For Its Key
+func FillTemplate(template string, value func(key string) string) string {
+	return placeholder.ReplaceAllStringFunc(template, func(p string) string {
+		return value(p[1 : len(p)-1])
+	})
+}
@@ -0,0 +1,259 @@
+package data
+
+//------------------------------------------------------------------------------------------
+//-----------------------------------------IMPORTS------------------------------------------
+//------------------------------------------------------------------------------------------
+import (
+	"path"
+	"sort"
+	"sync"
+)
+
+//------------------------------------------------------------------------------------------
+//-----------------------------------------STRUCTS------------------------------------------
+//------------------------------------------------------------------------------------------
+//Metadata Of A Field, Used By The Outputs And The Documentation
+type FieldSchema struct {
+	Name        string `json:"name"` //Field Name, With "*" Where It Varies, Such As The Memory Pool Or The ACL
+	Kind        Kind   `json:"kind"`
+	Unit        string `json:"unit"` //UCUM Unit, Such As "By", "s", "Cel" Or "1", Annotations In Braces
+	Description string `json:"description"`
+}
+
+//------------------------------------------------------------------------------------------
+//----------------------------------------VARIABLES-----------------------------------------
+//------------------------------------------------------------------------------------------
+//Fields Emitted By The Devices And By The Application Itself
+var schema = []FieldSchema{
+	//Statistics
+	{"statistics_fetch_seconds", Gauge, "s", "Duration Of The Whole Collection"},
+	{"statistics_*_seconds", Gauge, "s", "Duration Of The Collection Of A Feature"},
+
+	//Uptime
+	{"uptime_seconds", Gauge, "s", "Time Since The SNMP Engine Started"},
+
+	//Interfaces
+	{"interface_in_discards", Counter, "{packet}", "Received Packets Discarded Without Errors"},
+	{"interface_out_discards", Counter, "{packet}", "Packets To Send Discarded Without Errors"},
+	{"interface_in_errors", Counter, "{packet}", "Received Packets With Errors"},
+	{"interface_out_errors", Counter, "{packet}", "Packets Not Sent Because Of Errors"},
+	{"interface_in_hc_bytes", Counter, "By", "Received Bytes"},
+	{"interface_out_hc_bytes", Counter, "By", "Sent Bytes"},
+	{"interface_in_ipv6_uni_bytes", Counter, "By", "Received IPv6 Unicast Bytes"},
+	{"interface_in_ipv6_multi_bytes", Counter, "By", "Received IPv6 Multicast Bytes"},
+	{"interface_out_ipv6_uni_bytes", Counter, "By", "Sent IPv6 Unicast Bytes"},
+	{"interface_out_ipv6_multi_bytes", Counter, "By", "Sent IPv6 Multicast Bytes"},
+	{"interface_oper_status", Gauge, "1", "Operational Status, 1 Is Up, 2 Is Down"},
+	{"interface_admin_status", Gauge, "1", "Administrative Status, 1 Is Up, 2 Is Down"},
+	{"interface_*_permit_bytes", Counter, "By", "Bytes Permitted By An ACL Or Before A Policy Class, By Direction"},
+	{"interface_*_drop_bytes", Counter, "By", "Bytes Dropped By An ACL Or A Policy Class, By Direction"},
+
+	//BGP
+	{"bgp_accepted_prefixes", Gauge, "{prefix}", "Prefixes Accepted From The Peer"},
+	{"bgp_denied_prefixes", Counter, "{prefix}", "Prefixes Denied From The Peer"},
+	{"bgp_limit_prefixes", Gauge, "{prefix}", "Maximum Prefixes Allowed From The Peer"},
+
+	//Cellular Modems
+	{"cell_modem_enabled", Gauge, "1", "Whether The Modem Is Enabled"},
+	{"cell_modem_connected", Gauge, "1", "Whether The Modem Is Connected"},
+	{"cell_modem_registered", Gauge, "1", "Whether The Modem Is Registered In The Network"},
+	{"cell_modem_tower", String, "1", "Tower The Modem Is Connected To"},
+	{"cell_modem_tech", String, "1", "Radio Technology In Use"},
+	{"cell_modem_3g_rssi", Gauge, "dBm", "3G Received Signal Strength"},
+	{"cell_modem_4g_rssi", Gauge, "dBm", "4G Received Signal Strength"},
+	{"cell_modem_session_time", Gauge, "s", "Duration Of The Current Session"},
+	{"cell_modem_sim_card", Gauge, "1", "SIM Card In Use"},
+	{"cell_modem_temperature", Gauge, "Cel", "Temperature Of The Modem"},
+	{"cell_modem_counter", Counter, "1", "Modem Counter"},
+	{"cell_signal_strength", Gauge, "1", "Received Signal Strength Indicator, From 0 To 31"},
+	{"cell_bit_error_rate", Gauge, "1", "Bit Error Rate Class, From 0 To 7"},
+
+	//NTP
+	{"ntp_stratum", Gauge, "1", "Stratum Of The NTP Server"},
+	{"ntp_clock_offset", Gauge, "ms", "Offset To The Reference Clock"},
+	{"ntp_frequency", Gauge, "Hz", "Frequency Of The Oscillator"},
+	{"ntp_pzf_correlation", Gauge, "%", "Correlation Of The PZF Receiver"},
+	{"ntp_field_strength", Gauge, "%", "Field Strength Of The Receiver"},
+	{"ntp_requests_current_day", Counter, "{request}", "Requests Received Today"},
+	{"ntp_requests_last_minute", Gauge, "{request}", "Requests Received In The Last Minute"},
+	{"ntp_clients", Gauge, "{client}", "Clients Seen Today"},
+
+	//Memory
+	{"memory_total", Gauge, "KiBy", "Total Real Memory"},
+	{"memory_free", Gauge, "KiBy", "Free Real Memory"},
+	{"memory_real_used_kbytes", Gauge, "KiBy", "Total Real Memory"},
+	{"memory_real_free_kbytes", Gauge, "KiBy", "Available Real Memory"},
+	{"memory_swap_used_kbytes", Gauge, "KiBy", "Total Swap"},
+	{"memory_swap_free_kbytes", Gauge, "KiBy", "Available Swap"},
+	{"memory_*_used_bytes", Gauge, "By", "Memory Used Of A Pool"},
+	{"memory_*_free_bytes", Gauge, "By", "Memory Free Of A Pool"},
+	{"memory_total_bytes", Gauge, "By", "Total Memory, The Same On Every Device Type"},
+	{"memory_used_bytes", Gauge, "By", "Used Memory, The Same On Every Device Type"},
+
+	//Storage
+	{"storage_size_bytes", Gauge, "By", "Size Of A Storage, Such As The Physical Memory, The Swap Or A Filesystem"},
+	{"storage_used_bytes", Gauge, "By", "Used Space Of A Storage"},
+
+	//CPU
+	{"cpu_user", Counter, "{tick}", "Time Spent In User Mode"},
+	{"cpu_system", Counter, "{tick}", "Time Spent In System Mode"},
+	{"cpu_idle", Counter, "{tick}", "Time Spent Idle"},
+	{"cpu_wait", Counter, "{tick}", "Time Spent Waiting For Input/Output"},
+	{"cpu_kernel", Counter, "{tick}", "Time Spent In The Kernel"},
+	{"cpu_one_minute_percent", Gauge, "%", "Utilisation In The Last Minute"},
+	{"cpu_five_minutes_percent", Gauge, "%", "Utilisation In The Last Five Minutes"},
+	{"cpu_load_percent", Gauge, "%", "Load Of A Processor In The Last Minute"},
+	{"cpu_processes", Gauge, "{process}", "Processes Loaded Or Running"},
+	{"cpu_utilisation_percent", Gauge, "%", "Utilisation, The Same On Every Device Type"},
+
+	//Sensors
+	{"sensor_value_celsius", Gauge, "Cel", "Temperature"},
+	{"sensor_value_volts", Gauge, "V", "Voltage"},
+	{"sensor_value_amperes", Gauge, "A", "Current"},
+	{"sensor_value_watts", Gauge, "W", "Power"},
+	{"sensor_value_hertz", Gauge, "Hz", "Frequency"},
+	{"sensor_value_percent_rh", Gauge, "%", "Relative Humidity"},
+	{"sensor_value_rpm", Gauge, "{rotation}/min", "Fan Speed"},
+	{"sensor_value_cmm", Gauge, "m3/min", "Air Flow"},
+	{"sensor_value_dbm", Gauge, "dBm", "Optical Power"},
+	{"sensor_value_db", Gauge, "dB", "Power Ratio"},
+	{"sensor_value_bool", Bool, "1", "Sensor Reading That Is True Or False"},
+	{"sensor_value_special_enum", Gauge, "1", "Sensor Reading Whose Values Are Specific To The Sensor"},
+	{"sensor_value_", Gauge, "1", "Sensor Reading Of Another Or Unknown Type"},
+	{"sensor_thresh_celsius", Gauge, "Cel", "Temperature Threshold"},
+	{"sensor_thresh_low_celsius", Gauge, "Cel", "Low Temperature Threshold"},
+	{"sensor_thresh_high_celsius", Gauge, "Cel", "High Temperature Threshold"},
+	{"sensor_thresh_low_volts", Gauge, "V", "Low Voltage Threshold"},
+	{"sensor_thresh_high_volts", Gauge, "V", "High Voltage Threshold"},
+	{"sensor_thresh_low_amperes", Gauge, "A", "Low Current Threshold"},
+	{"sensor_thresh_high_amperes", Gauge, "A", "High Current Threshold"},
+	{"sensor_state", Gauge, "1", "State Of The Sensor, 1 Is Normal"},
+	{"sensor_status", Gauge, "1", "Status Of The Sensor"},
+	{"sensor_error", Gauge, "1", "Error Of The Sensor"},
+	{"sensor_input_status_bool", Bool, "1", "Whether The Power Input Is Up"},
+	{"sensor_output_status_bool", Bool, "1", "Whether The Power Output Is Up"},
+
+	//Optical Transceivers
+	{"optics_rx_power_dbm", Gauge, "dBm", "Received Optical Power"},
+	{"optics_tx_power_dbm", Gauge, "dBm", "Transmitted Optical Power"},
+	{"optics_bias_current_milliamperes", Gauge, "mA", "Laser Bias Current"},
+	{"optics_temperature_celsius", Gauge, "Cel", "Temperature Of The Transceiver Or Of The Laser"},
+	{"optics_voltage_volts", Gauge, "V", "Supply Voltage Of The Transceiver"},
+
+	//Inventory
+	{"inventory_descr", String, "1", "Description Of The Physical Entity"},
+	{"inventory_contained_in", Gauge, "1", "Index Of The Physical Entity That Contains It, 0 If None"},
+	{"inventory_position", Gauge, "1", "Position Among The Entities Of The Same Class In Its Container, -1 If Unknown"},
+	{"inventory_hardware_rev", String, "1", "Hardware Revision"},
+	{"inventory_firmware_rev", String, "1", "Firmware Revision"},
+	{"inventory_software_rev", String, "1", "Software Revision"},
+	{"inventory_serial", String, "1", "Serial Number"},
+	{"inventory_model", String, "1", "Model Name, Such As The Part Number"},
+	{"inventory_fru", Bool, "1", "Whether It Is A Field Replaceable Unit"},
+
+	//Buffers Of The Outputs
+	{"queue_devices", Gauge, "{device}", "Devices Waiting To Be Written"},
+	{"queue_points", Gauge, "{point}", "Points Waiting To Be Written"},
+	{"wal_files", Gauge, "{file}", "Write-Ahead Log Files"},
+	{"wal_bytes", Gauge, "By", "Size Of The Write-Ahead Log Files"},
+	{"written_points", Counter, "{point}", "Points Written"},
+	{"dropped_points", Counter, "{point}", "Points Dropped"},
+	{"spilled_points", Counter, "{point}", "Points Spilled To The Write-Ahead Log"},
+	{"failed_writes", Counter, "{write}", "Writes That Failed"},
+	{"backoff_seconds", Gauge, "s", "Time Until The Next Write Attempt"},
+
+	//Events
+	{"event_message", String, "1", "Description Of The Event"},
+	{"*_before", Gauge, "1", "Value Of A Field Before The Event"},
+	{"inventory_serial_before", String, "1", "Serial Number Of The Part That Was Replaced"},
+	{"inventory_model_before", String, "1", "Model Name Of The Part That Was Replaced"},
+}
+
+//Schema By Field Name, Built When First Used
+var schemaByName map[string]FieldSchema
+var schemaMutex sync.Mutex
+
+//------------------------------------------------------------------------------------------
+//----------------------------------------FUNCTIONS-----------------------------------------
+//------------------------------------------------------------------------------------------
+//Registers More Fields, Such As Those Of A Detection Or Of A New Driver, Replacing The Ones With The Same Name
+func RegisterFields(fields ...FieldSchema) {
+	schemaMutex.Lock()
+	defer schemaMutex.Unlock()
+	for _, field := range fields {
+		for i := range schema {
+			if schema[i].Name == field.Name {
+				schema = append(schema[:i], schema[i+1:]...)
+				break
+			}
+		}
+		schema = append(schema, field)
+	}
+	schemaByName = nil
+}
+
+//Gets The Metadata Of A Field, By Its Exact Name Or By The Most Specific Name With "*" That Matches It
+func LookupField(name string) (field FieldSchema, ok bool) {
+	schemaMutex.Lock()
+	defer schemaMutex.Unlock()
+	if schemaByName == nil {
+		schemaByName = map[string]FieldSchema{}
+		for _, f := range schema {
+			schemaByName[f.Name] = f
+		}
+	}
+	if field, ok = schemaByName[name]; ok {
+		return
+	}
+	for _, f := range schema {
+		if matched, _ := path.Match(f.Name, name); matched && (!ok || len(f.Name) > len(field.Name)) {
+			field, ok = f, true
+		}
+	}
+	return
+}
+
+//Gets The Metadata Of All The Fields, Sorted By Name
+func Schema() []FieldSchema {
+	schemaMutex.Lock()
+	defer schemaMutex.Unlock()
+	fields := append([]FieldSchema{}, schema...)
+	sort.Slice(fields, func(i, j int) bool { return fields[i].Name < fields[j].Name })
+	return fields
+}
+
+//Gets The Fields Of The Data That Are Not In The Schema, As "<metric>.<field>", Sorted
+func (d *Data) UnregisteredFields() (names []string) {
+	defer rlock(d.mutex)()
+	found := map[string]bool{}
+	for metric, m := range d.Metrics {
+		for _, fields := range m.Fields {
+			for name := range fields {
+				if _, ok := LookupField(name); !ok && !found[metric+"."+name] {
+					found[metric+"."+name] = true
+					names = append(names, metric+"."+name)
+				}
+			}
+		}
+	}
+	sort.Strings(names)
+	return
+}
+
+//Writes The Kind By Its Name In JSON
+func (k Kind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+//Names Of The Kinds, As Written In The Documentation
+func (k Kind) String() string {
+	switch k {
+	case Counter:
+		return "counter"
+	case Bool:
+		return "bool"
+	case String:
+		return "string"
+	}
+	return "gauge"
+}
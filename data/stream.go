@@ -0,0 +1,146 @@
+package data
+
+//------------------------------------------------------------------------------------------
+//-----------------------------------------IMPORTS------------------------------------------
+//------------------------------------------------------------------------------------------
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/fccn/gofetch-snmp/config"
+	. "github.com/fccn/gofetch-snmp/log"
+)
+
+//------------------------------------------------------------------------------------------
+//-----------------------------------------STRUCTS------------------------------------------
+//------------------------------------------------------------------------------------------
+type stream struct {
+	conf config.Stream
+	out  io.Writer //Stdout Or The Current File
+	file *os.File  //Nil When Writing To Stdout
+	size int64     //Size Of The Current File
+}
+
+//------------------------------------------------------------------------------------------
+//----------------------------------------FUNCTIONS-----------------------------------------
+//------------------------------------------------------------------------------------------
+//Creates The Stream Output, If Configured
+func StreamInit(conf *config.Stream) error {
+	if conf == nil {
+		return nil
+	}
+	s := &stream{conf: *conf}
+
+	//Default Values
+	switch strings.ToLower(s.conf.Format) {
+	case "":
+		s.conf.Format = "line"
+	case "line", "json":
+		s.conf.Format = strings.ToLower(s.conf.Format)
+	default:
+		return fmt.Errorf("Unknown Stream Format \"%s\", Must Be \"line\" Or \"json\"", conf.Format)
+	}
+	if s.conf.Keep <= 0 {
+		s.conf.Keep = 5
+	}
+
+	if IsStdout(s.conf.Path) {
+		s.out = os.Stdout
+	} else if !s.open() {
+		return fmt.Errorf("Could Not Open Stream File %s", s.conf.Path)
+	}
+
+	AddOutput(s)
+	return nil
+}
+
+//Checks If A Stream Path Refers To The Standard Output
+func IsStdout(path string) bool {
+	return path == "" || path == "-"
+}
+
+func (s *stream) Name() string {
+	return "Stream"
+}
+
+//Writes Each Point In A Line, Rotating The File Before It Grows Past The Maximum Size
+func (s *stream) Write(d []*Data) bool {
+	var buffer bytes.Buffer
+	for _, dat := range d {
+		for _, pt := range dat.InfluxPoints() {
+			if s.conf.Format == "line" {
+				buffer.WriteString(pt.String() + "\n")
+			} else if line, err := json.Marshal(NewJsonPoint(pt)); err == nil {
+				buffer.Write(append(line, '\n'))
+			} else {
+				ErrorLog(fmt.Sprintf("Could Not Encode Point %s: %s", pt.Name(), err.Error()))
+			}
+		}
+	}
+	if buffer.Len() == 0 {
+		return true
+	}
+
+	if s.file != nil && s.conf.MaxSize > 0 && s.size > 0 && s.size+int64(buffer.Len()) > int64(s.conf.MaxSize)<<20 {
+		s.rotate()
+	}
+	if s.out == nil && !s.open() {
+		return false
+	}
+
+	n, err := s.out.Write(buffer.Bytes())
+	s.size += int64(n)
+	if err != nil {
+		ErrorLog(fmt.Sprintf("Could Not Write To Stream: %s", err.Error()))
+		return false
+	}
+	return true
+}
+
+//Closes The File, If Writing To One
+func (s *stream) Close() error {
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Sync()
+	if e := s.file.Close(); err == nil {
+		err = e
+	}
+	s.file, s.out = nil, nil
+	return err
+}
+
+//Opens The File For Appending
+func (s *stream) open() bool {
+	f, err := os.OpenFile(s.conf.Path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		ErrorLog(fmt.Sprintf("Could Not Open Stream File: %s", err.Error()))
+		return false
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		ErrorLog(fmt.Sprintf("Could Not Open Stream File: %s", err.Error()))
+		return false
+	}
+	s.file, s.out, s.size = f, f, info.Size()
+	return true
+}
+
+//Closes The File And Shifts The Rotated Files, "path" Becoming "path.1" And The Oldest Being Removed, The File Is Reopened On Write
+func (s *stream) rotate() {
+	s.file.Close()
+	s.file, s.out = nil, nil
+
+	os.Remove(fmt.Sprintf("%s.%d", s.conf.Path, s.conf.Keep))
+	for i := s.conf.Keep - 1; i > 0; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", s.conf.Path, i), fmt.Sprintf("%s.%d", s.conf.Path, i+1))
+	}
+	if err := os.Rename(s.conf.Path, s.conf.Path+".1"); err != nil {
+		ErrorLog(fmt.Sprintf("Could Not Rotate Stream File: %s", err.Error()))
+	}
+}
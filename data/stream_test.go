@@ -0,0 +1,141 @@
+package data
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fccn/gofetch-snmp/config"
+)
+
+//Initializes A Stream Output Without Keeping It Among The Registered Outputs
+func newTestStream(t *testing.T, conf config.Stream) *stream {
+	t.Helper()
+	defer func(registered []Output) { outputs = registered }(outputs)
+	if err := StreamInit(&conf); err != nil {
+		t.Fatal(err)
+	}
+	s := outputs[len(outputs)-1].(*stream)
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+//A Device With A Point Per Kilobyte Of The Given Size, Or A Single One
+func streamDevice(name string, size int) *Data {
+	d := NewData()
+	d.AddTag("device_name", name)
+	m := d.GetOrAddMetric("test_info")
+	for i := 0; i == 0 || i < size>>10; i++ {
+		m.AddTag(fmt.Sprint(i), "padding", strings.Repeat("x", 1000))
+		m.AddGauge(fmt.Sprint(i), "test_percent", 50)
+	}
+	d.SetTimestamp(time.Unix(100, 0))
+	return &d
+}
+
+//Names Of The Devices Written To A File, In Order, Empty If The File Does Not Exist
+func streamDevices(t *testing.T, path string) string {
+	t.Helper()
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return ""
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, line := range strings.Split(strings.TrimSpace(string(content)), "\n") {
+		for _, part := range strings.Split(strings.SplitN(line, " ", 2)[0], ",") {
+			name := strings.TrimPrefix(part, "device_name=")
+			if name != part && (len(names) == 0 || names[len(names)-1] != name) {
+				names = append(names, name)
+			}
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+func TestStreamRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gofetch.lp")
+
+	//A File Left By A Previous Run Counts Towards The Maximum Size
+	if err := os.WriteFile(path, []byte(strings.Repeat("#", 700<<10)+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s := newTestStream(t, config.Stream{Path: path, MaxSize: 1, Keep: 2})
+
+	//Each Write Of About 600 KB Rolls The File Of 1 MB Over, Keeping Two Rotated Files
+	expected := []map[string]string{
+		{"": "w1", ".1": "", ".2": ""},
+		{"": "w2", ".1": "w1", ".2": ""},
+		{"": "w3", ".1": "w2", ".2": "w1"},
+		{"": "w4", ".1": "w3", ".2": "w2"},
+	}
+	for i, files := range expected {
+		if !s.Write([]*Data{streamDevice(fmt.Sprintf("w%d", i+1), 600<<10)}) {
+			t.Fatalf("Write %d - Expected The Write To Succeed", i+1)
+		}
+		for suffix, devices := range files {
+			if got := streamDevices(t, path+suffix); got != devices {
+				t.Errorf("Write %d - Expected %q In %s, Got %q", i+1, devices, "gofetch.lp"+suffix, got)
+			}
+		}
+		if info, err := os.Stat(path); err != nil || info.Size() > 1<<20 {
+			t.Errorf("Write %d - Expected The File Below 1 MB, Got %v", i+1, info.Size())
+		}
+		if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+			t.Errorf("Write %d - Expected No More Than Two Rotated Files", i+1)
+		}
+	}
+
+	//Small Writes Share The File
+	if !s.Write([]*Data{streamDevice("w5", 0)}) || streamDevices(t, path) != "w4,w5" {
+		t.Errorf("Expected The Small Write Appended, Got %q", streamDevices(t, path))
+	}
+}
+
+func TestStreamFormats(t *testing.T) {
+	directory := t.TempDir()
+
+	line := newTestStream(t, config.Stream{Path: filepath.Join(directory, "line")})
+	if !line.Write([]*Data{streamDevice("a", 0), streamDevice("b", 0)}) {
+		t.Fatal("Expected The Write To Succeed")
+	}
+	content, _ := os.ReadFile(filepath.Join(directory, "line"))
+	lines := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected A Line Per Point, Got %q", content)
+	}
+	for i, l := range lines {
+		if !strings.HasPrefix(l, "test_info,device_name="+[]string{"a", "b"}[i]) || !strings.HasSuffix(l, " test_percent=50 100000000000") {
+			t.Errorf("Unexpected Line %q", l)
+		}
+	}
+
+	ndjson := newTestStream(t, config.Stream{Path: filepath.Join(directory, "json"), Format: "JSON"})
+	if !ndjson.Write([]*Data{streamDevice("a", 0), streamDevice("b", 0)}) {
+		t.Fatal("Expected The Write To Succeed")
+	}
+	content, _ = os.ReadFile(filepath.Join(directory, "json"))
+	lines = strings.Split(strings.TrimSpace(string(content)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected A JSON Object Per Line, Got %q", content)
+	}
+	for i, l := range lines {
+		var point JsonPoint
+		if err := json.Unmarshal([]byte(l), &point); err != nil {
+			t.Fatal(err)
+		}
+		name := []string{"a", "b"}[i]
+		if point.Measurement != "test_info" || point.Tags["device_name"] != name || point.Fields["test_percent"] != float64(50) || !point.Timestamp.Equal(time.Unix(100, 0)) {
+			t.Errorf("Unexpected Point %+v", point)
+		}
+	}
+
+	if err := StreamInit(&config.Stream{Path: filepath.Join(directory, "csv"), Format: "csv"}); err == nil {
+		t.Error("Expected An Unknown Format To Fail")
+	}
+}
@@ -0,0 +1,71 @@
+package data
+
+//------------------------------------------------------------------------------------------
+//-----------------------------------------IMPORTS------------------------------------------
+//------------------------------------------------------------------------------------------
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/fccn/gofetch-snmp/config"
+	. "github.com/fccn/gofetch-snmp/log"
+)
+
+//------------------------------------------------------------------------------------------
+//-----------------------------------------STRUCTS------------------------------------------
+//------------------------------------------------------------------------------------------
+type Webhook struct {
+	conf   config.Webhook
+	client *http.Client
+}
+
+//------------------------------------------------------------------------------------------
+//----------------------------------------FUNCTIONS-----------------------------------------
+//------------------------------------------------------------------------------------------
+//Creates A Webhook, Returns Nil If It Is Not Configured
+func NewWebhook(conf *config.Webhook) *Webhook {
+	if conf == nil || conf.Url == "" {
+		return nil
+	}
+	w := &Webhook{conf: *conf, client: &http.Client{Timeout: 10 * time.Second}}
+	if conf.Timeout != nil {
+		if t, err := config.GetDuration(conf.Timeout); err == nil {
+			w.client.Timeout = t
+		} else {
+			WarnLog(err.Error())
+		}
+	}
+	return w
+}
+
+//Posts The Payload As JSON
+func (w *Webhook) Post(payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, w.conf.Url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range w.conf.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("%s - %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package devices
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	g "github.com/soniah/gosnmp"
+)
+
+//Object Served By The Agent
+type object struct {
+	Oid   string
+	Type  g.Asn1BER
+	Value interface{}
+}
+
+//SNMPv2c Agent That Answers Get, GetNext And GetBulk From Objects Read From snmprec Files
+type agent struct {
+	conn    net.PacketConn
+	objects []object
+}
+
+//Starts An Agent On A Random Local Port, Serving The Objects Of The Given Files In testdata
+func newAgent(t *testing.T, files ...string) *agent {
+	a := &agent{}
+	byOid := map[string]object{}
+	for _, file := range files {
+		for _, o := range readSnmprec(t, "testdata/"+file+".snmprec") {
+			byOid[o.Oid] = o
+		}
+	}
+	for _, o := range byOid {
+		a.objects = append(a.objects, o)
+	}
+	sort.Slice(a.objects, func(i, j int) bool { return oidLess(a.objects[i].Oid, a.objects[j].Oid) })
+
+	var err error
+	if a.conn, err = net.ListenPacket("udp", "127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { a.conn.Close() })
+	go a.serve()
+	return a
+}
+
+//Port The Agent Listens On
+func (a *agent) Port() uint16 {
+	return uint16(a.conn.LocalAddr().(*net.UDPAddr).Port)
+}
+
+//Reads An snmprec File, With An "oid|type|value" Object Per Line
+func readSnmprec(t *testing.T, name string) (objects []object) {
+	file, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		split := strings.SplitN(scanner.Text(), "|", 3)
+		if len(split) != 3 {
+			continue
+		}
+		o := object{Oid: "." + strings.TrimPrefix(split[0], ".")}
+		number, _ := strconv.ParseInt(split[2], 10, 64)
+		switch split[1] {
+		case "2":
+			o.Type, o.Value = g.Integer, int(number)
+		case "4":
+			o.Type, o.Value = g.OctetString, []byte(split[2])
+		case "6":
+			o.Type, o.Value = g.ObjectIdentifier, split[2]
+		case "65":
+			o.Type, o.Value = g.Counter32, uint32(number)
+		case "66":
+			o.Type, o.Value = g.Gauge32, uint32(number)
+		case "67":
+			o.Type, o.Value = g.TimeTicks, uint32(number)
+		case "70":
+			value, _ := strconv.ParseUint(split[2], 10, 64)
+			o.Type, o.Value = g.Counter64, value
+		default:
+			t.Fatalf("%s - Unknown Type %s", name, split[1])
+		}
+		objects = append(objects, o)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return
+}
+
+//Compares Two OIDs By Their Numbers
+func oidLess(a, b string) bool {
+	as, bs := strings.Split(strings.Trim(a, "."), "."), strings.Split(strings.Trim(b, "."), ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		x, _ := strconv.Atoi(as[i])
+		y, _ := strconv.Atoi(bs[i])
+		if x != y {
+			return x < y
+		}
+	}
+	return len(as) < len(bs)
+}
+
+//Answers The Requests Until The Connection Is Closed
+func (a *agent) serve() {
+	decoder := &g.GoSNMP{Version: g.Version2c, Community: "public"}
+	buf := make([]byte, 65535)
+	for {
+		n, addr, err := a.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		request, err := decoder.SnmpDecodePacket(buf[:n])
+		if err != nil {
+			continue
+		}
+
+		var variables []g.SnmpPDU
+		switch request.PDUType {
+		case g.GetRequest:
+			for _, v := range request.Variables {
+				variables = append(variables, a.get(v.Name))
+			}
+		case g.GetNextRequest, g.GetBulkRequest:
+			repetitions := 1
+			if request.PDUType == g.GetBulkRequest {
+				//Some Versions Of The Library Don't Decode The Repetitions
+				if repetitions = int(request.MaxRepetitions); repetitions == 0 {
+					repetitions = 10
+				}
+			}
+			oid := request.Variables[0].Name
+			for i := 0; i < repetitions; i++ {
+				v := a.next(oid)
+				variables = append(variables, v)
+				if v.Type == g.EndOfMibView {
+					break
+				}
+				oid = v.Name
+			}
+		default:
+			continue
+		}
+
+		response := &g.SnmpPacket{
+			Version:   g.Version2c,
+			Community: request.Community,
+			PDUType:   g.GetResponse,
+			RequestID: request.RequestID,
+			Variables: variables,
+		}
+		if out, err := response.MarshalMsg(); err == nil {
+			a.conn.WriteTo(out, addr)
+		}
+	}
+}
+
+//Gets The Object With The Given OID
+func (a *agent) get(oid string) g.SnmpPDU {
+	oid = "." + strings.TrimPrefix(oid, ".")
+	for _, o := range a.objects {
+		if o.Oid == oid {
+			return g.SnmpPDU{Name: o.Oid, Type: o.Type, Value: o.Value}
+		}
+	}
+	return g.SnmpPDU{Name: oid, Type: g.NoSuchObject}
+}
+
+//Gets The First Object After The Given OID
+func (a *agent) next(oid string) g.SnmpPDU {
+	i := sort.Search(len(a.objects), func(i int) bool { return oidLess(oid, a.objects[i].Oid) })
+	if i == len(a.objects) {
+		return g.SnmpPDU{Name: oid, Type: g.EndOfMibView}
+	}
+	return g.SnmpPDU{Name: a.objects[i].Oid, Type: a.objects[i].Type, Value: a.objects[i].Value}
+}
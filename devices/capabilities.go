@@ -0,0 +1,324 @@
+package devices
+
+//------------------------------------------------------------------------------------------
+//-----------------------------------------IMPORTS------------------------------------------
+//------------------------------------------------------------------------------------------
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	. "github.com/fccn/gofetch-snmp/log"
+	"github.com/fccn/gofetch-snmp/snmp"
+)
+
+//------------------------------------------------------------------------------------------
+//-----------------------------------------STRUCTS------------------------------------------
+//------------------------------------------------------------------------------------------
+//Struct That Declares A Feature Implemented By A Driver And The Root OIDs Used To Probe It
+type capability struct {
+	Feature string
+	Oids    []string //The Feature Is Implemented If Any Of Them Answers
+}
+
+//Struct That Defines How To Build A Specific Device And What It Is Able To Collect
+type driver struct {
+	New          func(d *device) Device //Creates The Specific Device
+	Bulk         bool                   //Indicates If Device Can Use BulkWalk
+	Capabilities []capability           //Features Implemented By The Driver
+}
+
+//------------------------------------------------------------------------------------------
+//----------------------------------------VARIABLES-----------------------------------------
+//------------------------------------------------------------------------------------------
+//Feature Names, In The Same Order They Are Collected
+var featureNames = []string{
+	"Uptime",
+	"InterfaceCounters",
+	"NetworkAcl",
+	"NetworkPolicy",
+	"BgpPeers",
+	"CellInfo",
+	"Ntp",
+	"Memory",
+	"Cpu",
+	"Sensors",
+	"Optics",
+	"Inventory",
+}
+
+//Capabilities Common To All Drivers
+var uptime = capability{"Uptime", []string{".1.3.6.1.6.3.10.2.1.3"}}
+var interfaceCounters = capability{"InterfaceCounters", []string{".1.3.6.1.2.1.2.2.1"}}
+
+//Capabilities Shared By The Cisco Drivers
+var ciscoMemory = capability{"Memory", []string{".1.3.6.1.4.1.9.9.221.1.1.1"}}
+var ciscoCpu = capability{"Cpu", []string{".1.3.6.1.4.1.9.9.109.1.1.1"}}
+
+//The CISCO-ENVMON-MIB Or The CISCO-ENTITY-SENSOR-MIB, Both Collected By The IOS Driver
+var ciscoSensors = capability{"Sensors", []string{".1.3.6.1.4.1.9.9.13.1", ".1.3.6.1.4.1.9.9.91.1.1.1"}}
+
+//Capabilities Of The Drivers That Read The ENTITY-MIB
+var entityOptics = capability{"Optics", []string{".1.3.6.1.2.1.47.1.3.2.1"}}
+var entityInventory = capability{"Inventory", []string{".1.3.6.1.2.1.47.1.1.1.1"}}
+
+//Capabilities Of The HOST-RESOURCES-MIB
+var hostMemory = capability{"Memory", []string{".1.3.6.1.2.1.25.2.3.1"}}
+var hostCpu = capability{"Cpu", []string{".1.3.6.1.2.1.25.3.3.1"}}
+
+//Capabilities Of The ENTITY-SENSOR-MIB
+var entitySensors = capability{"Sensors", []string{".1.3.6.1.2.1.99.1.1.1"}}
+
+//Capabilities Shared By The UCD-SNMP Based Drivers
+var ucdMemory = capability{"Memory", []string{".1.3.6.1.4.1.2021.4"}}
+var ucdCpu = capability{"Cpu", []string{".1.3.6.1.4.1.2021.11"}}
+
+//Drivers Available For Each Device Type
+var drivers = map[string]driver{
+	"generic": {
+		New:          func(d *device) Device { return &generic{device: d} },
+		Capabilities: []capability{uptime, interfaceCounters, hostMemory, hostCpu, entitySensors, entityOptics, entityInventory},
+	},
+	"cisco-ios-xr": {
+		New:  func(d *device) Device { return &iosxr{device: d} },
+		Bulk: true,
+		Capabilities: []capability{
+			uptime,
+			interfaceCounters,
+			{"NetworkPolicy", []string{".1.3.6.1.4.1.9.9.166.1.15.1"}},
+			{"BgpPeers", []string{".1.3.6.1.4.1.9.9.187.1.2.8.1"}},
+			ciscoMemory,
+			ciscoCpu,
+			{"Sensors", []string{".1.3.6.1.4.1.9.9.91.1.1.1"}},
+			entityOptics,
+			entityInventory,
+		},
+	},
+	"cisco-ios": {
+		New:  func(d *device) Device { return &ios{device: d} },
+		Bulk: true,
+		Capabilities: []capability{
+			uptime,
+			interfaceCounters,
+			{"NetworkAcl", []string{".1.3.6.1.4.1.9.9.113.1.2.1"}},
+			{"BgpPeers", []string{".1.3.6.1.4.1.9.9.187.1.2.4.1"}},
+			ciscoMemory,
+			ciscoCpu,
+			ciscoSensors,
+			entityOptics,
+			entityInventory,
+		},
+	},
+	"opengear": {
+		New:  func(d *device) Device { return &opengear{device: d} },
+		Bulk: true,
+		Capabilities: []capability{
+			uptime,
+			interfaceCounters,
+			{"CellInfo", []string{".1.3.6.1.4.1.25049.17.17.1"}},
+			ucdMemory,
+			ucdCpu,
+			{"Sensors", []string{".1.3.6.1.4.1.25049.17.9.1"}},
+		},
+	},
+	"mrv": {
+		New: func(d *device) Device { return &mrv{device: d} },
+		Capabilities: []capability{
+			uptime,
+			interfaceCounters,
+			{"CellInfo", []string{".1.3.6.1.4.1.33.100.2.13.1"}},
+			{"Sensors", []string{".1.3.6.1.4.1.33.100.1.1"}},
+		},
+	},
+	"ntp": {
+		New:  func(d *device) Device { return &ntp{device: d} },
+		Bulk: true,
+		Capabilities: []capability{
+			uptime,
+			interfaceCounters,
+			{"Ntp", []string{".1.3.6.1.4.1.5597.30.0.2"}},
+			ucdMemory,
+			ucdCpu,
+			{"Sensors", []string{".1.3.6.1.4.1.5597.30.0.5"}},
+		},
+	},
+	"junos": {
+		New: func(d *device) Device { return &junos{&ios{device: d}} },
+		Capabilities: []capability{
+			uptime,
+			interfaceCounters,
+			{"NetworkAcl", []string{".1.3.6.1.4.1.9.9.113.1.2.1"}},
+			{"BgpPeers", []string{".1.3.6.1.4.1.9.9.187.1.2.4.1"}},
+			ciscoMemory,
+			ciscoCpu,
+			entitySensors,
+			{"Optics", []string{".1.3.6.1.4.1.2636.3.60.1.1.1.1"}},
+			entityInventory,
+		},
+	},
+}
+
+//Features Already Reported As Disabled Per Host, So The Warning Is Only Logged Once
+var disabled = map[string]bool{}
+
+//Probe Results Per Host, Mapping Each Probed Feature To Whether The Device Implements It
+var probed = map[string]map[string]bool{}
+
+var capabilitiesMutex sync.Mutex
+
+//------------------------------------------------------------------------------------------
+//----------------------------------------FUNCTIONS-----------------------------------------
+//------------------------------------------------------------------------------------------
+//Returns The Driver For The Given Type, Falling Back To The Generic Driver
+func getDriver(deviceType string) driver {
+	if drv, ok := drivers[deviceType]; ok {
+		return drv
+	}
+	return drivers["generic"]
+}
+
+//Returns The Known Device Types, Sorted By Name
+func Types() (types []string) {
+	for t := range drivers {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return
+}
+
+//Returns The Features Supported By The Given Device Type
+func Capabilities(deviceType string) (features []string, ok bool) {
+	var drv driver
+	if drv, ok = drivers[deviceType]; ok {
+		for _, c := range drv.Capabilities {
+			features = append(features, c.Feature)
+		}
+	}
+	return
+}
+
+//Maps Each Feature Name To Its Flag
+func (f *features) flags() map[string]*bool {
+	return map[string]*bool{
+		"Uptime":            &f.Uptime,
+		"InterfaceCounters": &f.InterfaceCounters,
+		"NetworkAcl":        &f.NetworkAcl,
+		"NetworkPolicy":     &f.NetworkPolicy,
+		"BgpPeers":          &f.BgpPeers,
+		"CellInfo":          &f.CellInfo,
+		"Ntp":               &f.Ntp,
+		"Memory":            &f.Memory,
+		"Cpu":               &f.Cpu,
+		"Sensors":           &f.Sensors,
+		"Optics":            &f.Optics,
+		"Inventory":         &f.Inventory,
+	}
+}
+
+//Lists The Names Of The Features Enabled, In The Order They Are Collected
+func (f features) Enabled() (names []string) {
+	flags := f.flags()
+	for _, name := range featureNames {
+		if *flags[name] {
+			names = append(names, name)
+		}
+	}
+	return
+}
+
+//Enables Only The Given Features, By Case Insensitive Name, Or All Of Them If None Is Given
+func (f *features) Only(names []string) error {
+	enable := map[string]bool{}
+	for _, name := range names {
+		found := false
+		for _, feature := range featureNames {
+			if strings.EqualFold(name, feature) {
+				enable[feature], found = true, true
+			}
+		}
+		if !found {
+			return fmt.Errorf("Unknown Feature \"%s\", Must Be One Of: %s", name, strings.Join(featureNames, ", "))
+		}
+	}
+	for name, flag := range f.flags() {
+		*flag = len(names) == 0 || enable[name]
+	}
+	return nil
+}
+
+//Disables The Enabled Features That The Device's Driver Does Not Implement
+func (d *device) RestrictFeatures() {
+	supported := map[string]bool{}
+	for _, c := range getDriver(d.Type).Capabilities {
+		supported[c.Feature] = true
+	}
+
+	flags := d.Features.flags()
+	for _, name := range featureNames {
+		if *flags[name] && !supported[name] {
+			d.disableFeature(name, fmt.Sprintf("Not Supported By Type \"%s\"", d.Type))
+		}
+	}
+}
+
+//Tests Each Enabled Feature's Root OID Once Per Host, Disabling The Ones Not Implemented
+func (d *device) ProbeFeatures() {
+	if !d.Features.Probe {
+		return
+	}
+
+	capabilitiesMutex.Lock()
+	results := probed[d.IP]
+	if results == nil {
+		results = map[string]bool{}
+		probed[d.IP] = results
+	}
+	capabilitiesMutex.Unlock()
+
+	flags := d.Features.flags()
+	for _, c := range getDriver(d.Type).Capabilities {
+		if !*flags[c.Feature] {
+			continue
+		}
+
+		capabilitiesMutex.Lock()
+		implemented, cached := results[c.Feature]
+		capabilitiesMutex.Unlock()
+
+		//Probe Only Once, Unless The Device Didn't Answer
+		if !cached {
+			answered := true
+			for _, oid := range c.Oids {
+				found, ok := snmp.Implements(&d.failures, d.SnmpConf, oid)
+				answered = answered && ok
+				if implemented = found; implemented {
+					break
+				}
+			}
+			//Unless Another One Answered, It Is Not Known Whether The One That Didn't Answer Is Implemented
+			if !implemented && !answered {
+				continue
+			}
+			capabilitiesMutex.Lock()
+			results[c.Feature] = implemented
+			capabilitiesMutex.Unlock()
+		}
+
+		if !implemented {
+			d.disableFeature(c.Feature, fmt.Sprintf("Not Implemented By The Device (%s)", strings.Join(c.Oids, ", ")))
+		}
+	}
+}
+
+//Disables A Feature, Logging A Warning The First Time It Happens For The Host
+func (d *device) disableFeature(name, reason string) {
+	*d.Features.flags()[name] = false
+
+	capabilitiesMutex.Lock()
+	defer capabilitiesMutex.Unlock()
+	if key := d.IP + "/" + name; !disabled[key] {
+		disabled[key] = true
+		d.log().With(Fields{"feature": name}).Warn(fmt.Sprintf("Feature %s Was Disabled - %s", name, reason))
+	}
+}
@@ -0,0 +1,115 @@
+package devices
+
+//------------------------------------------------------------------------------------------
+//-----------------------------------------IMPORTS------------------------------------------
+//------------------------------------------------------------------------------------------
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/fccn/gofetch-snmp/snmp"
+)
+
+//------------------------------------------------------------------------------------------
+//----------------------------------------CONSTANTS-----------------------------------------
+//------------------------------------------------------------------------------------------
+const AUTO = "auto"
+
+//------------------------------------------------------------------------------------------
+//-----------------------------------------STRUCTS------------------------------------------
+//------------------------------------------------------------------------------------------
+//Struct That Associates A sysObjectID Prefix (And Optionally A sysDescr Substring) To A Type
+type detection struct {
+	Prefix string //sysObjectID Prefix, Starting With "."
+	Descr  string //Case Insensitive Substring Of sysDescr, Ignored If Empty
+	Type   string //Device Type Chosen When The Rule Matches
+}
+
+//------------------------------------------------------------------------------------------
+//----------------------------------------VARIABLES-----------------------------------------
+//------------------------------------------------------------------------------------------
+//Detection Rules, Checked In Order, The First One That Matches Wins
+var detections = []detection{
+	{".1.3.6.1.4.1.9.", "ios xr", "cisco-ios-xr"}, //Cisco IOS-XR Shares The ciscoProducts Subtree With IOS
+	{".1.3.6.1.4.1.9.", "", "cisco-ios"},          //Cisco
+	{".1.3.6.1.4.1.2636.", "", "junos"},           //Juniper
+	{".1.3.6.1.4.1.25049.", "", "opengear"},       //Opengear
+	{".1.3.6.1.4.1.33.", "", "mrv"},               //MRV (In-Reach)
+	{".1.3.6.1.4.1.5597.", "", "ntp"},             //Meinberg
+}
+
+//Detected Types Cached Per Host IP, So Detection Only Runs Once
+var detected = map[string]string{}
+var detectedMutex sync.Mutex
+
+//------------------------------------------------------------------------------------------
+//----------------------------------------FUNCTIONS-----------------------------------------
+//------------------------------------------------------------------------------------------
+//Returns The Device Type Matching The Given sysObjectID And sysDescr, Or "" If None Matches
+func matchDetection(objectID, descr string) string {
+	if !strings.HasPrefix(objectID, ".") {
+		objectID = "." + objectID
+	}
+	descr = strings.ToLower(descr)
+	for _, rule := range detections {
+		if strings.HasPrefix(objectID, rule.Prefix) && strings.Contains(descr, rule.Descr) {
+			return rule.Type
+		}
+	}
+	return ""
+}
+
+//Reads sysObjectID And sysDescr From The Device And Sets The Detected Type
+func (d *device) DetectType() {
+	detectedMutex.Lock()
+	deviceType, cached := detected[d.IP]
+	detectedMutex.Unlock()
+
+	if !cached {
+		//---------------------------------------OIDs---------------------------------------
+		const sysDescr = ".1.3.6.1.2.1.1.1.0"
+		const sysObjectID = ".1.3.6.1.2.1.1.2.0"
+		//----------------------------------SNMP Requests-----------------------------------
+		result := snmp.Get(&d.failures, d.SnmpConf, []string{sysObjectID, sysDescr})
+		//--------------------------------Result Processing---------------------------------
+		//Don't Cache If The Device Didn't Answer, Try Again On The Next Fetch
+		if result == nil || len(result.Variables) < 2 {
+			if d.Type == AUTO {
+				d.log().Warn("Could Not Detect Device Type, Using generic")
+				d.Type = "generic"
+			}
+			return
+		}
+		var objectID, descr string
+		switch v := result.Variables[0].Value.(type) {
+		case string:
+			objectID = v
+		}
+		switch v := result.Variables[1].Value.(type) {
+		case []uint8:
+			descr = string(v)
+		case string:
+			descr = v
+		}
+		deviceType = matchDetection(objectID, descr)
+
+		detectedMutex.Lock()
+		detected[d.IP] = deviceType
+		detectedMutex.Unlock()
+
+		d.log().Debug(fmt.Sprintf("sysObjectID %s Detected As Type \"%s\"", objectID, deviceType))
+	}
+
+	switch {
+	case d.Type == AUTO && deviceType == "":
+		if !cached {
+			d.log().Warn("Could Not Detect Device Type, Using generic")
+		}
+		d.Type = "generic"
+	case d.Type == AUTO:
+		d.Type = deviceType
+	case deviceType != "" && deviceType != d.Type && !cached:
+		d.log().Info(fmt.Sprintf("Configured Type \"%s\" Differs From Detected Type \"%s\"", d.Type, deviceType))
+	}
+}
@@ -0,0 +1,101 @@
+package devices
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/fccn/gofetch-snmp/log"
+)
+
+func TestMatchDetection(t *testing.T) {
+	tests := []struct {
+		objectID string
+		descr    string
+		expected string
+	}{
+		{".1.3.6.1.4.1.9.1.1208", "Cisco IOS XR Software (Cisco ASR9K Series)", "cisco-ios-xr"},
+		{"1.3.6.1.4.1.9.1.1208", "cisco ios xr software", "cisco-ios-xr"},
+		{".1.3.6.1.4.1.9.1.1208", "Cisco IOS Software, C3750E Software", "cisco-ios"},
+		{".1.3.6.1.4.1.2636.1.1.1.2.29", "Juniper Networks, Inc. mx240", "junos"},
+		{".1.3.6.1.4.1.25049.1.61", "", "opengear"},
+		{".1.3.6.1.4.1.33.1.1", "", "mrv"},
+		{".1.3.6.1.4.1.5597.30", "", "ntp"},
+		//The Prefix Ends At A Whole Number, So Enterprise 99 Is Not Cisco
+		{".1.3.6.1.4.1.99.1", "Cisco IOS XR", ""},
+		{"", "", ""},
+	}
+	for _, test := range tests {
+		if got := matchDetection(test.objectID, test.descr); got != test.expected {
+			t.Errorf("%s %q - Expected %q, Got %q", test.objectID, test.descr, test.expected, got)
+		}
+	}
+}
+
+//Detects The Type Of A Device Served From The Given Fixtures, Forgetting What Was Detected Before
+func detectFixture(t *testing.T, configured string, files ...string) (*device, string) {
+	t.Helper()
+	detectedMutex.Lock()
+	delete(detected, "127.0.0.1")
+	detectedMutex.Unlock()
+
+	var logged bytes.Buffer
+	SetOutput(&logged)
+	defer SetOutput(os.Stdout)
+
+	a := newAgent(t, append([]string{"common"}, files...)...)
+	d := NewDevice(Host{IP: "127.0.0.1", Type: configured, SnmpConfig: snmpconfig{Version: 2, Community: "public", Port: a.Port(), Timeout: 2}})
+	if err := d.SnmpConf.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer d.SnmpConf.Conn.Close()
+	d.DetectType()
+	return d, logged.String()
+}
+
+func TestDetectType(t *testing.T) {
+	defer func() {
+		detectedMutex.Lock()
+		delete(detected, "127.0.0.1")
+		detectedMutex.Unlock()
+	}()
+
+	if d, _ := detectFixture(t, AUTO, "sysobject-cisco", "sysdescr-ios-xr"); d.Type != "cisco-ios-xr" {
+		t.Errorf("Expected IOS-XR From Its sysDescr, Got %s", d.Type)
+	}
+	if d, _ := detectFixture(t, AUTO, "sysobject-cisco"); d.Type != "cisco-ios" {
+		t.Errorf("Expected IOS From The Cisco Enterprise, Got %s", d.Type)
+	}
+
+	//A Configured Type Is Kept, Telling It Differs
+	if d, logged := detectFixture(t, "generic", "sysobject-cisco"); d.Type != "generic" || !strings.Contains(logged, "Differs From Detected Type") {
+		t.Errorf("Expected The Configured Type To Be Kept And The Difference Logged, Got %s And %q", d.Type, logged)
+	}
+
+	//An Unknown Enterprise Is Collected As Generic, With A Warning
+	if d, logged := detectFixture(t, AUTO, "sysobject-unknown"); d.Type != "generic" || !strings.Contains(logged, "Could Not Detect Device Type") {
+		t.Errorf("Expected Generic And A Warning, Got %s And %q", d.Type, logged)
+	}
+}
+
+func TestDetectTypeCached(t *testing.T) {
+	defer func() {
+		detectedMutex.Lock()
+		delete(detected, "127.0.0.1")
+		detectedMutex.Unlock()
+	}()
+	detectFixture(t, AUTO, "sysobject-cisco")
+
+	//The Host Is Not Asked Again, Even Once It No Longer Answers
+	d := NewDevice(Host{IP: "127.0.0.1", Type: AUTO, SnmpConfig: snmpconfig{Version: 2, Community: "public", Port: 1, Timeout: 2}})
+	start := time.Now()
+	d.DetectType()
+	if d.Type != "cisco-ios" || time.Since(start) > time.Second {
+		t.Errorf("Expected The Cached Type Right Away, Got %s After %s", d.Type, time.Since(start))
+	}
+	if errors := d.failures.Take(); len(errors) > 0 {
+		t.Errorf("Expected No Requests, Got %v", errors)
+	}
+}
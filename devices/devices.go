@@ -1,9 +1,11 @@
 package devices
 
 import (
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/fccn/gofetch-snmp/data"
@@ -27,8 +29,14 @@ const (
 	MEMORY     = "memory_info"
 	CPU        = "cpu_info"
 	SENSOR     = "sensor_info"
+	STORAGE    = "storage_info"
+	OPTICS     = "optics_info"
+	INVENTORY  = "inventory_info"
 )
 
+//Interval During Which A Host That Did Not Answer For Its Tags Is Not Asked Again
+const unansweredTagsInterval = time.Minute
+
 //------------------------------------------------------------------------------------------
 //----------------------------------------INTERFACES----------------------------------------
 //------------------------------------------------------------------------------------------
@@ -55,8 +63,12 @@ type Device interface {
 	Cpu()
 	//Collect Sensor Data
 	Sensors()
+	//Collect Optical Transceiver Data
+	Optics()
+	//Collect Physical Inventory Data
+	Inventory()
 	//Fetch All Data And Write To InfluxDB
-	Fetch(dat *data.Data, s *runner.S)
+	Fetch(dat *data.Data, s *runner.S) error
 }
 
 //------------------------------------------------------------------------------------------
@@ -72,32 +84,38 @@ type Host struct {
 
 //Struct That Receives Host Snmp Configurations From YAML
 type snmpconfig struct {
-	Version   int    `yaml:"Version"`
-	Timeout   int    `yaml:"Timeout"`
-	Retries   int    `yaml:"Retries"`
-	Port      uint16 `yaml:"Port"`
-	Community string `yaml:"Community"`
-	Flags     string `yaml:"Flags"`
-	Username  string `yaml:"Username"`
-	AuthProt  string `yaml:"AuthProt"`
-	AuthPass  string `yaml:"AuthPass"`
-	PrivProt  string `yaml:"PrivProt"`
-	PrivPass  string `yaml:"PrivPass"`
+	Version       int    `yaml:"Version"`
+	Timeout       int    `yaml:"Timeout"`
+	Retries       int    `yaml:"Retries"`
+	Port          uint16 `yaml:"Port"`
+	Community     string `yaml:"Community"`
+	TrapCommunity string `yaml:"TrapCommunity"` //Community Of The Traps The Device Sends, Not The One Polled
+	Flags         string `yaml:"Flags"`
+	Username      string `yaml:"Username"`
+	AuthProt      string `yaml:"AuthProt"`
+	AuthPass      string `yaml:"AuthPass"`
+	PrivProt      string `yaml:"PrivProt"`
+	PrivPass      string `yaml:"PrivPass"`
+	Parallel      int    `yaml:"Parallel"`
 }
 
 //Struct That Receives Host Features Information From YAML
 type features struct {
-	GofetchStatistics bool `yaml:"GofetchStatistics"`
-	Uptime            bool `yaml:"Uptime"`
-	InterfaceCounters bool `yaml:"InterfaceCounters"`
-	NetworkAcl        bool `yaml:"NetworkAcl"`
-	NetworkPolicy     bool `yaml:"NetworkPolicy"`
-	BgpPeers          bool `yaml:"BgpPeers"`
-	CellInfo          bool `yaml:"CellInfo"`
-	Ntp               bool `yaml:"Ntp"`
-	Memory            bool `yaml:"Memory"`
-	Cpu               bool `yaml:"Cpu"`
-	Sensors           bool `yaml:"Sensors"`
+	GofetchStatistics bool   `yaml:"GofetchStatistics"`
+	Probe             bool   `yaml:"Probe"`
+	Uptime            bool   `yaml:"Uptime"`
+	InterfaceCounters bool   `yaml:"InterfaceCounters"`
+	NetworkAcl        bool   `yaml:"NetworkAcl"`
+	NetworkPolicy     bool   `yaml:"NetworkPolicy"`
+	BgpPeers          bool   `yaml:"BgpPeers"`
+	CellInfo          bool   `yaml:"CellInfo"`
+	Ntp               bool   `yaml:"Ntp"`
+	Memory            bool   `yaml:"Memory"`
+	Cpu               bool   `yaml:"Cpu"`
+	Sensors           bool   `yaml:"Sensors"`
+	Optics            bool   `yaml:"Optics"`
+	Inventory         bool   `yaml:"Inventory"`
+	InventoryInterval string `yaml:"InventoryInterval"` //Such As "6h", One Hour If Empty
 }
 
 //Struct That Carries All Hosts' Information From YAML
@@ -115,8 +133,34 @@ type device struct {
 	Data     *data.Data //Data Collected For Each Of The Device's Metrics
 	Bulk     bool       //Indicates If Device Can Use BulkWalk
 	Cancel   bool       //Indicates That Fetch Should Not Run
+	Status   HostStatus //Status Of The Current Collection
+	Parallel int        //Maximum Number Of Concurrent SNMP Requests, Features Run One At A Time If 1
+
+	conns    chan g.GoSNMP //Connections Free For The Walks, Opened By Fetch
+	stop     *runner.S     //Indicates That The Collection Should Stop
+	mutex    sync.Mutex    //Guards The Status While Features Run Concurrently
+	failures snmp.Errors   //Errors Of This Device's SNMP Requests, Apart From Those Made For Others To The Same Host
+}
+
+//Tags Of A Host That Did Not Answer For Them
+type unanswered struct {
+	tags map[string]string
+	at   time.Time
 }
 
+//------------------------------------------------------------------------------------------
+//----------------------------------------VARIABLES-----------------------------------------
+//------------------------------------------------------------------------------------------
+//Tags Of Each Device From Its Last Collection, By IP, To Tag What Is Received Between Collections
+var lastTags = map[string]map[string]string{}
+var lastTagsMutex sync.Mutex
+
+//Tags Of Each Host That Did Not Answer For Them, Holding Only Its IP, And When It Was Asked, By IP
+var unansweredTags = map[string]unanswered{}
+
+//Hosts Being Asked For Their Tags, By IP, Closed Once Answered, So A Host Is Asked Once At A Time
+var tagQueries = map[string]chan struct{}{}
+
 //------------------------------------------------------------------------------------------
 //----------------------------------------FUNCTIONS-----------------------------------------
 //------------------------------------------------------------------------------------------
@@ -180,33 +224,21 @@ func NewDevice(host Host) *device {
 		Features: host.Features,
 		IP:       host.IP,
 		Type:     strings.ToLower(host.Type),
+		Parallel: host.SnmpConfig.Parallel,
+	}
+
+	//Without A Limit, The Requests Are Made One At A Time
+	if d.Parallel < 1 {
+		d.Parallel = 1
 	}
 
 	return &d
 }
 
 func (d *device) GetSpecific() Device {
-	switch d.Type {
-	case "cisco-ios-xr":
-		d.Bulk = true
-		return &iosxr{device: d}
-	case "cisco-ios":
-		d.Bulk = true
-		return &ios{device: d}
-	case "opengear":
-		d.Bulk = true
-		return &opengear{device: d}
-	case "mrv":
-		return &mrv{device: d}
-	case "ntp":
-		d.Bulk = true
-		return &ntp{device: d}
-	case "junos":
-		//d.Bulk = true
-		return &ios{device: d}
-	default:
-		return &generic{device: d}
-	}
+	drv := getDriver(d.Type)
+	d.Bulk = drv.Bulk
+	return drv.New(d)
 }
 
 func (d *device) Init() {
@@ -223,15 +255,56 @@ func (d *device) Init() {
 }
 
 func (d *device) AddDataFromEntries(metric string, entries data.Entries, function data.Function) {
-	d.Data.AddFromEntries(d.SnmpConf, d.Bulk, metric, entries, function)
+	d.Data.AddFromEntries(d.walk, metric, entries, function)
 }
 
 func (d *device) AddMetricTagsFromEntries(metric string, entries data.Entries) {
-	d.Data.AddFromEntries(d.SnmpConf, d.Bulk, metric, entries, data.AddTags)
+	d.Data.AddFromEntries(d.walk, metric, entries, data.AddTags)
 }
 
 func (d *device) AddMetricFieldsFromEntries(metric string, entries data.Entries) {
-	d.Data.AddFromEntries(d.SnmpConf, d.Bulk, metric, entries, data.AddFields)
+	d.Data.AddFromEntries(d.walk, metric, entries, data.AddFields)
+}
+
+//Walks An Oid Over A Free Connection, Waiting For One If All Are Busy, Skipping It If The Collection Should Stop
+func (d *device) walk(oid string) []g.SnmpPDU {
+	if d.stop != nil && (*d.stop)() {
+		return nil
+	}
+	conn := <-d.conns
+	defer func() { d.conns <- conn }()
+	return snmp.WalkAll(&d.failures, conn, d.Bulk, oid)
+}
+
+//Opens The Connections Used By The Walks, Besides The Main One, Up To Parallel
+func (d *device) openConns() {
+	d.conns = make(chan g.GoSNMP, d.Parallel)
+	d.conns <- d.SnmpConf
+	for i := 1; i < d.Parallel; i++ {
+		conn := d.SnmpConf
+		conn.Conn = nil
+		if conn.SecurityParameters != nil {
+			conn.SecurityParameters = conn.SecurityParameters.Copy()
+		}
+		if err := conn.Connect(); err != nil {
+			d.log().Warn(fmt.Sprintf("Could Not Open More Than %d Connections: %v", i, err))
+			break
+		}
+		d.conns <- conn
+	}
+}
+
+//Closes The Connections Opened By openConns, Except The Main One
+func (d *device) closeConns() {
+	if d.conns == nil {
+		return
+	}
+	for len(d.conns) > 0 {
+		if conn := <-d.conns; conn.Conn != d.SnmpConf.Conn {
+			conn.Conn.Close()
+		}
+	}
+	d.conns = nil
 }
 
 func (d *device) GetTags() {
@@ -239,19 +312,86 @@ func (d *device) GetTags() {
 	//---------------------------------------OIDs---------------------------------------
 	const sysName = ".1.3.6.1.2.1.1.5.0"
 	//----------------------------------SNMP Requests-----------------------------------
-	name := snmp.Get(d.SnmpConf, []string{sysName})
+	name := snmp.Get(&d.failures, d.SnmpConf, []string{sysName})
 	//--------------------------------Result Processing---------------------------------
 	if name != nil && len(name.Variables) > 0 {
 		d.Data.AddTag("device_name", strings.ToLower(string(name.Variables[0].Value.([]byte))))
 		d.Data.AddTag("device_ip", d.IP)
 		d.Data.AddTag("device_type", d.Type)
+
+		lastTagsMutex.Lock()
+		lastTags[d.IP] = map[string]string{}
+		for k, v := range d.Data.Tags {
+			lastTags[d.IP][k] = v
+		}
+		lastTagsMutex.Unlock()
 	} else {
 		d.Cancel = true
 	}
 }
 
+//Gets The Device Tags Of A Host From Its Last Collection, Asking The Device If It Wasn't Collected Yet
+//A Host That Does Not Answer Is Only Asked Again After A While, Being Identified By Its IP Meanwhile
+func HostTags(host Host) map[string]string {
+	tags := map[string]string{}
+	for {
+		lastTagsMutex.Lock()
+		if cached, ok := lastTags[host.IP]; ok {
+			for k, v := range cached {
+				tags[k] = v
+			}
+			lastTagsMutex.Unlock()
+			return tags
+		}
+		if u, ok := unansweredTags[host.IP]; ok && time.Since(u.at) < unansweredTagsInterval {
+			for k, v := range u.tags {
+				tags[k] = v
+			}
+			lastTagsMutex.Unlock()
+			return tags
+		}
+		//Wait For The Host To Answer Another Query, Instead Of Asking It Again
+		wait, asking := tagQueries[host.IP]
+		if !asking {
+			tagQueries[host.IP] = make(chan struct{})
+			lastTagsMutex.Unlock()
+			break
+		}
+		lastTagsMutex.Unlock()
+		<-wait
+	}
+
+	d := NewDevice(host)
+	dat := data.NewData()
+	d.Data = &dat
+	if err := d.SnmpConf.Connect(); err == nil {
+		d.DetectType()
+		d.GetTags()
+		d.SnmpConf.Conn.Close()
+	}
+	answered := dat.GetTag("device_name") != ""
+	if !answered {
+		//Without An Answer, The IP Identifies The Device
+		dat.AddTag("device_name", host.IP)
+		dat.AddTag("device_ip", host.IP)
+		dat.AddTag("device_type", d.Type)
+	}
+	for k, v := range dat.Tags {
+		tags[k] = v
+	}
+
+	lastTagsMutex.Lock()
+	if !answered {
+		unansweredTags[host.IP] = unanswered{tags: dat.Tags, at: time.Now()}
+	}
+	close(tagQueries[host.IP])
+	delete(tagQueries, host.IP)
+	lastTagsMutex.Unlock()
+	return tags
+}
+
 func (d *device) GetInterfaceTags() {
-	if d.Cancel || (!d.Features.NetworkPolicy && !d.Features.InterfaceCounters) {
+	if d.Cancel || (!d.Features.NetworkPolicy && !d.Features.InterfaceCounters && !d.Features.Optics) {
 		return
 	}
 	//---------------------------------------OIDs---------------------------------------
@@ -274,7 +414,7 @@ func (d *device) GetInterfaceTags() {
 		case ipAddrIfIndex:
 			index := strconv.Itoa(pdu.Value.(int))
 			var ip string
-			if tag := m.Tags[index][entry.Name]; tag != "" {
+			if tag := m.GetTag(index, entry.Name); tag != "" {
 				ip = tag + ";"
 			}
 			m.AddTag(index, entry.Name, ip+strings.TrimPrefix(pdu.Name, entry.Oid+"."))
@@ -308,6 +448,7 @@ func (d *device) InterfaceCounters() {
 	const ifOutErrors = ".1.3.6.1.2.1.2.2.1.20"
 	const ifHCInOctets = ".1.3.6.1.2.1.31.1.1.1.6"
 	const ifHCOutOctets = ".1.3.6.1.2.1.31.1.1.1.10"
+	const ifOperStatus = ".1.3.6.1.2.1.2.2.1.8"
 	//-------------------------------------Entries--------------------------------------
 	entries := data.Entries{
 		{"interface_in_discards", ifInDiscards},
@@ -316,32 +457,47 @@ func (d *device) InterfaceCounters() {
 		{"interface_out_errors", ifOutErrors},
 		{"interface_in_hc_bytes", ifHCInOctets},
 		{"interface_out_hc_bytes", ifHCOutOctets},
+		{"interface_oper_status", ifOperStatus},
 	}
 	//--------------------------------Result Processing---------------------------------
 	d.AddMetricFieldsFromEntries(INTERFACE, entries)
 }
 
-func (d *device) Fetch(dat *data.Data, s *runner.S) {
+func (d *device) Fetch(dat *data.Data, s *runner.S) (err error) {
 	//For Statistic Purposes
 	start := time.Now()
 
 	//----------------------------------Initialization----------------------------------
+	//Initialize Device Data
+	d.Data, d.stop = dat, s
+	d.Status = HostStatus{IP: d.IP, LastPoll: start, Features: map[string]FeatureStatus{}}
+	d.failures.Take()
+
 	//Start SNMP Connection
 	if err := d.SnmpConf.Connect(); err != nil {
-		FatalLog(fmt.Sprintf("SNMP Connect() err: %v", err))
+		d.Status.Error = fmt.Sprintf("Could Not Connect: %v", err)
+		setStatus(d.Status)
+		return errors.New(d.Status.Error)
 	}
 
-	//Initialize Device Data
-	d.Data = dat
+	//Share The Connections Among The Walks, Opening More If Features Run Concurrently
+	d.openConns()
+
+	//Detect The Device Type From Its sysObjectID, Replacing It If Configured As "auto"
+	d.DetectType()
 
 	//Based On The Type Configured, Initialize A Specific Device
 	dev := d.GetSpecific()
 
+	//Disable The Features The Driver Does Not Implement
+	d.RestrictFeatures()
+
 	//Variables Initialization
 	dev.Init()
 
 	defer func() {
-		//Close Connection In The End, Or If Something Goes Wrong
+		//Close Connections In The End, Or If Something Goes Wrong
+		d.closeConns()
 		d.SnmpConf.Conn.Close()
 
 		//Set The Timestamp From When The Data Was Collected
@@ -352,16 +508,35 @@ func (d *device) Fetch(dat *data.Data, s *runner.S) {
 
 		//Collect Performance Statistics
 		if d.Features.GofetchStatistics {
-			d.Data.GetMetric(STATISTICS).AddField("0", "statistics_fetch_seconds", delta.Seconds())
+			d.Data.GetMetric(STATISTICS).AddGauge("0", "statistics_fetch_seconds", delta.Seconds())
+			for featureName, feature := range d.Status.Features {
+				d.Data.GetMetric(STATISTICS).AddGauge("0", "statistics_"+featureName+"_seconds", feature.Seconds)
+			}
+		}
+
+		//Keep The Status Of The Collection
+		d.Status.Type, d.Status.Name, d.Status.Seconds = d.Type, d.Data.GetTag("device_name"), delta.Seconds()
+		if err != nil {
+			d.Status.Error = err.Error()
+		} else if !d.Status.hasErrors() {
+			//Nothing Failed, So The Alerts And Events Can Tell What Is Missing Is Gone
+			d.Data.SetComplete()
 		}
+		setStatus(d.Status)
 	}()
 
 	//Return If Cancel
 	if d.Cancel {
-		Log("Fetch Was Cancelled")
-		return
+		msg := "Device Did Not Answer"
+		if errors := d.failures.Take(); len(errors) > 0 {
+			msg += ": " + errors[len(errors)-1]
+		}
+		return fmt.Errorf("%s", msg)
 	}
 
+	//Disable The Features The Device Does Not Implement, If Probing Is Enabled
+	d.ProbeFeatures()
+
 	//-------------------------------------Features-------------------------------------
 	features := []struct {
 		n string
@@ -378,25 +553,65 @@ func (d *device) Fetch(dat *data.Data, s *runner.S) {
 		{"memory", d.Features.Memory, dev.Memory},
 		{"cpu", d.Features.Cpu, dev.Cpu},
 		{"sensors", d.Features.Sensors, dev.Sensors},
+		{"optics", d.Features.Optics, dev.Optics},
+		{"inventory", d.Features.Inventory, dev.Inventory},
 	}
 
-	for _, feature := range features {
-		d.CollectFeature(feature.n, feature.c, feature.f)
+	if d.Parallel > 1 {
+		//Run The Features Concurrently, The Connections Limit How Many Requests Are Made At Once
+		var wg sync.WaitGroup
+		for _, feature := range features {
+			wg.Add(1)
+			go func(n string, c bool, f func()) {
+				defer wg.Done()
+				d.CollectFeature(n, c, f)
+			}(feature.n, feature.c, feature.f)
+		}
+		wg.Wait()
+
+		//The Errors Can't Be Told Apart By Feature, So They Are Kept For The Host
+		d.Status.Errors = d.failures.Take()
 		if (*s)() {
-			Log("Fetch Was Interrupted")
-			return
+			return fmt.Errorf("Collection Timed Out")
+		}
+	} else {
+		for _, feature := range features {
+			d.CollectFeature(feature.n, feature.c, feature.f)
+			if (*s)() {
+				return fmt.Errorf("Collection Timed Out")
+			}
 		}
 	}
 
 	//Debug Fetch Time
-	DebugLog(d.Data.GetTag("device_name") + "'s Data Has Been Collected. Duration: " + time.Now().Sub(start).String())
+	d.log().With(Fields{"duration": time.Now().Sub(start)}).Debug("Data Has Been Collected")
+	return nil
+}
+
+//Creates A Logger With The Host And Its Type As Fields
+func (d *device) log() *Logger {
+	fields := Fields{"host": d.IP, "device_type": d.Type}
+	if d.Data != nil && d.Data.GetTag("device_name") != "" {
+		fields["device_name"] = d.Data.GetTag("device_name")
+	}
+	return With(fields)
 }
 
 func (d *device) CollectFeature(featureName string, featureEnabled bool, featureFunc func()) {
 	if featureEnabled {
+		//The Errors Of The Host Belong To This Feature Only If The Features Run One At A Time
+		sequential := d.Parallel <= 1
+		if sequential {
+			d.failures.Take()
+		}
 		duration := util.FunctionDuration(featureFunc)
-		if d.Features.GofetchStatistics {
-			d.Data.GetMetric(STATISTICS).AddField("0", "statistics_"+featureName+"_seconds", duration)
+		status := FeatureStatus{Seconds: duration}
+		if sequential {
+			status.Errors = d.failures.Take()
 		}
+		d.mutex.Lock()
+		d.Status.Features[featureName] = status
+		d.mutex.Unlock()
+		d.log().With(Fields{"feature": featureName, "duration": time.Duration(duration * float64(time.Second))}).Debug("Feature Has Been Collected")
 	}
 }
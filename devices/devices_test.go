@@ -0,0 +1,300 @@
+package devices
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/fccn/gofetch-snmp/data"
+	"github.com/fccn/gofetch-snmp/snmp"
+	"github.com/matryer/runner"
+)
+
+//Fixtures Served To Each Device Type, Besides The Common One
+var fixtures = map[string][]string{
+	"generic":      {"host", "entity", "entity-sensor"},
+	"cisco-ios-xr": {"entity", "cisco-entity-sensor", "cisco-ios-xr"},
+	"cisco-ios":    {"entity", "cisco-entity-sensor", "cisco", "cisco-ios"},
+	"junos":        {"entity", "entity-sensor", "cisco", "junos"},
+	"opengear":     {"ucd", "opengear"},
+	"mrv":          {"mrv"},
+	"ntp":          {"ucd", "ntp"},
+}
+
+//Metric Written By Each Feature
+var featureMetrics = map[string]string{
+	"Uptime":            UPTIME,
+	"InterfaceCounters": INTERFACE,
+	"NetworkAcl":        INTERFACE,
+	"NetworkPolicy":     INTERFACE,
+	"BgpPeers":          BGP,
+	"CellInfo":          CELL,
+	"Ntp":               NTP,
+	"Memory":            MEMORY,
+	"Cpu":               CPU,
+	"Sensors":           SENSOR,
+	"Optics":            OPTICS,
+	"Inventory":         INVENTORY,
+}
+
+//Collects A Device Of The Given Type From An Agent, With Every Feature Enabled
+func fetchFixture(t *testing.T, deviceType string, parallel int) (*device, *data.Data) {
+	return fetchFixtureInterval(t, deviceType, parallel, "0s")
+}
+
+//Collects A Device Of The Given Type From An Agent, Collecting The Inventory Once Every Interval
+func fetchFixtureInterval(t *testing.T, deviceType string, parallel int, inventoryInterval string) (*device, *data.Data) {
+	a := newAgent(t, append([]string{"common"}, fixtures[deviceType]...)...)
+	host := Host{
+		IP:         "127.0.0.1",
+		Type:       deviceType,
+		SnmpConfig: snmpconfig{Version: 2, Community: "public", Port: a.Port(), Timeout: 2, Parallel: parallel},
+	}
+	host.Features.Only(nil)
+	host.Features.GofetchStatistics = true
+	host.Features.InventoryInterval = inventoryInterval
+
+	d := NewDevice(host)
+	dat := data.NewData()
+	s := runner.S(func() bool { return false })
+	if err := d.Fetch(&dat, &s); err != nil {
+		t.Fatal(err)
+	}
+	return d, &dat
+}
+
+func TestDriversFields(t *testing.T) {
+	for _, deviceType := range Types() {
+		fixture, ok := fixtures[deviceType]
+		if !ok {
+			t.Errorf("%s - No Fixture For The Type", deviceType)
+			continue
+		}
+		for _, parallel := range []int{1, 4} {
+			t.Run(deviceType, func(t *testing.T) {
+				d, dat := fetchFixture(t, deviceType, parallel)
+				if len(d.Status.Errors) > 0 {
+					t.Errorf("Unexpected SNMP Errors: %v", d.Status.Errors)
+				}
+				for name, status := range d.Status.Features {
+					if len(status.Errors) > 0 {
+						t.Errorf("Unexpected SNMP Errors In %s: %v", name, status.Errors)
+					}
+				}
+
+				if !dat.Complete() {
+					t.Errorf("Expected The Collection To Be Complete")
+				}
+
+				//Every Feature Of The Driver Must Collect Something From Its Fixture
+				features, _ := Capabilities(deviceType)
+				for _, feature := range features {
+					if m := dat.GetMetric(featureMetrics[feature]); len(m.Fields) == 0 {
+						t.Errorf("Feature %s Collected No Fields From %v", feature, fixture)
+					}
+					if feature == "Memory" && !hasField(dat.GetMetric(MEMORY), "memory_used_bytes") {
+						t.Errorf("Feature %s Collected No Normalised Memory", feature)
+					}
+				}
+
+				//Every Field Must Be Known, So The Outputs Can Tell Its Kind And Unit
+				for _, name := range dat.UnregisteredFields() {
+					t.Errorf("Field %s Is Not Registered In The Schema", name)
+				}
+			})
+		}
+	}
+}
+
+//Tells If Any Index Of The Metric Has The Field
+func hasField(m *data.Metric, name string) bool {
+	for _, index := range m.Indexes() {
+		if _, ok := m.GetField(index, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func TestGenericHostResources(t *testing.T) {
+	_, dat := fetchFixture(t, "generic", 1)
+
+	//Sizes Are Given In Allocation Units, Of 4096 Bytes For The Filesystem
+	storage := dat.GetMetric(STORAGE)
+	if size, _ := storage.GetFloat("31", "storage_size_bytes"); size != 4096*2500000 {
+		t.Errorf("Expected %d Bytes, Got %v", 4096*2500000, size)
+	}
+
+	//The Processes Are Of The System, Apart From The Processors
+	cpu := dat.GetMetric(CPU)
+	for _, index := range cpu.Indexes() {
+		_, processes := cpu.GetField(index, "cpu_processes")
+		_, load := cpu.GetField(index, "cpu_load_percent")
+		scope := cpu.GetTag(index, "cpu_scope")
+		if processes == load || (processes && scope != "system") || (load && scope != "processor") {
+			t.Errorf("Unexpected Index %s: %v %v", index, cpu.GetTags(index), cpu.GetFields(index))
+		}
+	}
+	if processes, _ := cpu.GetFloat("0", "cpu_processes"); processes != 212 {
+		t.Errorf("Expected 212 Processes, Got %v", processes)
+	}
+}
+
+func TestNormalisedCpuFromTicks(t *testing.T) {
+	dat := data.NewData()
+	d := &device{IP: "ticks", Data: &dat}
+	m := dat.GetOrAddMetric(CPU)
+
+	//The First Collection Has No Utilisation, The Second Is Told By The Difference
+	for i, ticks := range [][]uint{{100, 100, 700, 100}, {130, 120, 740, 110}} {
+		for j, name := range []string{"cpu_user", "cpu_system", "cpu_idle", "cpu_wait"} {
+			m.AddField("0", name, ticks[j])
+		}
+		d.normaliseTicksCpu()
+		value, ok := m.GetField("0", "cpu_utilisation_percent")
+		if i == 0 && ok {
+			t.Errorf("Unexpected Utilisation In The First Collection: %v", value)
+		}
+		if i == 1 && value != float64(50) {
+			t.Errorf("Expected 50%%, Got %#v", value)
+		}
+	}
+}
+
+func TestInventoryInterval(t *testing.T) {
+	lastInventoryMutex.Lock()
+	delete(lastInventory, "127.0.0.1")
+	lastInventoryMutex.Unlock()
+
+	//The First Collection Gets The Inventory, The Next Ones Wait For The Interval
+	for i := 0; i < 2; i++ {
+		_, dat := fetchFixtureInterval(t, "cisco-ios-xr", 1, "")
+		m := dat.GetMetric(INVENTORY)
+		if i == 0 && m.GetTag("1", "inventory_class") != "chassis" {
+			t.Errorf("Expected The Chassis In The Inventory, Got %v", m.GetTags("1"))
+		}
+		if serial, _ := m.GetField("6001", "inventory_serial"); i == 0 && serial != "AVD1122334" {
+			t.Errorf("Expected The Serial Number Of The Transceiver, Got %#v", serial)
+		}
+		if i == 1 && len(m.Indexes()) > 0 {
+			t.Errorf("Unexpected Inventory Before The Interval: %v", m.Indexes())
+		}
+	}
+}
+
+func TestCollectingOverlap(t *testing.T) {
+	//Only One Collection Of A Host At A Time, Other Hosts Are Not Affected
+	if !StartCollecting("10.9.9.1") || StartCollecting("10.9.9.1") || !StartCollecting("10.9.9.2") {
+		t.Fatal("Expected Only The First Collection Of Each Host To Start")
+	}
+	StopCollecting("10.9.9.1")
+	StopCollecting("10.9.9.2")
+	if !StartCollecting("10.9.9.1") {
+		t.Error("Expected The Host To Be Collected Again Once Its Collection Ended")
+	}
+	StopCollecting("10.9.9.1")
+}
+
+func TestProbeAnyOid(t *testing.T) {
+	capabilitiesMutex.Lock()
+	delete(probed, "127.0.0.1")
+	capabilitiesMutex.Unlock()
+	defer func() {
+		capabilitiesMutex.Lock()
+		delete(probed, "127.0.0.1")
+		capabilitiesMutex.Unlock()
+	}()
+
+	//The Entity Sensors Alone Keep The Sensors Of The IOS Driver Enabled, Without The CISCO-ENVMON-MIB
+	a := newAgent(t, "common", "entity", "cisco-entity-sensor")
+	host := Host{
+		IP:         "127.0.0.1",
+		Type:       "cisco-ios",
+		SnmpConfig: snmpconfig{Version: 2, Community: "public", Port: a.Port(), Timeout: 2},
+	}
+	host.Features.Sensors, host.Features.Probe = true, true
+
+	d := NewDevice(host)
+	dat := data.NewData()
+	s := runner.S(func() bool { return false })
+	if err := d.Fetch(&dat, &s); err != nil {
+		t.Fatal(err)
+	}
+	if !d.Features.Sensors || len(dat.GetMetric(SENSOR).Indexes()) == 0 {
+		t.Errorf("Expected The Entity Sensors To Be Collected")
+	}
+}
+
+func TestHostTagsUnanswered(t *testing.T) {
+	//An Address That Receives The Requests But Never Answers
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.3")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	var requests int32
+	go func() {
+		buffer := make([]byte, 65535)
+		for {
+			if _, _, err := conn.ReadFromUDP(buffer); err != nil {
+				return
+			}
+			atomic.AddInt32(&requests, 1)
+		}
+	}()
+	host := Host{IP: "127.0.0.3", Type: "generic", SnmpConfig: snmpconfig{Version: 2, Community: "public", Port: uint16(conn.LocalAddr().(*net.UDPAddr).Port), Timeout: 1}}
+
+	//Traps Arriving Together Ask The Host Once, And Those That Follow Don't Ask It Again
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if tags := HostTags(host); tags["device_name"] != "127.0.0.3" || tags["device_ip"] != "127.0.0.3" {
+				t.Errorf("Expected The IP To Identify The Host, Got %v", tags)
+			}
+		}()
+	}
+	wg.Wait()
+	asked := atomic.LoadInt32(&requests)
+	if asked == 0 || asked > 3 {
+		t.Errorf("Expected The Host To Be Asked Once, Got %d Requests", asked)
+	}
+
+	start := time.Now()
+	if tags := HostTags(host); tags["device_name"] != "127.0.0.3" || time.Since(start) > 100*time.Millisecond {
+		t.Errorf("Expected The Cached Tags Right Away, Got %v After %s", tags, time.Since(start))
+	}
+	if got := atomic.LoadInt32(&requests); got != asked {
+		t.Errorf("Expected No More Requests, Got %d", got-asked)
+	}
+}
+
+func TestErrorsPerCollection(t *testing.T) {
+	//An Address That Receives The Requests But Never Answers
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	collected := NewDevice(Host{IP: "127.0.0.1", Type: "generic", SnmpConfig: snmpconfig{Version: 2, Community: "public", Port: 161}})
+	collected.Status.Features = map[string]FeatureStatus{}
+	other := NewDevice(Host{IP: "127.0.0.1", Type: "generic", SnmpConfig: snmpconfig{Version: 2, Community: "public", Port: uint16(conn.LocalAddr().(*net.UDPAddr).Port), Timeout: 1}})
+	if err := other.SnmpConf.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer other.SnmpConf.Conn.Close()
+
+	//A Request Failing For Another Owner, Such As A Trap, While A Feature Of The Same Host Is Collected
+	collected.CollectFeature("uptime", true, func() {
+		snmp.Get(&other.failures, other.SnmpConf, []string{".1.3.6.1.2.1.1.5.0"})
+	})
+	if errors := collected.Status.Features["uptime"].Errors; len(errors) > 0 {
+		t.Errorf("Expected No Errors In The Collection, Got %v", errors)
+	}
+	if errors := other.failures.Take(); len(errors) != 1 {
+		t.Errorf("Expected The Error To Be Kept By Its Owner, Got %v", errors)
+	}
+}
@@ -0,0 +1,185 @@
+package devices
+
+//------------------------------------------------------------------------------------------
+//-----------------------------------------IMPORTS------------------------------------------
+//------------------------------------------------------------------------------------------
+import (
+	"math"
+
+	"github.com/fccn/gofetch-snmp/data"
+	"github.com/fccn/gofetch-snmp/snmp"
+	g "github.com/soniah/gosnmp"
+)
+
+//------------------------------------------------------------------------------------------
+//-----------------------------------------STRUCTS------------------------------------------
+//------------------------------------------------------------------------------------------
+//Struct That Holds A Reading Of A Sensor, Scaled To The Unit Of Its Type
+type sensorReading struct {
+	Type  string
+	Value float64
+}
+
+//Struct That Holds The Columns Of A Sensor Table Of The ENTITY-SENSOR-MIB Or Of A Private MIB Alike
+type sensorTable struct {
+	Type      string
+	Scale     string
+	Precision string
+	Value     string
+	Status    string //Operational Status, Ignored If Empty
+}
+
+//------------------------------------------------------------------------------------------
+//----------------------------------------VARIABLES-----------------------------------------
+//------------------------------------------------------------------------------------------
+//Sensor Table Of The Standard ENTITY-SENSOR-MIB (RFC 3433)
+var entPhySensorTable = sensorTable{
+	Type:      ".1.3.6.1.2.1.99.1.1.1.1",
+	Scale:     ".1.3.6.1.2.1.99.1.1.1.2",
+	Precision: ".1.3.6.1.2.1.99.1.1.1.3",
+	Value:     ".1.3.6.1.2.1.99.1.1.1.4",
+	Status:    ".1.3.6.1.2.1.99.1.1.1.5",
+}
+
+//Sensor Table Of The CISCO-ENTITY-SENSOR-MIB
+var ciscoEntSensorTable = sensorTable{
+	Type:      ".1.3.6.1.4.1.9.9.91.1.1.1.1.1",
+	Scale:     ".1.3.6.1.4.1.9.9.91.1.1.1.1.2",
+	Precision: ".1.3.6.1.4.1.9.9.91.1.1.1.1.3",
+	Value:     ".1.3.6.1.4.1.9.9.91.1.1.1.1.4",
+}
+
+//Map - TypeNumber: TypeName, The Cisco Types Extending The Standard Ones
+var sensorTypes = map[int]string{
+	1:  "", //Other
+	2:  "", //Unknown
+	3:  "volts",
+	4:  "volts",
+	5:  "amperes",
+	6:  "watts",
+	7:  "hertz",
+	8:  "celsius",
+	9:  "percent_rh",
+	10: "rpm",
+	11: "cmm",
+	12: "bool",
+	13: "special_enum",
+	14: "dbm",
+	15: "db",
+}
+
+//------------------------------------------------------------------------------------------
+//----------------------------------------FUNCTIONS-----------------------------------------
+//------------------------------------------------------------------------------------------
+//Gets The Name And Description Of Each Physical Entity Of The ENTITY-MIB, By Index
+func (d *device) getPhysicalEntries() map[string]map[string]interface{} {
+	//---------------------------------------OIDs---------------------------------------
+	const entPhysicalDescr = ".1.3.6.1.2.1.47.1.1.1.1.2"
+	const entPhysicalName = ".1.3.6.1.2.1.47.1.1.1.1.7"
+	//-------------------------------------Entries--------------------------------------
+	entries := data.Entries{
+		{"descr", entPhysicalDescr},
+		{"name", entPhysicalName},
+	}
+	//--------------------------------Result Processing---------------------------------
+	physicalEntries := map[string]map[string]interface{}{}
+
+	for i := range entries {
+		entry := entries[i]
+		metric := d.walk(entry.Oid)
+		for i := range metric {
+			index := snmp.GetIndex(metric[i], entry.Oid)
+			if physicalEntries[index] == nil {
+				physicalEntries[index] = map[string]interface{}{}
+			}
+			switch metric[i].Value.(type) {
+			case []uint8:
+				physicalEntries[index][entry.Name] = string(metric[i].Value.([]uint8))
+			case string:
+				physicalEntries[index][entry.Name] = metric[i].Value.(string)
+			}
+		}
+	}
+	return physicalEntries
+}
+
+//Collects The Sensors Of A Sensor Table, Scaling The Values To The Unit Of Their Type
+func (d *device) EntitySensors(table sensorTable, physicalEntries map[string]map[string]interface{}) {
+	//-------------------------------------Entries--------------------------------------
+	entries := data.Entries{
+		{"type", table.Type},
+		{"scale", table.Scale},
+		{"precision", table.Precision},
+		{"sensor_value", table.Value},
+	}
+	if table.Status != "" {
+		entries = append(entries, data.Entry{"sensor_status", table.Status})
+	}
+	//--------------------------------Result Processing---------------------------------
+	sensorType := map[string]string{}
+	sensorScale := map[string]int{}
+	sensorPrecision := map[string]int{}
+
+	sensorData := data.Function(func(m data.Metric, entry data.Entry, pdu g.SnmpPDU) {
+		index := snmp.GetIndex(pdu, entry.Oid)
+		number, ok := pdu.Value.(int)
+		if !ok {
+			return
+		}
+
+		switch entry.Oid {
+		case table.Type:
+			sensorType[index] = sensorTypes[number]
+		case table.Scale:
+			sensorScale[index] = number
+		case table.Precision:
+			sensorPrecision[index] = number
+		case table.Value:
+			var value interface{}
+			if sensorType[index] == "bool" {
+				value = number == 1
+			} else {
+				value = float32(scaleSensor(number, sensorScale[index], sensorPrecision[index]))
+			}
+			m.AddField(index, entry.Name+"_"+sensorType[index], value)
+
+			//Describe The Sensor By Its Physical Entity
+			name, _ := physicalEntries[index]["name"].(string)
+			descr, _ := physicalEntries[index]["descr"].(string)
+			switch {
+			case name != "" && descr != "":
+				m.AddTag(index, "sensor_descr", name+" - "+descr)
+			case name != "" || descr != "":
+				m.AddTag(index, "sensor_descr", name+descr)
+			}
+		default:
+			m.AddField(index, entry.Name, number)
+		}
+	})
+	d.AddDataFromEntries(SENSOR, entries, sensorData)
+}
+
+//Reads The Sensors Of A Sensor Table, By Index
+func (d *device) readSensors(table sensorTable) map[string]sensorReading {
+	columns := map[string]map[string]int{}
+	for _, oid := range []string{table.Type, table.Scale, table.Precision, table.Value} {
+		columns[oid] = map[string]int{}
+		for _, pdu := range d.walk(oid) {
+			if number, ok := pdu.Value.(int); ok {
+				columns[oid][snmp.GetIndex(pdu, oid)] = number
+			}
+		}
+	}
+
+	readings := map[string]sensorReading{}
+	for index, value := range columns[table.Value] {
+		sensorType := sensorTypes[columns[table.Type][index]]
+		readings[index] = sensorReading{sensorType, scaleSensor(value, columns[table.Scale][index], columns[table.Precision][index])}
+	}
+	return readings
+}
+
+//Scales A Sensor Value To The Unit Of Its Type, The Scale Being 9 For Units And Each Step A Power Of 1000
+func scaleSensor(value, scale, precision int) float64 {
+	return float64(value) * math.Pow10((scale-9)*3-precision)
+}
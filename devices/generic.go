@@ -1,8 +1,12 @@
 package devices
 
 import (
+	"strings"
+
 	"github.com/fccn/gofetch-snmp/data"
+	"github.com/fccn/gofetch-snmp/snmp"
 	"github.com/matryer/runner"
+	g "github.com/soniah/gosnmp"
 )
 
 //------------------------------------------------------------------------------------------
@@ -17,16 +21,6 @@ type generic struct {
 //------------------------------------------------------------------------------------------
 func (d *generic) Init() {
 	d.device.Init()
-
-	//Unsupported Features
-	d.Features.CellInfo = false
-	d.Features.Ntp = false
-	d.Features.NetworkAcl = false
-	d.Features.NetworkPolicy = false
-	d.Features.BgpPeers = false
-	d.Features.Memory = false
-	d.Features.Cpu = false
-	d.Features.Sensors = false
 }
 
 func (d *generic) Uptime() {
@@ -37,6 +31,124 @@ func (d *generic) InterfaceCounters() {
 	d.device.InterfaceCounters()
 }
 
-func (d *generic) Fetch(dat *data.Data, s *runner.S) {
-	d.device.Fetch(dat, s)
+func (d *generic) Memory() {
+	//---------------------------------------OIDs---------------------------------------
+	const hrStorageType = ".1.3.6.1.2.1.25.2.3.1.2"
+	const hrStorageDescr = ".1.3.6.1.2.1.25.2.3.1.3"
+	const hrStorageAllocationUnits = ".1.3.6.1.2.1.25.2.3.1.4"
+	const hrStorageSize = ".1.3.6.1.2.1.25.2.3.1.5"
+	const hrStorageUsed = ".1.3.6.1.2.1.25.2.3.1.6"
+	const hrStorageTypes = ".1.3.6.1.2.1.25.2.1"
+	//-------------------------------------Entries--------------------------------------
+	entries := data.Entries{
+		{"storage_type", hrStorageType},
+		{"storage_descr", hrStorageDescr},
+		{"units", hrStorageAllocationUnits},
+		{"storage_size_bytes", hrStorageSize},
+		{"storage_used_bytes", hrStorageUsed},
+	}
+	//--------------------------------Result Processing---------------------------------
+	types := map[string]string{
+		"1":  "other",
+		"2":  "ram",
+		"3":  "virtual_memory",
+		"4":  "fixed_disk",
+		"5":  "removable_disk",
+		"6":  "floppy_disk",
+		"7":  "compact_disc",
+		"8":  "ram_disk",
+		"9":  "flash_memory",
+		"10": "network_disk",
+	}
+
+	//Stores The Size Of The Allocation Units, In Bytes
+	units := map[string]float64{}
+
+	function := data.Function(func(m data.Metric, entry data.Entry, pdu g.SnmpPDU) {
+		index := snmp.GetIndex(pdu, entry.Oid)
+
+		switch entry.Oid {
+		//The Type Is An OID Under hrStorageTypes
+		case hrStorageType:
+			value, _ := pdu.Value.(string)
+			name, ok := types[strings.TrimPrefix(value, hrStorageTypes+".")]
+			if !ok {
+				name = "other"
+			}
+			m.AddTag(index, entry.Name, name)
+		case hrStorageDescr:
+			data.AddTags(m, entry, pdu)
+		case hrStorageAllocationUnits:
+			units[index] = float64(pdu.Value.(int))
+		//Sizes Are Given In Allocation Units
+		default:
+			m.AddGauge(index, entry.Name, float64(pdu.Value.(int))*units[index])
+		}
+	})
+	d.AddDataFromEntries(STORAGE, entries, function)
+
+	//The Physical Memory Is The Normalised One
+	storage := d.Data.GetMetric(STORAGE)
+	for _, index := range storage.Indexes() {
+		if storage.GetTag(index, "storage_type") != "ram" {
+			continue
+		}
+		size, okSize := storage.GetFloat(index, "storage_size_bytes")
+		used, okUsed := storage.GetFloat(index, "storage_used_bytes")
+		if okSize && okUsed {
+			m := d.Data.GetOrAddMetric(MEMORY)
+			m.AddTag(index, "memory_descr", storage.GetTag(index, "storage_descr"))
+			m.AddGauge(index, "memory_total_bytes", size)
+			m.AddGauge(index, "memory_used_bytes", used)
+		}
+	}
+}
+
+func (d *generic) Cpu() {
+	//---------------------------------------OIDs---------------------------------------
+	const hrProcessorLoad = ".1.3.6.1.2.1.25.3.3.1.2"
+	const hrDeviceDescr = ".1.3.6.1.2.1.25.3.2.1.3"
+	const hrSystemProcesses = ".1.3.6.1.2.1.25.1.6"
+	//-------------------------------------Entries--------------------------------------
+	entries := data.Entries{
+		{"cpu_load_percent", hrProcessorLoad},
+		{"cpu_descr", hrDeviceDescr},
+	}
+	processes := data.Entries{
+		{"cpu_processes", hrSystemProcesses},
+	}
+	//--------------------------------Result Processing---------------------------------
+	function := data.Function(func(m data.Metric, entry data.Entry, pdu g.SnmpPDU) {
+		index := snmp.GetIndex(pdu, entry.Oid)
+
+		switch entry.Oid {
+		//Only The Processors Among The Devices Are Described
+		case hrDeviceDescr:
+			if _, ok := m.GetField(index, "cpu_load_percent"); ok {
+				data.AddTags(m, entry, pdu)
+			}
+		//The Processes Are Of The Whole System, Not Of A Processor
+		case hrSystemProcesses:
+			m.AddTag(index, "cpu_scope", "system")
+			m.AddField(index, entry.Name, pdu.Value)
+		default:
+			m.AddTag(index, "cpu_scope", "processor")
+			m.AddField(index, entry.Name, pdu.Value)
+		}
+	})
+	d.AddDataFromEntries(CPU, entries, function)
+	d.normalisePercentCpu("cpu_load_percent")
+	d.AddDataFromEntries(CPU, processes, function)
+}
+
+func (d *generic) Sensors() {
+	d.EntitySensors(entPhySensorTable, d.getPhysicalEntries())
+}
+
+func (d *generic) Optics() {
+	d.EntityOptics(entPhySensorTable, d.getPhysicalEntries())
+}
+
+func (d *generic) Fetch(dat *data.Data, s *runner.S) error {
+	return d.device.Fetch(dat, s)
 }
@@ -0,0 +1,137 @@
+package devices
+
+//------------------------------------------------------------------------------------------
+//-----------------------------------------IMPORTS------------------------------------------
+//------------------------------------------------------------------------------------------
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/fccn/gofetch-snmp/data"
+	"github.com/fccn/gofetch-snmp/snmp"
+	g "github.com/soniah/gosnmp"
+)
+
+//------------------------------------------------------------------------------------------
+//----------------------------------------CONSTANTS-----------------------------------------
+//------------------------------------------------------------------------------------------
+//Interval Between The Collections Of The Inventory, Unless The Host Sets Another
+const defaultInventoryInterval = time.Hour
+
+//------------------------------------------------------------------------------------------
+//----------------------------------------VARIABLES-----------------------------------------
+//------------------------------------------------------------------------------------------
+//Map - ClassNumber: ClassName, Of The entPhysicalClass Of The ENTITY-MIB
+var physicalClasses = map[int]string{
+	1:  "other",
+	2:  "unknown",
+	3:  "chassis",
+	4:  "backplane",
+	5:  "container",
+	6:  "power_supply",
+	7:  "fan",
+	8:  "sensor",
+	9:  "module",
+	10: "port",
+	11: "stack",
+	12: "cpu",
+	13: "energy_object",
+	14: "battery",
+	15: "storage_drive",
+}
+
+//Time Of The Last Collection Of The Inventory, By IP, Since It Changes Seldom
+var lastInventory = map[string]time.Time{}
+var lastInventoryMutex sync.Mutex
+
+//------------------------------------------------------------------------------------------
+//----------------------------------------FUNCTIONS-----------------------------------------
+//------------------------------------------------------------------------------------------
+//Collects The Physical Inventory Of The ENTITY-MIB, Once Every Inventory Interval
+func (d *device) Inventory() {
+	if d.Cancel || !d.Features.Inventory || !d.inventoryDue() {
+		return
+	}
+	//---------------------------------------OIDs---------------------------------------
+	const entPhysicalDescr = ".1.3.6.1.2.1.47.1.1.1.1.2"
+	const entPhysicalContainedIn = ".1.3.6.1.2.1.47.1.1.1.1.4"
+	const entPhysicalClass = ".1.3.6.1.2.1.47.1.1.1.1.5"
+	const entPhysicalParentRelPos = ".1.3.6.1.2.1.47.1.1.1.1.6"
+	const entPhysicalName = ".1.3.6.1.2.1.47.1.1.1.1.7"
+	const entPhysicalHardwareRev = ".1.3.6.1.2.1.47.1.1.1.1.8"
+	const entPhysicalFirmwareRev = ".1.3.6.1.2.1.47.1.1.1.1.9"
+	const entPhysicalSoftwareRev = ".1.3.6.1.2.1.47.1.1.1.1.10"
+	const entPhysicalSerialNum = ".1.3.6.1.2.1.47.1.1.1.1.11"
+	const entPhysicalModelName = ".1.3.6.1.2.1.47.1.1.1.1.13"
+	const entPhysicalIsFRU = ".1.3.6.1.2.1.47.1.1.1.1.16"
+	//-------------------------------------Entries--------------------------------------
+	entries := data.Entries{
+		{"inventory_descr", entPhysicalDescr},
+		{"inventory_contained_in", entPhysicalContainedIn},
+		{"inventory_class", entPhysicalClass},
+		{"inventory_position", entPhysicalParentRelPos},
+		{"inventory_name", entPhysicalName},
+		{"inventory_hardware_rev", entPhysicalHardwareRev},
+		{"inventory_firmware_rev", entPhysicalFirmwareRev},
+		{"inventory_software_rev", entPhysicalSoftwareRev},
+		{"inventory_serial", entPhysicalSerialNum},
+		{"inventory_model", entPhysicalModelName},
+		{"inventory_fru", entPhysicalIsFRU},
+	}
+	//--------------------------------Result Processing---------------------------------
+	function := data.Function(func(m data.Metric, entry data.Entry, pdu g.SnmpPDU) {
+		index := snmp.GetIndex(pdu, entry.Oid)
+
+		switch value := pdu.Value.(type) {
+		case []byte:
+			switch {
+			case len(value) == 0:
+			//The Name Identifies The Part Along With Its Class
+			case entry.Oid == entPhysicalName:
+				m.AddTag(index, entry.Name, string(value))
+			default:
+				m.AddString(index, entry.Name, string(value))
+			}
+		case int:
+			switch entry.Oid {
+			case entPhysicalClass:
+				if class, ok := physicalClasses[value]; ok {
+					m.AddTag(index, entry.Name, class)
+				} else {
+					m.AddTag(index, entry.Name, strconv.Itoa(value))
+				}
+			case entPhysicalIsFRU:
+				m.AddBool(index, entry.Name, value == 1)
+			default:
+				m.AddGauge(index, entry.Name, float64(value))
+			}
+		}
+	})
+	d.AddDataFromEntries(INVENTORY, entries, function)
+
+	//Only A Collection That Got The Inventory Waits For The Next Interval
+	if len(d.Data.GetMetric(INVENTORY).Indexes()) > 0 {
+		lastInventoryMutex.Lock()
+		lastInventory[d.IP] = time.Now()
+		lastInventoryMutex.Unlock()
+	}
+}
+
+//Tells If The Inventory Interval Has Passed Since The Inventory Was Last Collected
+func (d *device) inventoryDue() bool {
+	interval := defaultInventoryInterval
+	if d.Features.InventoryInterval != "" {
+		if t, err := time.ParseDuration(d.Features.InventoryInterval); err == nil {
+			interval = t
+		} else {
+			d.log().Warn(fmt.Sprintf("Invalid InventoryInterval \"%s\", Using %s", d.Features.InventoryInterval, interval))
+		}
+	}
+
+	lastInventoryMutex.Lock()
+	defer lastInventoryMutex.Unlock()
+	last, ok := lastInventory[d.IP]
+	return !ok || time.Since(last) >= interval
+}
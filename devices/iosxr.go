@@ -4,7 +4,6 @@ package devices
 //-----------------------------------------IMPORTS------------------------------------------
 //------------------------------------------------------------------------------------------
 import (
-	"math"
 	"strconv"
 	"strings"
 
@@ -30,38 +29,8 @@ type iosxr struct {
 func (d *iosxr) Init() {
 	d.device.Init()
 
-	//Unsupported Features
-	d.Features.CellInfo = false
-	d.Features.Ntp = false
-
-	if !d.Cancel && (d.Features.Memory || d.Features.Cpu || d.Features.Sensors) {
-		//---------------------------------------OIDs---------------------------------------
-		const entPhysicalDescr = ".1.3.6.1.2.1.47.1.1.1.1.2"
-		const entPhysicalName = ".1.3.6.1.2.1.47.1.1.1.1.7"
-		//-------------------------------------Entries--------------------------------------
-		entries := data.Entries{
-			{"descr", entPhysicalDescr},
-			{"name", entPhysicalName},
-		}
-		//--------------------------------Result Processing---------------------------------
-		d.physicalEntries = map[string]map[string]interface{}{}
-
-		for i := range entries {
-			entry := entries[i]
-			metric := snmp.WalkAll(d.SnmpConf, d.Bulk, entry.Oid)
-			for i := range metric {
-				index := snmp.GetIndex(metric[i], entry.Oid)
-				if d.physicalEntries[index] == nil {
-					d.physicalEntries[index] = map[string]interface{}{}
-				}
-				switch metric[i].Value.(type) {
-				case []uint8:
-					d.physicalEntries[index][entry.Name] = string(metric[i].Value.([]uint8))
-				case string:
-					d.physicalEntries[index][entry.Name] = metric[i].Value.(string)
-				}
-			}
-		}
+	if !d.Cancel && (d.Features.Memory || d.Features.Cpu || d.Features.Sensors || d.Features.Optics) {
+		d.physicalEntries = d.getPhysicalEntries()
 	}
 }
 
@@ -183,8 +152,8 @@ func (d *iosxr) NetworkPolicy() {
 
 			//Initialize Tags
 			newIndex := index + "_" + qosParentIndex[objectIndex]
-			if m.Tags[newIndex]["interface_policy"] == "" {
-				for k, v := range m.Tags[index] {
+			if m.GetTag(newIndex, "interface_policy") == "" {
+				for k, v := range m.GetTags(index) {
 					m.AddTag(newIndex, k, v)
 				}
 			}
@@ -269,6 +238,7 @@ func (d *iosxr) Memory() {
 		}
 	}
 	d.AddDataFromEntries(MEMORY, entries, function)
+	d.normalisePoolMemory()
 }
 
 func (d *iosxr) Cpu() {
@@ -304,6 +274,7 @@ func (d *iosxr) Cpu() {
 		}
 	})
 	d.AddDataFromEntries(CPU, entries, function)
+	d.normalisePercentCpu("cpu_one_minute_percent")
 }
 
 func (d *iosxr) Sensors() {
@@ -313,65 +284,13 @@ func (d *iosxr) Sensors() {
 		return
 	}
 
-	//Map - TypeNumber: TypeName
-	types := map[int]string{
-		1:  "", //Other
-		2:  "", //Unknown
-		3:  "volts",
-		4:  "volts",
-		5:  "amperes",
-		6:  "watts",
-		7:  "hertz",
-		8:  "celsius",
-		9:  "percent_rh",
-		10: "rpm",
-		11: "cmm",
-		12: "bool",
-		13: "special_enum",
-		14: "dbm",
-		15: "db",
-	}
-	//---------------------------------------OIDs---------------------------------------
-	const entSensorType = ".1.3.6.1.4.1.9.9.91.1.1.1.1.1"
-	const entSensorScale = ".1.3.6.1.4.1.9.9.91.1.1.1.1.2"
-	const entSensorPrecision = ".1.3.6.1.4.1.9.9.91.1.1.1.1.3"
-	const entSensorValue = ".1.3.6.1.4.1.9.9.91.1.1.1.1.4"
-	//-------------------------------------Entries--------------------------------------
-	entries := data.Entries{
-		{"type", entSensorType},
-		{"scale", entSensorScale},
-		{"precision", entSensorPrecision},
-		{"sensor_value", entSensorValue},
-	}
-	//--------------------------------Result Processing---------------------------------
-	sensorType := map[string]string{}
-	sensorScale := map[string]int{}
-	sensorPrecision := map[string]int{}
-
-	sensorData := data.Function(func(m data.Metric, entry data.Entry, pdu g.SnmpPDU) {
-		index := snmp.GetIndex(pdu, entry.Oid)
+	d.EntitySensors(ciscoEntSensorTable, d.physicalEntries)
+}
 
-		switch entry.Oid {
-		case entSensorType:
-			sensorType[index] = types[pdu.Value.(int)]
-		case entSensorScale:
-			sensorScale[index] = pdu.Value.(int)
-		case entSensorPrecision:
-			sensorPrecision[index] = pdu.Value.(int)
-		case entSensorValue:
-			var value interface{}
-			if sensorType[index] == "bool" {
-				value = pdu.Value.(int) == 1
-			} else {
-				value = float32(float64(pdu.Value.(int)) * math.Pow10((sensorScale[index]-9)*3-sensorPrecision[index]))
-			}
-			m.AddField(index, entry.Name+"_"+sensorType[index], value)
-			m.AddTag(index, "sensor_descr", d.physicalEntries[index]["name"].(string)+" - "+d.physicalEntries[index]["descr"].(string))
-		}
-	})
-	d.AddDataFromEntries(SENSOR, entries, sensorData)
+func (d *iosxr) Optics() {
+	d.EntityOptics(ciscoEntSensorTable, d.physicalEntries)
 }
 
-func (d *iosxr) Fetch(dat *data.Data, s *runner.S) {
-	d.device.Fetch(dat, s)
+func (d *iosxr) Fetch(dat *data.Data, s *runner.S) error {
+	return d.device.Fetch(dat, s)
 }
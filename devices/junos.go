@@ -0,0 +1,72 @@
+package devices
+
+//------------------------------------------------------------------------------------------
+//-----------------------------------------IMPORTS------------------------------------------
+//------------------------------------------------------------------------------------------
+import (
+	"strings"
+
+	"github.com/fccn/gofetch-snmp/snmp"
+)
+
+//------------------------------------------------------------------------------------------
+//-----------------------------------------STRUCTS------------------------------------------
+//------------------------------------------------------------------------------------------
+type junos struct {
+	*ios //Extends IOS Struct, Which Collects The Same Features
+}
+
+//------------------------------------------------------------------------------------------
+//----------------------------------------FUNCTIONS-----------------------------------------
+//------------------------------------------------------------------------------------------
+//Juniper Devices Implement The Standard ENTITY-SENSOR-MIB, Not The Cisco MIBs Of The IOS Driver
+func (d *junos) Sensors() {
+	d.EntitySensors(entPhySensorTable, d.physicalEntries)
+}
+
+func (d *junos) Optics() {
+	//---------------------------------------OIDs---------------------------------------
+	const jnxDomCurrentRxLaserPower = ".1.3.6.1.4.1.2636.3.60.1.1.1.1.5"
+	const jnxDomCurrentTxLaserBiasCurrent = ".1.3.6.1.4.1.2636.3.60.1.1.1.1.6"
+	const jnxDomCurrentTxLaserOutputPower = ".1.3.6.1.4.1.2636.3.60.1.1.1.1.7"
+	const jnxDomCurrentModuleTemperature = ".1.3.6.1.4.1.2636.3.60.1.1.1.1.8"
+	const jnxDomCurrentModuleVoltage = ".1.3.6.1.4.1.2636.3.60.1.1.1.1.25"
+	const jnxDomCurrentLaneRxLaserPower = ".1.3.6.1.4.1.2636.3.60.1.2.1.1.6"
+	const jnxDomCurrentLaneTxLaserBiasCurrent = ".1.3.6.1.4.1.2636.3.60.1.2.1.1.7"
+	const jnxDomCurrentLaneTxLaserOutputPower = ".1.3.6.1.4.1.2636.3.60.1.2.1.1.8"
+	const jnxDomCurrentLaneLaserTemperature = ".1.3.6.1.4.1.2636.3.60.1.2.1.1.9"
+	//-------------------------------------Entries--------------------------------------
+	//The Transceiver Table Is Indexed By ifIndex, The Lane Table By ifIndex And Lane
+	entries := []struct {
+		Name  string
+		Oid   string
+		Scale float64 //Divisor To The Unit Of The Field
+		Lane  bool
+	}{
+		{"optics_rx_power_dbm", jnxDomCurrentRxLaserPower, 100, false},
+		{"optics_bias_current_milliamperes", jnxDomCurrentTxLaserBiasCurrent, 1000, false},
+		{"optics_tx_power_dbm", jnxDomCurrentTxLaserOutputPower, 100, false},
+		{"optics_temperature_celsius", jnxDomCurrentModuleTemperature, 1, false},
+		{"optics_voltage_volts", jnxDomCurrentModuleVoltage, 1000, false},
+		{"optics_rx_power_dbm", jnxDomCurrentLaneRxLaserPower, 100, true},
+		{"optics_bias_current_milliamperes", jnxDomCurrentLaneTxLaserBiasCurrent, 1000, true},
+		{"optics_tx_power_dbm", jnxDomCurrentLaneTxLaserOutputPower, 100, true},
+		{"optics_temperature_celsius", jnxDomCurrentLaneLaserTemperature, 1, true},
+	}
+	//--------------------------------Result Processing---------------------------------
+	var readings []opticsReading
+	for _, entry := range entries {
+		for _, pdu := range d.walk(entry.Oid) {
+			value, ok := pdu.Value.(int)
+			if !ok {
+				continue
+			}
+			reading := opticsReading{IfIndex: snmp.GetIndex(pdu, entry.Oid), Field: entry.Name, Value: float64(value) / entry.Scale}
+			if split := strings.Split(strings.TrimPrefix(pdu.Name, entry.Oid+"."), "."); entry.Lane && len(split) == 2 {
+				reading.IfIndex, reading.Lane = split[0], split[1]
+			}
+			readings = append(readings, reading)
+		}
+	}
+	d.addOptics(readings)
+}
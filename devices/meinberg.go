@@ -23,12 +23,6 @@ type meinberg struct {
 //------------------------------------------------------------------------------------------
 func (d *meinberg) Init() {
 	d.device.Init()
-
-	//Unsupported Features
-	d.Features.NetworkAcl = false
-	d.Features.NetworkPolicy = false
-	d.Features.BgpPeers = false
-	d.Features.CellInfo = false
 }
 
 func (d *meinberg) Uptime() {
@@ -79,6 +73,7 @@ func (d *meinberg) Memory() {
 	}
 	//--------------------------------Result Processing---------------------------------
 	d.AddMetricFieldsFromEntries(MEMORY, entries)
+	d.normaliseKbytesMemory("memory_real_used_kbytes", "memory_real_free_kbytes")
 }
 
 func (d *meinberg) Cpu() {
@@ -98,6 +93,7 @@ func (d *meinberg) Cpu() {
 	}
 	//--------------------------------Result Processing---------------------------------
 	d.AddMetricFieldsFromEntries(CPU, entries)
+	d.normaliseTicksCpu()
 }
 
 func (d *meinberg) Sensors() {
@@ -143,6 +139,6 @@ func (d *meinberg) Sensors() {
 	d.AddDataFromEntries(SENSOR, temp, function)
 }
 
-func (d *meinberg) Fetch(dat *data.Data, s *runner.S) {
-	d.device.Fetch(dat, s)
+func (d *meinberg) Fetch(dat *data.Data, s *runner.S) error {
+	return d.device.Fetch(dat, s)
 }
@@ -22,14 +22,6 @@ type mrv struct {
 //------------------------------------------------------------------------------------------
 func (d *mrv) Init() {
 	d.device.Init()
-
-	//Unsupported Features
-	d.Features.Ntp = false
-	d.Features.NetworkAcl = false
-	d.Features.NetworkPolicy = false
-	d.Features.BgpPeers = false
-	d.Features.Memory = false
-	d.Features.Cpu = false
 }
 
 func (d *mrv) Uptime() {
@@ -83,6 +75,6 @@ func (d *mrv) Sensors() {
 	d.AddDataFromEntries(SENSOR, entries, function)
 }
 
-func (d *mrv) Fetch(dat *data.Data, s *runner.S) {
-	d.device.Fetch(dat, s)
+func (d *mrv) Fetch(dat *data.Data, s *runner.S) error {
+	return d.device.Fetch(dat, s)
 }
@@ -0,0 +1,106 @@
+package devices
+
+//------------------------------------------------------------------------------------------
+//-----------------------------------------IMPORTS------------------------------------------
+//------------------------------------------------------------------------------------------
+import (
+	"strings"
+	"sync"
+)
+
+//------------------------------------------------------------------------------------------
+//-----------------------------------------STRUCTS------------------------------------------
+//------------------------------------------------------------------------------------------
+//Raw CPU Ticks Of A Collection, Busy Being The Time Not Spent Idle Or Waiting
+type cpuTicks struct {
+	Busy  float64
+	Total float64
+}
+
+//------------------------------------------------------------------------------------------
+//----------------------------------------VARIABLES-----------------------------------------
+//------------------------------------------------------------------------------------------
+//CPU Ticks Of The Last Collection, By IP And Index, The Utilisation Being Told By Their Difference
+var lastCpuTicks = map[string]cpuTicks{}
+var lastCpuTicksMutex sync.Mutex
+
+//------------------------------------------------------------------------------------------
+//----------------------------------------FUNCTIONS-----------------------------------------
+//------------------------------------------------------------------------------------------
+//Adds The Normalised CPU Utilisation From The UCD-SNMP Raw Ticks, Since The Previous Collection
+func (d *device) normaliseTicksCpu() {
+	m := d.Data.GetMetric(CPU)
+indexes:
+	for _, index := range m.Indexes() {
+		var ticks cpuTicks
+		for _, name := range []string{"cpu_user", "cpu_system", "cpu_idle", "cpu_wait"} {
+			value, ok := m.GetFloat(index, name)
+			if !ok {
+				continue indexes
+			}
+			ticks.Total += value
+			if name == "cpu_user" || name == "cpu_system" {
+				ticks.Busy += value
+			}
+		}
+
+		lastCpuTicksMutex.Lock()
+		last, ok := lastCpuTicks[d.IP+"/"+index]
+		lastCpuTicks[d.IP+"/"+index] = ticks
+		lastCpuTicksMutex.Unlock()
+
+		//The First Collection Has Nothing To Compare To, And A Counter That Wrapped Or Reset Is Skipped
+		busy, total := ticks.Busy-last.Busy, ticks.Total-last.Total
+		if !ok || busy < 0 || total <= 0 || busy > total {
+			continue
+		}
+		m.AddGauge(index, "cpu_utilisation_percent", 100*busy/total)
+	}
+}
+
+//Adds The Normalised CPU Utilisation From A Field That Is Already A Percentage
+func (d *device) normalisePercentCpu(name string) {
+	m := d.Data.GetMetric(CPU)
+	for _, index := range m.Indexes() {
+		if value, ok := m.GetFloat(index, name); ok {
+			m.AddGauge(index, "cpu_utilisation_percent", value)
+		}
+	}
+}
+
+//Adds The Normalised Memory In Bytes From The Total And Free Memory Fields In Kilobytes, As In UCD-SNMP
+func (d *device) normaliseKbytesMemory(totalName, freeName string) {
+	m := d.Data.GetMetric(MEMORY)
+	for _, index := range m.Indexes() {
+		total, okTotal := m.GetFloat(index, totalName)
+		free, okFree := m.GetFloat(index, freeName)
+		if !okTotal || !okFree || free > total {
+			continue
+		}
+		m.AddGauge(index, "memory_total_bytes", total*1024)
+		m.AddGauge(index, "memory_used_bytes", (total-free)*1024)
+	}
+}
+
+//Adds The Normalised Memory In Bytes From The Used And Free Bytes Of Each Memory Pool, Summed By Index
+func (d *device) normalisePoolMemory() {
+	m := d.Data.GetMetric(MEMORY)
+	for _, index := range m.Indexes() {
+		var used, free float64
+		var found bool
+		for name := range m.GetFields(index) {
+			value, _ := m.GetFloat(index, name)
+			switch {
+			case name == "memory_used_bytes" || name == "memory_total_bytes":
+			case strings.HasSuffix(name, "_used_bytes"):
+				used, found = used+value, true
+			case strings.HasSuffix(name, "_free_bytes"):
+				free, found = free+value, true
+			}
+		}
+		if found {
+			m.AddGauge(index, "memory_total_bytes", used+free)
+			m.AddGauge(index, "memory_used_bytes", used)
+		}
+	}
+}
@@ -23,12 +23,6 @@ type ntp struct {
 //------------------------------------------------------------------------------------------
 func (d *ntp) Init() {
 	d.device.Init()
-
-	//Unsupported Features
-	d.Features.NetworkAcl = false
-	d.Features.NetworkPolicy = false
-	d.Features.BgpPeers = false
-	d.Features.CellInfo = false
 }
 
 func (d *ntp) Uptime() {
@@ -63,14 +57,17 @@ func (d *ntp) Ntp() {
 func (d *ntp) Memory() {
 	//---------------------------------------OIDs---------------------------------------
 	const memTotalReal = ".1.3.6.1.4.1.2021.4.5"
+	const memAvailReal = ".1.3.6.1.4.1.2021.4.6"
 	const memTotalFree = ".1.3.6.1.4.1.2021.4.11"
 	//-------------------------------------Entries--------------------------------------
 	entries := data.Entries{
 		{"memory_total", memTotalReal},
 		{"memory_free", memTotalFree},
+		{"memory_real_free_kbytes", memAvailReal},
 	}
 	//--------------------------------Result Processing---------------------------------
 	d.AddMetricFieldsFromEntries(MEMORY, entries)
+	d.normaliseKbytesMemory("memory_total", "memory_real_free_kbytes")
 }
 
 func (d *ntp) Cpu() {
@@ -90,6 +87,7 @@ func (d *ntp) Cpu() {
 	}
 	//--------------------------------Result Processing---------------------------------
 	d.AddMetricFieldsFromEntries(CPU, entries)
+	d.normaliseTicksCpu()
 }
 
 func (d *ntp) Sensors() {
@@ -135,6 +133,6 @@ func (d *ntp) Sensors() {
 	d.AddDataFromEntries(SENSOR, temp, function)
 }
 
-func (d *ntp) Fetch(dat *data.Data, s *runner.S) {
-	d.device.Fetch(dat, s)
+func (d *ntp) Fetch(dat *data.Data, s *runner.S) error {
+	return d.device.Fetch(dat, s)
 }
@@ -22,12 +22,6 @@ type opengear struct {
 //------------------------------------------------------------------------------------------
 func (d *opengear) Init() {
 	d.device.Init()
-
-	//Unsupported Features
-	d.Features.Ntp = false
-	d.Features.NetworkAcl = false
-	d.Features.NetworkPolicy = false
-	d.Features.BgpPeers = false
 }
 
 func (d *opengear) Uptime() {
@@ -73,14 +67,17 @@ func (d *opengear) CellInfo() {
 func (d *opengear) Memory() {
 	//---------------------------------------OIDs---------------------------------------
 	const memTotalReal = ".1.3.6.1.4.1.2021.4.5"
+	const memAvailReal = ".1.3.6.1.4.1.2021.4.6"
 	const memTotalFree = ".1.3.6.1.4.1.2021.4.11"
 	//-------------------------------------Entries--------------------------------------
 	entries := data.Entries{
 		{"memory_total", memTotalReal},
 		{"memory_free", memTotalFree},
+		{"memory_real_free_kbytes", memAvailReal},
 	}
 	//--------------------------------Result Processing---------------------------------
 	d.AddMetricFieldsFromEntries(MEMORY, entries)
+	d.normaliseKbytesMemory("memory_total", "memory_real_free_kbytes")
 }
 
 func (d *opengear) Cpu() {
@@ -100,6 +97,7 @@ func (d *opengear) Cpu() {
 	}
 	//--------------------------------Result Processing---------------------------------
 	d.AddMetricFieldsFromEntries(CPU, entries)
+	d.normaliseTicksCpu()
 }
 
 func (d *opengear) Sensors() {
@@ -123,12 +121,12 @@ func (d *opengear) Sensors() {
 		case ogEmdTemperatureValue:
 			m.AddField(index, entry.Name, pdu.Value)
 		default:
-			m.AddTag(index, entry.Name, pdu.Value.(string))
+			data.AddTags(m, entry, pdu)
 		}
 	})
 	d.AddDataFromEntries(SENSOR, entries, function)
 }
 
-func (d *opengear) Fetch(dat *data.Data, s *runner.S) {
-	d.device.Fetch(dat, s)
+func (d *opengear) Fetch(dat *data.Data, s *runner.S) error {
+	return d.device.Fetch(dat, s)
 }
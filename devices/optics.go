@@ -0,0 +1,124 @@
+package devices
+
+//------------------------------------------------------------------------------------------
+//-----------------------------------------IMPORTS------------------------------------------
+//------------------------------------------------------------------------------------------
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/fccn/gofetch-snmp/snmp"
+)
+
+//------------------------------------------------------------------------------------------
+//-----------------------------------------STRUCTS------------------------------------------
+//------------------------------------------------------------------------------------------
+//Struct That Holds A Reading Of A Transceiver, By Interface And Lane
+type opticsReading struct {
+	IfIndex string
+	Lane    string //Empty If The Reading Is Of The Whole Transceiver
+	Field   string
+	Value   float64
+}
+
+//------------------------------------------------------------------------------------------
+//----------------------------------------VARIABLES-----------------------------------------
+//------------------------------------------------------------------------------------------
+//Tells The Direction Of An Optical Power Sensor And Its Lane From Its Name Or Description
+var opticsReceive = regexp.MustCompile(`\b(rx|receive)\b`)
+var opticsTransmit = regexp.MustCompile(`\b(tx|transmit)\b`)
+var opticsLane = regexp.MustCompile(`\blane\s*(\d+)`)
+
+//------------------------------------------------------------------------------------------
+//----------------------------------------FUNCTIONS-----------------------------------------
+//------------------------------------------------------------------------------------------
+//Collects The Transceiver Readings From The Entity Sensors Contained In A Port With An Interface
+func (d *device) EntityOptics(table sensorTable, physicalEntries map[string]map[string]interface{}) {
+	//---------------------------------------OIDs---------------------------------------
+	const entPhysicalContainedIn = ".1.3.6.1.2.1.47.1.1.1.1.4"
+	const entAliasMappingIdentifier = ".1.3.6.1.2.1.47.1.3.2.1.2"
+	const ifIndex = ".1.3.6.1.2.1.2.2.1.1"
+	//----------------------------------SNMP Requests-----------------------------------
+	containedIn := map[string]string{}
+	for _, pdu := range d.walk(entPhysicalContainedIn) {
+		if parent, ok := pdu.Value.(int); ok {
+			containedIn[snmp.GetIndex(pdu, entPhysicalContainedIn)] = strconv.Itoa(parent)
+		}
+	}
+
+	//The Alias Is Indexed By The Physical And The Logical Entity, Its Value Being The ifIndex Object
+	interfaces := map[string]string{}
+	for _, pdu := range d.walk(entAliasMappingIdentifier) {
+		alias, _ := pdu.Value.(string)
+		if !strings.HasPrefix(alias, ifIndex+".") {
+			continue
+		}
+		physical := strings.Split(snmp.GetIndex(pdu, entAliasMappingIdentifier), ".")[0]
+		interfaces[physical] = strings.TrimPrefix(alias, ifIndex+".")
+	}
+	//--------------------------------Result Processing---------------------------------
+	var readings []opticsReading
+	for index, sensor := range d.readSensors(table) {
+		//Climb The Containment Up To The Port, The Sensor Being Usually In The Transceiver
+		var port string
+		for parent, depth := index, 0; parent != "" && parent != "0" && depth < 5; parent, depth = containedIn[parent], depth+1 {
+			if port = interfaces[parent]; port != "" {
+				break
+			}
+		}
+		if port == "" {
+			continue
+		}
+
+		name, _ := physicalEntries[index]["name"].(string)
+		descr, _ := physicalEntries[index]["descr"].(string)
+		label := strings.ToLower(name + " " + descr)
+
+		reading := opticsReading{IfIndex: port, Value: sensor.Value}
+		if lane := opticsLane.FindStringSubmatch(label); lane != nil {
+			reading.Lane = lane[1]
+		}
+		switch {
+		case sensor.Type == "dbm" && opticsReceive.MatchString(label):
+			reading.Field = "optics_rx_power_dbm"
+		case sensor.Type == "dbm" && opticsTransmit.MatchString(label):
+			reading.Field = "optics_tx_power_dbm"
+		//The Standard Sensors Give The Optical Power In Watts
+		case sensor.Type == "watts" && sensor.Value > 0 && opticsReceive.MatchString(label):
+			reading.Field, reading.Value = "optics_rx_power_dbm", 10*math.Log10(sensor.Value*1000)
+		case sensor.Type == "watts" && sensor.Value > 0 && opticsTransmit.MatchString(label):
+			reading.Field, reading.Value = "optics_tx_power_dbm", 10*math.Log10(sensor.Value*1000)
+		case sensor.Type == "amperes":
+			reading.Field, reading.Value = "optics_bias_current_milliamperes", sensor.Value*1000
+		case sensor.Type == "celsius":
+			reading.Field = "optics_temperature_celsius"
+		case sensor.Type == "volts":
+			reading.Field = "optics_voltage_volts"
+		default:
+			continue
+		}
+		readings = append(readings, reading)
+	}
+	d.addOptics(readings)
+}
+
+//Writes The Transceiver Readings, Tagged With The Interface As In The Interface Metric
+func (d *device) addOptics(readings []opticsReading) {
+	m := d.Data.GetOrAddMetric(OPTICS)
+	interfaces := d.Data.GetMetric(INTERFACE)
+	for _, reading := range readings {
+		index := reading.IfIndex
+		if reading.Lane != "" {
+			index += "." + reading.Lane
+			m.AddTag(index, "optics_lane", reading.Lane)
+		}
+		for _, tag := range []string{"interface_name", "interface_descr"} {
+			if value := interfaces.GetTag(reading.IfIndex, tag); value != "" {
+				m.AddTag(index, tag, value)
+			}
+		}
+		m.AddGauge(index, reading.Field, reading.Value)
+	}
+}
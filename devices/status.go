@@ -0,0 +1,89 @@
+package devices
+
+//------------------------------------------------------------------------------------------
+//-----------------------------------------IMPORTS------------------------------------------
+//------------------------------------------------------------------------------------------
+import (
+	"sync"
+	"time"
+)
+
+//------------------------------------------------------------------------------------------
+//-----------------------------------------STRUCTS------------------------------------------
+//------------------------------------------------------------------------------------------
+//Status Of The Last Collection Of A Host
+type HostStatus struct {
+	IP       string                   `json:"ip"`
+	Type     string                   `json:"type"`
+	Name     string                   `json:"name"`
+	LastPoll time.Time                `json:"last_poll"`
+	Seconds  float64                  `json:"seconds"`
+	Error    string                   `json:"error,omitempty"`  //Why The Collection Did Not Complete
+	Errors   []string                 `json:"errors,omitempty"` //Errors Of The SNMP Requests Not Told Apart By Feature
+	Features map[string]FeatureStatus `json:"features"`
+}
+
+//Status Of A Feature In The Last Collection Of A Host
+type FeatureStatus struct {
+	Seconds float64  `json:"seconds"`
+	Errors  []string `json:"errors,omitempty"` //Errors Of The SNMP Requests
+}
+
+//------------------------------------------------------------------------------------------
+//----------------------------------------VARIABLES-----------------------------------------
+//------------------------------------------------------------------------------------------
+//Status Of Each Host's Last Collection, By IP
+var statuses = map[string]HostStatus{}
+var statusesMutex sync.Mutex
+
+//Hosts Being Collected, By IP, Including Collections That Outlived Their Timeout
+var collecting = map[string]bool{}
+var collectingMutex sync.Mutex
+
+//------------------------------------------------------------------------------------------
+//----------------------------------------FUNCTIONS-----------------------------------------
+//------------------------------------------------------------------------------------------
+//Gets The Status Of The Last Collection Of A Host, "ok" Is False If It Wasn't Collected Yet
+func Status(ip string) (status HostStatus, ok bool) {
+	statusesMutex.Lock()
+	defer statusesMutex.Unlock()
+	status, ok = statuses[ip]
+	return
+}
+
+//Tells If Any SNMP Request Of The Collection Failed
+func (s HostStatus) hasErrors() bool {
+	if len(s.Errors) > 0 {
+		return true
+	}
+	for _, feature := range s.Features {
+		if len(feature.Errors) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+//Marks A Host As Being Collected, False If It Already Was, So Its Collections Don't Overlap
+func StartCollecting(ip string) bool {
+	collectingMutex.Lock()
+	defer collectingMutex.Unlock()
+	if collecting[ip] {
+		return false
+	}
+	collecting[ip] = true
+	return true
+}
+
+//Marks The End Of A Host's Collection
+func StopCollecting(ip string) {
+	collectingMutex.Lock()
+	defer collectingMutex.Unlock()
+	delete(collecting, ip)
+}
+
+func setStatus(status HostStatus) {
+	statusesMutex.Lock()
+	defer statusesMutex.Unlock()
+	statuses[status.IP] = status
+}
@@ -1,42 +1,248 @@
 package log
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 )
 
+//------------------------------------------------------------------------------------------
+//-----------------------------------------STRUCTS------------------------------------------
+//------------------------------------------------------------------------------------------
+//Key-Value Pairs Attached To A Message, Such As "host", "device_type", "feature", "oid" Or "duration"
+type Fields map[string]interface{}
+
+//Logs Messages With The Same Fields
+type Logger struct {
+	fields Fields
+}
+
+//Writes A Message To Where The Messages Go, Such As Syslog Or The Journal
+type target func(lvl int, msg string, fields Fields) error
+
+//------------------------------------------------------------------------------------------
+//----------------------------------------CONSTANTS-----------------------------------------
+//------------------------------------------------------------------------------------------
+//Levels Of The Messages, From The Most Verbose
+const (
+	DebugLevel = iota
+	InfoLevel
+	WarnLevel
+	ErrorLevel
+	FatalLevel
+)
+
 //------------------------------------------------------------------------------------------
 //----------------------------------------VARIABLES-----------------------------------------
 //------------------------------------------------------------------------------------------
-var debug bool
+var level = InfoLevel
+var format = "text"
+var output io.Writer = os.Stdout
+var send target
+var debugHosts = map[string]bool{}
+var mutex sync.Mutex
+
+var levelNames = []string{"debug", "info", "warn", "error", "fatal"}
+
+//Prefixes Of The Text Format, After The Time
+var levelPrefixes = []string{"", ": ", " - WARNING: ", " - ERROR: ", " - FATAL: "}
 
 //------------------------------------------------------------------------------------------
 //----------------------------------------FUNCTIONS-----------------------------------------
 //------------------------------------------------------------------------------------------
 //Sets The Debug Flag
 func Debug(d bool){
-	debug = d
+	if d {
+		level = DebugLevel
+	} else if level == DebugLevel {
+		level = InfoLevel
+	}
+}
+
+//Sets Where The Messages Are Printed, Used When The Standard Output Carries Data
+func SetOutput(w io.Writer){
+	output = w
+}
+
+//Sets The Minimum Level ("debug", "info", "warn" Or "error"), The Format ("text" Or "json")
+//And The Target ("stdout", "stderr", "syslog" Or "journald"), Empty Values Are Left Unchanged
+func Configure(lvl, form, to string) error {
+	if lvl != "" {
+		found := false
+		for l, name := range levelNames[:FatalLevel] {
+			if strings.EqualFold(lvl, name) || (strings.EqualFold(lvl, "warning") && l == WarnLevel) {
+				level, found = l, true
+			}
+		}
+		if !found {
+			return fmt.Errorf("Unknown Log Level \"%s\", Must Be \"debug\", \"info\", \"warn\" Or \"error\"", lvl)
+		}
+	}
+	switch strings.ToLower(form) {
+	case "":
+	case "text", "json":
+		format = strings.ToLower(form)
+	default:
+		return fmt.Errorf("Unknown Log Format \"%s\", Must Be \"text\" Or \"json\"", form)
+	}
+	switch strings.ToLower(to) {
+	case "":
+	case "stdout":
+		output, send = os.Stdout, nil
+	case "stderr":
+		output, send = os.Stderr, nil
+	case "syslog":
+		t, err := syslogTarget()
+		if err != nil {
+			return fmt.Errorf("Could Not Connect To Syslog: %v", err)
+		}
+		send = t
+	case "journald":
+		t, err := journaldTarget()
+		if err != nil {
+			return fmt.Errorf("Could Not Connect To The Journal: %v", err)
+		}
+		send = t
+	default:
+		return fmt.Errorf("Unknown Log Target \"%s\", Must Be \"stdout\", \"stderr\", \"syslog\" Or \"journald\"", to)
+	}
+	return nil
+}
+
+//Prints The Debug Messages About These Hosts, By The "host" Field, Even Without The Debug Flag
+func DebugHosts(hosts []string){
+	mutex.Lock()
+	defer mutex.Unlock()
+	debugHosts = map[string]bool{}
+	for _, host := range hosts {
+		debugHosts[host] = true
+	}
+}
+
+//Creates A Logger Whose Messages Carry The Given Fields
+func With(fields Fields) *Logger {
+	return &Logger{fields: fields}
+}
+
+//Creates A Logger With More Fields, Besides The Ones Of This Logger
+func (l *Logger) With(fields Fields) *Logger {
+	merged := Fields{}
+	for k, v := range l.fields {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	return &Logger{fields: merged}
+}
+
+func (l *Logger) Debug(str string){
+	log(DebugLevel, str, l.fields)
+}
+
+func (l *Logger) Info(str string){
+	log(InfoLevel, str, l.fields)
+}
+
+func (l *Logger) Warn(str string){
+	log(WarnLevel, str, l.fields)
+}
+
+func (l *Logger) Error(str string){
+	log(ErrorLevel, str, l.fields)
 }
 
 //Prints A Debug Message If The Flag Is Active
 func DebugLog(str string){
-	if debug {
-		log(str, "")
-	}
+	log(DebugLevel, str, nil)
 }
 
 func Log(str string){
-	log(str, now() + ": ")
+	log(InfoLevel, str, nil)
+}
+
+func WarnLog(str string){
+	log(WarnLevel, str, nil)
+}
+
+func ErrorLog(str string){
+	log(ErrorLevel, str, nil)
 }
 
+//Prints The Message And Exits, Only For The Application Itself, Libraries Return Errors Instead
 func FatalLog(str string){
-	log(str, now() + " - FATAL: ")
+	log(FatalLevel, str, nil)
 	os.Exit(1)
 }
 
-func log(str string, prefix string){
-	fmt.Printf("%s%s\n", prefix, str)
+func log(lvl int, str string, fields Fields){
+	if lvl < level && !(lvl == DebugLevel && isDebugHost(fields)) {
+		return
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	if send != nil {
+		if err := send(lvl, str, fields); err == nil {
+			return
+		}
+	}
+	fmt.Fprintln(output, line(lvl, str, fields, true))
+}
+
+func isDebugHost(fields Fields) bool {
+	if len(fields) == 0 {
+		return false
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	return debugHosts[fmt.Sprint(fields["host"])]
+}
+
+//Formats A Message As Text Or JSON, With The Time If Asked
+func line(lvl int, str string, fields Fields, withTime bool) string {
+	if format == "json" {
+		entry := map[string]interface{}{}
+		for k, v := range fields {
+			entry[k] = v
+		}
+		if d, ok := entry["duration"].(time.Duration); ok {
+			entry["duration"] = d.Seconds()
+		}
+		if withTime {
+			entry["time"] = time.Now().Format(time.RFC3339Nano)
+		}
+		entry["level"], entry["msg"] = levelNames[lvl], str
+		if content, err := json.Marshal(entry); err == nil {
+			return string(content)
+		}
+	}
+
+	var b strings.Builder
+	if withTime {
+		if lvl != DebugLevel {
+			b.WriteString(now())
+		}
+		b.WriteString(levelPrefixes[lvl])
+	}
+	b.WriteString(str)
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v := fmt.Sprint(fields[k])
+		if strings.ContainsAny(v, " \"=") {
+			v = fmt.Sprintf("%q", v)
+		}
+		fmt.Fprintf(&b, " %s=%s", k, v)
+	}
+	return b.String()
 }
 
 func now()string{
@@ -44,4 +250,4 @@ func now()string{
 	return fmt.Sprintf("%d/%02d/%02d %02d:%02d:%02d",
 		t.Year(), t.Month(), t.Day(),
 		t.Hour(), t.Minute(), t.Second())
-}
\ No newline at end of file
+}
@@ -0,0 +1,97 @@
+//go:build !windows && !plan9
+
+package log
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"log/syslog"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+//------------------------------------------------------------------------------------------
+//----------------------------------------VARIABLES-----------------------------------------
+//------------------------------------------------------------------------------------------
+//Socket Of The Journal's Native Protocol
+const journalSocket = "/run/systemd/journal/socket"
+
+//Syslog Priorities Of Each Level
+var priorities = []int{7, 6, 4, 3, 2}
+
+//Characters Not Allowed In The Journal's Field Names
+var journalInvalid = regexp.MustCompile(`[^A-Z0-9_]`)
+
+//------------------------------------------------------------------------------------------
+//----------------------------------------FUNCTIONS-----------------------------------------
+//------------------------------------------------------------------------------------------
+//Sends The Messages To The Local Syslog, Formatted Without The Time, Which Syslog Adds
+func syslogTarget() (target, error) {
+	w, err := syslog.New(syslog.LOG_DAEMON|syslog.LOG_INFO, identifier())
+	if err != nil {
+		return nil, err
+	}
+	return func(lvl int, msg string, fields Fields) error {
+		text := line(lvl, msg, fields, false)
+		switch lvl {
+		case DebugLevel:
+			return w.Debug(text)
+		case InfoLevel:
+			return w.Info(text)
+		case WarnLevel:
+			return w.Warning(text)
+		case ErrorLevel:
+			return w.Err(text)
+		}
+		return w.Crit(text)
+	}, nil
+}
+
+//Sends The Messages To The Journal With Its Native Protocol, Each Field As A Journal Field
+func journaldTarget() (target, error) {
+	conn, err := net.Dial("unixgram", journalSocket)
+	if err != nil {
+		return nil, err
+	}
+	id := identifier()
+	return func(lvl int, msg string, fields Fields) error {
+		var b bytes.Buffer
+		journalField(&b, "MESSAGE", msg)
+		journalField(&b, "PRIORITY", fmt.Sprint(priorities[lvl]))
+		journalField(&b, "SYSLOG_IDENTIFIER", id)
+		keys := make([]string, 0, len(fields))
+		for k := range fields {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			name := journalInvalid.ReplaceAllString(strings.ToUpper(k), "_")
+			if name == "" || name[0] == '_' {
+				name = "F" + name
+			}
+			journalField(&b, name, fmt.Sprint(fields[k]))
+		}
+		_, err := conn.Write(b.Bytes())
+		return err
+	}, nil
+}
+
+//Writes A Field Of The Journal's Native Protocol, With Its Length If The Value Has Line Breaks
+func journalField(b *bytes.Buffer, name, value string) {
+	if !strings.Contains(value, "\n") {
+		fmt.Fprintf(b, "%s=%s\n", name, value)
+		return
+	}
+	b.WriteString(name + "\n")
+	binary.Write(b, binary.LittleEndian, uint64(len(value)))
+	b.WriteString(value + "\n")
+}
+
+func identifier() string {
+	return filepath.Base(os.Args[0])
+}
@@ -0,0 +1,18 @@
+//go:build windows || plan9
+
+package log
+
+import (
+	"fmt"
+)
+
+//------------------------------------------------------------------------------------------
+//----------------------------------------FUNCTIONS-----------------------------------------
+//------------------------------------------------------------------------------------------
+func syslogTarget() (target, error) {
+	return nil, fmt.Errorf("Syslog Is Not Available On This System")
+}
+
+func journaldTarget() (target, error) {
+	return nil, fmt.Errorf("The Journal Is Not Available On This System")
+}
@@ -6,11 +6,28 @@ package snmp
 import (
 	"fmt"
 	"strings"
+	"sync"
 
 	. "github.com/fccn/gofetch-snmp/log"
 	g "github.com/soniah/gosnmp"
 )
 
+//------------------------------------------------------------------------------------------
+//-----------------------------------------STRUCTS------------------------------------------
+//------------------------------------------------------------------------------------------
+//Errors Of The Requests Made By One Owner, Such As A Collection, Until They Are Taken
+//Requests Made For Another Owner To The Same Target, Such As A Trap's Or A Probe's, Are Kept Apart
+type Errors struct {
+	mutex  sync.Mutex
+	errors []string
+}
+
+//------------------------------------------------------------------------------------------
+//----------------------------------------CONSTANTS-----------------------------------------
+//------------------------------------------------------------------------------------------
+//Maximum Number Of Errors Kept For Each Owner
+const maxFailures = 100
+
 //------------------------------------------------------------------------------------------
 //----------------------------------------FUNCTIONS-----------------------------------------
 //------------------------------------------------------------------------------------------
@@ -35,24 +52,68 @@ func hasPrefix(pdu g.SnmpPDU, prefix string) bool {
 	return strings.HasPrefix(pdu.Name, prefix)
 }
 
-func WalkAll(snmpConf g.GoSNMP, bulk bool, oid string) (result []g.SnmpPDU) {
+//The Errors Of The Requests Are Logged And Kept In "errors", Unless It Is Nil
+func WalkAll(errors *Errors, snmpConf g.GoSNMP, bulk bool, oid string) (result []g.SnmpPDU) {
 	var err error
 	if bulk {
 		if result, err = snmpConf.BulkWalkAll(oid); err != nil {
-			Log(fmt.Sprintf("%s - Could Not Perform Snmp BulkWalkAll - %s: %s", snmpConf.Target, oid, err.Error()))
+			errors.fail(snmpConf.Target, "BulkWalkAll", oid, err)
 		}
 	} else {
 		if result, err = snmpConf.WalkAll(oid); err != nil {
-			Log(fmt.Sprintf("%s - Could Not Perform Snmp WalkAll - %s: %s", snmpConf.Target, oid, err.Error()))
+			errors.fail(snmpConf.Target, "WalkAll", oid, err)
 		}
 	}
 	return
 }
 
-func Get(snmpConf g.GoSNMP, oids []string) (result *g.SnmpPacket) {
+func Get(errors *Errors, snmpConf g.GoSNMP, oids []string) (result *g.SnmpPacket) {
 	var err error
 	if result, err = snmpConf.Get(oids); err != nil {
-		Log(fmt.Sprintf("%s - Could Not Perform Snmp Get - %s: %s", snmpConf.Target, oids, err.Error()))
+		errors.fail(snmpConf.Target, "Get", oids, err)
+	}
+	return
+}
+
+func GetNext(errors *Errors, snmpConf g.GoSNMP, oids []string) (result *g.SnmpPacket) {
+	var err error
+	if result, err = snmpConf.GetNext(oids); err != nil {
+		errors.fail(snmpConf.Target, "GetNext", oids, err)
 	}
 	return
 }
+
+//Checks If The Device Implements Any Object Under The Given OID, "ok" Is False If It Didn't Answer
+func Implements(errors *Errors, snmpConf g.GoSNMP, oid string) (implemented bool, ok bool) {
+	result := GetNext(errors, snmpConf, []string{oid})
+	if result == nil || len(result.Variables) == 0 {
+		return false, false
+	}
+	pdu := result.Variables[0]
+	switch pdu.Type {
+	case g.NoSuchObject, g.NoSuchInstance, g.EndOfMibView:
+		return false, true
+	}
+	return hasPrefix(pdu, oid), true
+}
+
+//Logs The Error Of A Request And Keeps It, To Be Taken By Whoever Reports The Status Of The Owner's Requests
+func (e *Errors) fail(target, request string, oids interface{}, err error) {
+	With(Fields{"host": target, "oid": fmt.Sprint(oids)}).Error(fmt.Sprintf("Could Not Perform Snmp %s: %s", request, err.Error()))
+	if e == nil {
+		return
+	}
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if len(e.errors) < maxFailures {
+		e.errors = append(e.errors, fmt.Sprintf("Could Not Perform Snmp %s - %s: %s", request, oids, err.Error()))
+	}
+}
+
+//Returns The Errors Of The Owner's Requests Since The Last Call, Clearing Them
+func (e *Errors) Take() (errors []string) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	errors, e.errors = e.errors, nil
+	return
+}
@@ -0,0 +1,600 @@
+package trap
+
+//------------------------------------------------------------------------------------------
+//-----------------------------------------IMPORTS------------------------------------------
+//------------------------------------------------------------------------------------------
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fccn/gofetch-snmp/config"
+	"github.com/fccn/gofetch-snmp/data"
+	"github.com/fccn/gofetch-snmp/devices"
+	. "github.com/fccn/gofetch-snmp/log"
+	g "github.com/soniah/gosnmp"
+)
+
+//------------------------------------------------------------------------------------------
+//----------------------------------------CONSTANTS-----------------------------------------
+//------------------------------------------------------------------------------------------
+const (
+	sysUpTime   = ".1.3.6.1.2.1.1.3.0"
+	snmpTrapOID = ".1.3.6.1.6.3.1.1.4.1.0"
+	coldStart   = ".1.3.6.1.6.3.1.1.5.1"
+	warmStart   = ".1.3.6.1.6.3.1.1.5.2"
+	linkDown    = ".1.3.6.1.6.3.1.1.5.3"
+	linkUp      = ".1.3.6.1.6.3.1.1.5.4"
+	genericV1   = ".1.3.6.1.6.3.1.1.5" //v1 Generic Traps Are Converted To The Ones Under This Prefix
+	enterprise  = 6                    //v1 Generic Trap Type Of Enterprise Specific Traps
+	workers     = 8                    //Traps Processed At Once, Each May Ask Its Device For Its Tags
+	queueSize   = 1000                 //Traps Waiting To Be Processed, Beyond Which They Are Dropped
+)
+
+//------------------------------------------------------------------------------------------
+//-----------------------------------------STRUCTS------------------------------------------
+//------------------------------------------------------------------------------------------
+type receiver struct {
+	conf  config.Traps
+	hosts map[string]devices.Host //Configured Hosts By IP
+	conn  *net.UDPConn
+	queue chan trap //Traps Received, Waiting For The Workers
+
+	closing chan struct{}  //Closed When The Receiver Is Closed
+	working sync.WaitGroup //Workers Still Processing The Queue
+}
+
+//A Trap Waiting To Be Processed
+type trap struct {
+	ip     string
+	host   devices.Host
+	known  bool
+	packet *g.SnmpPacket
+}
+
+//Turns The Variables Of A Trap Into Events
+type handler func(vars []g.SnmpPDU) []data.Event
+
+//------------------------------------------------------------------------------------------
+//----------------------------------------VARIABLES-----------------------------------------
+//------------------------------------------------------------------------------------------
+//Handlers Of The Known Traps, By Trap OID, All Others Become A Generic "trap" Event
+var handlers = map[string]handler{
+	coldStart: restart,
+	warmStart: restart,
+	linkDown:  link(false),
+	linkUp:    link(true),
+
+	//BGP4-MIB, In Its v1, v2 And RFC 4273 Forms
+	".1.3.6.1.2.1.15.7.1":   bgpEstablished,
+	".1.3.6.1.2.1.15.7.0.1": bgpEstablished,
+	".1.3.6.1.2.1.15.0.1":   bgpEstablished,
+	".1.3.6.1.2.1.15.7.2":   bgpBackward,
+	".1.3.6.1.2.1.15.7.0.2": bgpBackward,
+	".1.3.6.1.2.1.15.0.2":   bgpBackward,
+
+	//CISCO-BGP4-MIB
+	".1.3.6.1.4.1.9.9.187.0.1": bgpStateChange,
+	".1.3.6.1.4.1.9.9.187.0.2": bgpBackward,
+	".1.3.6.1.4.1.9.9.187.0.5": bgpEstablished,
+	".1.3.6.1.4.1.9.9.187.0.6": bgpBackward,
+	".1.3.6.1.4.1.9.9.187.0.7": bgpStateChange,
+	".1.3.6.1.4.1.9.9.187.0.8": bgpBackward,
+
+	//CISCO-ENVMON-MIB
+	".1.3.6.1.4.1.9.9.13.3.0.1": envmon("shutdown"),
+	".1.3.6.1.4.1.9.9.13.3.0.2": envmon("voltage"),
+	".1.3.6.1.4.1.9.9.13.3.0.3": envmon("temperature"),
+	".1.3.6.1.4.1.9.9.13.3.0.4": envmon("fan"),
+	".1.3.6.1.4.1.9.9.13.3.0.5": envmon("power_supply"),
+	".1.3.6.1.4.1.9.9.13.3.0.6": envmon("voltage"),
+	".1.3.6.1.4.1.9.9.13.3.0.7": envmon("temperature"),
+	".1.3.6.1.4.1.9.9.13.3.0.8": envmon("fan"),
+	".1.3.6.1.4.1.9.9.13.3.0.9": envmon("power_supply"),
+}
+
+//The Receiver Started By Listen, Nil If Traps Are Not Received
+var active *receiver
+
+//------------------------------------------------------------------------------------------
+//----------------------------------------FUNCTIONS-----------------------------------------
+//------------------------------------------------------------------------------------------
+//Starts Receiving Traps And Informs In The Background, Writing Them As Events To The Outputs
+func Listen(conf *config.Traps, hosts devices.Hosts) error {
+	if conf == nil {
+		return nil
+	}
+	r := &receiver{conf: *conf, hosts: map[string]devices.Host{}, queue: make(chan trap, queueSize), closing: make(chan struct{})}
+	if r.conf.Address == "" {
+		r.conf.Address = ":162"
+	}
+	for _, host := range hosts.Hosts {
+		r.hosts[host.IP] = host
+	}
+
+	addr, err := net.ResolveUDPAddr("udp", r.conf.Address)
+	if err == nil {
+		r.conn, err = net.ListenUDP("udp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("Could Not Listen For Traps: %v", err)
+	}
+	Log(fmt.Sprintf("Listening For Traps On %s", r.conf.Address))
+
+	r.working.Add(workers)
+	for i := 0; i < workers; i++ {
+		go r.work()
+	}
+	go r.run()
+	active = r
+	return nil
+}
+
+//Stops Receiving Traps And Waits Until The Queued Ones Are Written To The Outputs, Or The Timeout Ends, Telling If They Were
+func Close(timeout time.Duration) bool {
+	if active == nil {
+		return true
+	}
+	close(active.closing)
+	active.conn.Close()
+
+	done := make(chan struct{})
+	go func() {
+		active.working.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		WarnLog("Stopped Receiving Traps, Some Were Not Processed")
+		return false
+	}
+}
+
+func (r *receiver) run() {
+	buffer := make([]byte, 65535)
+	for {
+		n, addr, err := r.conn.ReadFromUDP(buffer)
+		if err != nil {
+			//Only This Goroutine Queues Traps, So The Queue Can Be Closed Once It Stops
+			select {
+			case <-r.closing:
+				close(r.queue)
+				return
+			default:
+			}
+			ErrorLog(fmt.Sprintf("Could Not Receive Trap: %s", err.Error()))
+			continue
+		}
+		msg := make([]byte, n)
+		copy(msg, buffer[:n])
+		r.receive(msg, addr)
+	}
+}
+
+//Decodes A Trap With The Credentials Of The Host That Sent It, Answering It If It Is An Inform
+func (r *receiver) receive(msg []byte, addr *net.UDPAddr) {
+	ip := addr.IP.String()
+	host, known := r.hosts[ip]
+
+	snmpConf := g.GoSNMP{}
+	if known {
+		snmpConf = devices.NewDevice(host).SnmpConf
+	}
+	packet := snmpConf.UnmarshalTrap(msg, false)
+	if packet == nil {
+		With(Fields{"host": ip}).Debug("Could Not Decode Trap")
+		return
+	}
+
+	//v1 Traps May Be Relayed, The Agent Address Identifies The Device
+	if !known && packet.Version == g.Version1 && packet.AgentAddress != "" {
+		if host, known = r.hosts[packet.AgentAddress]; known {
+			ip = packet.AgentAddress
+		}
+	}
+	log := With(Fields{"host": ip})
+	switch {
+	case !known && (packet.Version == g.Version3 || !r.conf.Unknown):
+		log.Debug("Dropped Trap From Unknown Address")
+		return
+	case packet.Version != g.Version3 && !r.community(host, known, packet.Community):
+		log.Warn("Dropped Trap With Wrong Community")
+		return
+	}
+
+	//Answering A v3 Inform Needs The Receiver To Be The Authoritative Engine, Which It Is Not
+	if packet.PDUType == g.InformRequest && packet.Version == g.Version3 {
+		log.Warn("Dropped SNMPv3 Inform, Only v2c Informs Are Supported")
+		return
+	}
+	if packet.PDUType == g.InformRequest {
+		packet.PDUType = g.GetResponse
+		packet.Error = g.NoError
+		packet.ErrorIndex = 0
+		if response, err := packet.MarshalMsg(); err != nil {
+			log.Error("Could Not Encode Inform Response: " + err.Error())
+		} else if _, err := r.conn.WriteToUDP(response, addr); err != nil {
+			log.Error("Could Not Send Inform Response: " + err.Error())
+		}
+	}
+
+	//Getting The Device Tags May Need To Ask The Device, Which Must Not Hold The Receiver
+	select {
+	case r.queue <- trap{ip, host, known, packet}:
+	default:
+		log.Warn(fmt.Sprintf("Dropped Trap, %d Traps Are Waiting To Be Processed", queueSize))
+	}
+}
+
+//Processes The Queued Traps, A Few At A Time
+func (r *receiver) work() {
+	defer r.working.Done()
+	for t := range r.queue {
+		r.process(t.ip, t.host, t.known, t.packet)
+	}
+}
+
+//Checks The Community Of A v1/v2c Trap Against The Host's Trap Community, Or Else The Receiver's, Accepting Any If Neither Is Set
+func (r *receiver) community(host devices.Host, known bool, community string) bool {
+	expected := r.conf.Community
+	if known && host.SnmpConfig.TrapCommunity != "" {
+		expected = host.SnmpConfig.TrapCommunity
+	}
+	return expected == "" || community == expected
+}
+
+//Builds The Events Of A Trap And Queues Them To The Outputs
+func (r *receiver) process(ip string, host devices.Host, known bool, packet *g.SnmpPacket) {
+	oid := trapOID(packet)
+	var events []data.Event
+	if h, ok := handlers[oid]; ok {
+		events = h(packet.Variables)
+	} else {
+		events = []data.Event{generic(packet.Variables)}
+	}
+	for i := range events {
+		events[i].Source = "trap"
+		if events[i].Tags == nil {
+			events[i].Tags = map[string]string{}
+		}
+		events[i].Tags["trap_oid"] = oid
+	}
+
+	dat := data.NewData()
+	dat.SetTimestamp(time.Now())
+	if known {
+		for k, v := range devices.HostTags(host) {
+			dat.AddTag(k, v)
+		}
+	} else {
+		dat.AddTag("device_name", ip)
+		dat.AddTag("device_ip", ip)
+		dat.AddTag("device_type", "unknown")
+	}
+	dat.AddEvents(events)
+	data.NotifyEvents(&dat, events)
+
+	With(Fields{"host": ip, "oid": oid}).Debug("Received Trap")
+	data.BufferWrite([]*data.Data{&dat})
+}
+
+//Gets The Trap OID, Converting v1 Traps As In RFC 3584
+func trapOID(packet *g.SnmpPacket) string {
+	if packet.Version == g.Version1 {
+		if packet.GenericTrap == enterprise {
+			return dotted(packet.Enterprise) + ".0." + strconv.Itoa(packet.SpecificTrap)
+		}
+		return genericV1 + "." + strconv.Itoa(packet.GenericTrap+1)
+	}
+	for _, v := range packet.Variables {
+		if dotted(v.Name) == snmpTrapOID {
+			if oid, ok := v.Value.(string); ok {
+				return dotted(oid)
+			}
+		}
+	}
+	return ""
+}
+
+//------------------------------------------------------------------------------------------
+//-----------------------------------------HANDLERS-----------------------------------------
+//------------------------------------------------------------------------------------------
+func restart(vars []g.SnmpPDU) []data.Event {
+	return []data.Event{{
+		Type:     "device_restart",
+		Severity: data.WARNING,
+		Message:  "Device Restarted",
+	}}
+}
+
+//Builds The Handler Of The linkUp Or linkDown Traps
+func link(up bool) handler {
+	//---------------------------------------OIDs---------------------------------------
+	const ifIndex = ".1.3.6.1.2.1.2.2.1.1"
+	const ifDescr = ".1.3.6.1.2.1.2.2.1.2"
+	const ifAdminStatus = ".1.3.6.1.2.1.2.2.1.7"
+	const ifOperStatus = ".1.3.6.1.2.1.2.2.1.8"
+	const ifName = ".1.3.6.1.2.1.31.1.1.1.1"
+	//--------------------------------Result Processing---------------------------------
+	return func(vars []g.SnmpPDU) []data.Event {
+		e := data.Event{Tags: map[string]string{}, Fields: map[string]interface{}{}}
+		for _, v := range vars {
+			name := dotted(v.Name)
+			switch {
+			case strings.HasPrefix(name, ifIndex+"."):
+				e.Tags["interface_index"] = toString(v.Value)
+			case strings.HasPrefix(name, ifDescr+"."):
+				e.Tags["interface_descr"] = toString(v.Value)
+			case strings.HasPrefix(name, ifName+"."):
+				e.Tags["interface_name"] = toString(v.Value)
+			case strings.HasPrefix(name, ifAdminStatus+"."):
+				e.Fields["interface_admin_status"] = toInt(v.Value)
+				e.Tags["interface_index"] = strings.TrimPrefix(name, ifAdminStatus+".")
+			case strings.HasPrefix(name, ifOperStatus+"."):
+				e.Fields["interface_oper_status"] = toInt(v.Value)
+				e.Tags["interface_index"] = strings.TrimPrefix(name, ifOperStatus+".")
+			}
+		}
+
+		//Identify The Interface By The Most Readable Tag Available
+		iface := e.Tags["interface_index"]
+		for _, tag := range []string{"interface_descr", "interface_name"} {
+			if e.Tags[tag] != "" {
+				iface = e.Tags[tag]
+			}
+		}
+
+		if up {
+			e.Type, e.Severity, e.Message = "link_up", data.INFO, fmt.Sprintf("Interface %s Is Up", iface)
+		} else {
+			e.Type, e.Severity, e.Message = "link_down", data.WARNING, fmt.Sprintf("Interface %s Is Down", iface)
+			//Administratively Down Interfaces Were Shut On Purpose
+			if e.Fields["interface_admin_status"] == 2 {
+				e.Severity = data.INFO
+			}
+		}
+		if len(e.Fields) == 0 {
+			e.Fields = nil
+		}
+		return []data.Event{e}
+	}
+}
+
+func bgpEstablished(vars []g.SnmpPDU) []data.Event {
+	e := bgpEvent(vars)
+	e.Type, e.Severity = "bgp_established", data.INFO
+	e.Message = fmt.Sprintf("BGP Neighbour %s Is Established", e.Tags["bgp_neighbour"])
+	return []data.Event{e}
+}
+
+func bgpBackward(vars []g.SnmpPDU) []data.Event {
+	e := bgpEvent(vars)
+	e.Type, e.Severity = "bgp_backward_transition", data.CRITICAL
+	e.Message = fmt.Sprintf("BGP Neighbour %s Left The Established State", e.Tags["bgp_neighbour"])
+	if state, ok := e.Fields["bgp_peer_state"]; ok {
+		e.Message += ", Now " + bgpStates[state.(int)]
+	}
+	return []data.Event{e}
+}
+
+func bgpStateChange(vars []g.SnmpPDU) []data.Event {
+	e := bgpEvent(vars)
+	state, _ := e.Fields["bgp_peer_state"].(int)
+	if state == 6 {
+		return bgpEstablished(vars)
+	}
+	e.Type, e.Severity = "bgp_state_change", data.WARNING
+	e.Message = fmt.Sprintf("BGP Neighbour %s Is Now %s", e.Tags["bgp_neighbour"], bgpStates[state])
+	return []data.Event{e}
+}
+
+//States Of The BGP Finite State Machine
+var bgpStates = map[int]string{
+	0: "Unknown",
+	1: "Idle",
+	2: "Connect",
+	3: "Active",
+	4: "OpenSent",
+	5: "OpenConfirm",
+	6: "Established",
+}
+
+//Gets The Neighbour And Its State From The BGP4-MIB Or CISCO-BGP4-MIB Variables
+func bgpEvent(vars []g.SnmpPDU) data.Event {
+	//---------------------------------------OIDs---------------------------------------
+	const bgpPeerState = ".1.3.6.1.2.1.15.3.1.2"
+	const bgpPeerLastError = ".1.3.6.1.2.1.15.3.1.14"
+	const cbgpPeerState = ".1.3.6.1.4.1.9.9.187.1.2.1.1.3"
+	const cbgpPeerLastErrorTxt = ".1.3.6.1.4.1.9.9.187.1.2.1.1.7"
+	const cbgpPeer2State = ".1.3.6.1.4.1.9.9.187.1.2.5.1.3"
+	const cbgpPeer2LastErrorTxt = ".1.3.6.1.4.1.9.9.187.1.2.5.1.28"
+	//--------------------------------Result Processing---------------------------------
+	e := data.Event{Tags: map[string]string{}, Fields: map[string]interface{}{}}
+	for _, v := range vars {
+		name := dotted(v.Name)
+		switch {
+		case strings.HasPrefix(name, bgpPeerState+"."), strings.HasPrefix(name, cbgpPeerState+"."):
+			e.Tags["bgp_neighbour"] = lastOctets(name, 4)
+			e.Fields["bgp_peer_state"] = toInt(v.Value)
+		case strings.HasPrefix(name, cbgpPeer2State+"."):
+			e.Tags["bgp_neighbour"] = peer2Address(strings.TrimPrefix(name, cbgpPeer2State+"."))
+			e.Fields["bgp_peer_state"] = toInt(v.Value)
+		case strings.HasPrefix(name, bgpPeerLastError+"."):
+			if code, ok := v.Value.([]byte); ok && len(code) == 2 {
+				e.Fields["bgp_last_error"] = fmt.Sprintf("%d/%d", code[0], code[1])
+			}
+		case strings.HasPrefix(name, cbgpPeerLastErrorTxt+"."), strings.HasPrefix(name, cbgpPeer2LastErrorTxt+"."):
+			e.Fields["bgp_last_error"] = toString(v.Value)
+		}
+	}
+	if len(e.Fields) == 0 {
+		e.Fields = nil
+	}
+	return e
+}
+
+//Builds The Handler Of A CISCO-ENVMON-MIB Notification
+func envmon(kind string) handler {
+	//---------------------------------------OIDs---------------------------------------
+	const ciscoEnvMonVoltageStatusDescr = ".1.3.6.1.4.1.9.9.13.1.2.1.2"
+	const ciscoEnvMonVoltageState = ".1.3.6.1.4.1.9.9.13.1.2.1.7"
+	const ciscoEnvMonTemperatureStatusDescr = ".1.3.6.1.4.1.9.9.13.1.3.1.2"
+	const ciscoEnvMonTemperatureStatusValue = ".1.3.6.1.4.1.9.9.13.1.3.1.3"
+	const ciscoEnvMonTemperatureState = ".1.3.6.1.4.1.9.9.13.1.3.1.6"
+	const ciscoEnvMonFanStatusDescr = ".1.3.6.1.4.1.9.9.13.1.4.1.2"
+	const ciscoEnvMonFanState = ".1.3.6.1.4.1.9.9.13.1.4.1.3"
+	const ciscoEnvMonSupplyStatusDescr = ".1.3.6.1.4.1.9.9.13.1.5.1.2"
+	const ciscoEnvMonSupplyState = ".1.3.6.1.4.1.9.9.13.1.5.1.3"
+	//--------------------------------Result Processing---------------------------------
+	return func(vars []g.SnmpPDU) []data.Event {
+		e := data.Event{
+			Type:     "envmon_" + kind,
+			Severity: data.WARNING,
+			Tags:     map[string]string{},
+			Fields:   map[string]interface{}{},
+		}
+		if kind == "shutdown" {
+			e.Severity = data.CRITICAL
+		}
+		for _, v := range vars {
+			name := dotted(v.Name)
+			switch {
+			case hasAnyPrefix(name, ciscoEnvMonVoltageStatusDescr, ciscoEnvMonTemperatureStatusDescr, ciscoEnvMonFanStatusDescr, ciscoEnvMonSupplyStatusDescr):
+				e.Tags["sensor_descr"] = toString(v.Value)
+			case hasAnyPrefix(name, ciscoEnvMonTemperatureStatusValue):
+				e.Fields["sensor_value_celsius"] = float64(toInt(v.Value))
+			case hasAnyPrefix(name, ciscoEnvMonVoltageState, ciscoEnvMonTemperatureState, ciscoEnvMonFanState, ciscoEnvMonSupplyState):
+				state := toInt(v.Value)
+				e.Fields["sensor_state"] = state
+				if severity, ok := envmonSeverities[state]; ok {
+					e.Severity = severity
+				}
+			}
+		}
+
+		e.Message = fmt.Sprintf("Environmental Monitor Notification: %s", strings.Replace(kind, "_", " ", -1))
+		if e.Tags["sensor_descr"] != "" {
+			e.Message += " - " + e.Tags["sensor_descr"]
+		}
+		if state, ok := e.Fields["sensor_state"]; ok {
+			if name, known := envmonStates[state.(int)]; known {
+				e.Message += " Is " + name
+			} else {
+				e.Message += fmt.Sprintf(" Is In State %d", state)
+			}
+		}
+		if len(e.Fields) == 0 {
+			e.Fields = nil
+		}
+		return []data.Event{e}
+	}
+}
+
+//States Of The CISCO-ENVMON-MIB Sensors, And Their Severities
+var envmonStates = map[int]string{
+	1: "Normal",
+	2: "Warning",
+	3: "Critical",
+	4: "Shutdown",
+	5: "Not Present",
+	6: "Not Functioning",
+}
+
+var envmonSeverities = map[int]string{
+	0: data.WARNING,
+	1: data.INFO,
+	2: data.WARNING,
+	3: data.CRITICAL,
+	4: data.CRITICAL,
+	5: data.WARNING,
+	6: data.CRITICAL,
+}
+
+//Any Other Trap, With Its Variables In The Message
+func generic(vars []g.SnmpPDU) data.Event {
+	var values []string
+	for _, v := range vars {
+		if name := dotted(v.Name); name != sysUpTime && name != snmpTrapOID {
+			values = append(values, name+"="+toString(v.Value))
+		}
+	}
+	return data.Event{
+		Type:     "trap",
+		Severity: data.INFO,
+		Message:  strings.Join(values, ", "),
+	}
+}
+
+//------------------------------------------------------------------------------------------
+//------------------------------------------UTILS-------------------------------------------
+//------------------------------------------------------------------------------------------
+//Makes Sure An OID Starts With A "."
+func dotted(oid string) string {
+	if oid != "" && !strings.HasPrefix(oid, ".") {
+		return "." + oid
+	}
+	return oid
+}
+
+func hasAnyPrefix(oid string, prefixes ...string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(oid, prefix+".") {
+			return true
+		}
+	}
+	return false
+}
+
+//Gets The Last Sub-Identifiers Of An OID, Which Hold An IPv4 Address Index
+func lastOctets(oid string, n int) string {
+	split := strings.Split(oid, ".")
+	if len(split) < n {
+		return oid
+	}
+	return strings.Join(split[len(split)-n:], ".")
+}
+
+//Gets The Address From A cbgpPeer2Table Index, "<type>.<length>.<octets>"
+func peer2Address(index string) string {
+	split := strings.Split(index, ".")
+	if len(split) < 2 {
+		return index
+	}
+	var ip net.IP
+	for _, octet := range split[2:] {
+		b, _ := strconv.Atoi(octet)
+		ip = append(ip, byte(b))
+	}
+	return ip.String()
+}
+
+func toString(value interface{}) string {
+	switch v := value.(type) {
+	case []byte:
+		return string(v)
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func toInt(value interface{}) int {
+	switch v := value.(type) {
+	case int:
+		return v
+	case uint:
+		return int(v)
+	case uint32:
+		return int(v)
+	case uint64:
+		return int(v)
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,210 @@
+package trap
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/fccn/gofetch-snmp/config"
+	"github.com/fccn/gofetch-snmp/data"
+	"github.com/fccn/gofetch-snmp/devices"
+	g "github.com/soniah/gosnmp"
+)
+
+func TestTrapOID(t *testing.T) {
+	tests := []struct {
+		packet   g.SnmpPacket
+		expected string
+	}{
+		{g.SnmpPacket{Version: g.Version1, SnmpTrap: g.SnmpTrap{Enterprise: ".1.3.6.1.4.1.9.9.13.3", GenericTrap: enterprise, SpecificTrap: 3}}, ".1.3.6.1.4.1.9.9.13.3.0.3"},
+		{g.SnmpPacket{Version: g.Version1, SnmpTrap: g.SnmpTrap{Enterprise: "1.3.6.1.4.1.9", GenericTrap: enterprise, SpecificTrap: 1}}, ".1.3.6.1.4.1.9.0.1"},
+		{g.SnmpPacket{Version: g.Version1, SnmpTrap: g.SnmpTrap{Enterprise: ".1.3.6.1.4.1.9", GenericTrap: 0}}, coldStart},
+		{g.SnmpPacket{Version: g.Version1, SnmpTrap: g.SnmpTrap{Enterprise: ".1.3.6.1.4.1.9", GenericTrap: 2}}, linkDown},
+		{g.SnmpPacket{Version: g.Version2c, Variables: []g.SnmpPDU{
+			{Name: ".1.3.6.1.2.1.1.3.0", Type: g.TimeTicks, Value: uint32(100)},
+			{Name: "1.3.6.1.6.3.1.1.4.1.0", Type: g.ObjectIdentifier, Value: "1.3.6.1.6.3.1.1.5.4"},
+		}}, linkUp},
+		{g.SnmpPacket{Version: g.Version3, Variables: []g.SnmpPDU{
+			{Name: snmpTrapOID, Type: g.ObjectIdentifier, Value: ".1.3.6.1.2.1.15.0.2"},
+		}}, ".1.3.6.1.2.1.15.0.2"},
+		{g.SnmpPacket{Version: g.Version2c}, ""},
+	}
+	for i, test := range tests {
+		if got := trapOID(&test.packet); got != test.expected {
+			t.Errorf("Case %d - Expected %q, Got %q", i, test.expected, got)
+		}
+	}
+}
+
+func TestLink(t *testing.T) {
+	tests := []struct {
+		up       bool
+		vars     []g.SnmpPDU
+		expected data.Event
+	}{
+		{false, []g.SnmpPDU{
+			{Name: ".1.3.6.1.2.1.2.2.1.1.3", Value: 3},
+			{Name: ".1.3.6.1.2.1.2.2.1.7.3", Value: 1},
+			{Name: ".1.3.6.1.2.1.2.2.1.8.3", Value: 2},
+			{Name: ".1.3.6.1.2.1.2.2.1.2.3", Value: []byte("GigabitEthernet0/1")},
+		}, data.Event{
+			Type: "link_down", Severity: data.WARNING, Message: "Interface GigabitEthernet0/1 Is Down",
+			Tags:   map[string]string{"interface_index": "3", "interface_descr": "GigabitEthernet0/1"},
+			Fields: map[string]interface{}{"interface_admin_status": 1, "interface_oper_status": 2},
+		}},
+		//Administratively Down Interfaces Were Shut On Purpose
+		{false, []g.SnmpPDU{
+			{Name: ".1.3.6.1.2.1.2.2.1.7.4", Value: 2},
+			{Name: ".1.3.6.1.2.1.31.1.1.1.1.4", Value: []byte("Gi0/2")},
+		}, data.Event{
+			Type: "link_down", Severity: data.INFO, Message: "Interface Gi0/2 Is Down",
+			Tags:   map[string]string{"interface_index": "4", "interface_name": "Gi0/2"},
+			Fields: map[string]interface{}{"interface_admin_status": 2},
+		}},
+		//Without Names The Index Identifies The Interface
+		{true, []g.SnmpPDU{
+			{Name: "1.3.6.1.2.1.2.2.1.8.5", Value: 1},
+		}, data.Event{
+			Type: "link_up", Severity: data.INFO, Message: "Interface 5 Is Up",
+			Tags:   map[string]string{"interface_index": "5"},
+			Fields: map[string]interface{}{"interface_oper_status": 1},
+		}},
+		{true, nil, data.Event{Type: "link_up", Severity: data.INFO, Message: "Interface  Is Up", Tags: map[string]string{}}},
+	}
+	for i, test := range tests {
+		events := link(test.up)(test.vars)
+		if len(events) != 1 || fmt.Sprintf("%v", events[0]) != fmt.Sprintf("%v", test.expected) {
+			t.Errorf("Case %d - Expected %v, Got %v", i, test.expected, events)
+		}
+	}
+}
+
+func TestBgp(t *testing.T) {
+	tests := []struct {
+		handler  handler
+		vars     []g.SnmpPDU
+		expected data.Event
+	}{
+		//BGP4-MIB, The Neighbour In The Index And The Last Error As Code And Subcode
+		{bgpBackward, []g.SnmpPDU{
+			{Name: ".1.3.6.1.2.1.15.3.1.14.10.0.0.1", Value: []byte{6, 2}},
+			{Name: ".1.3.6.1.2.1.15.3.1.2.10.0.0.1", Value: 1},
+		}, data.Event{
+			Type: "bgp_backward_transition", Severity: data.CRITICAL, Message: "BGP Neighbour 10.0.0.1 Left The Established State, Now Idle",
+			Tags:   map[string]string{"bgp_neighbour": "10.0.0.1"},
+			Fields: map[string]interface{}{"bgp_peer_state": 1, "bgp_last_error": "6/2"},
+		}},
+		{bgpEstablished, []g.SnmpPDU{
+			{Name: ".1.3.6.1.2.1.15.3.1.2.10.0.0.1", Value: 6},
+		}, data.Event{
+			Type: "bgp_established", Severity: data.INFO, Message: "BGP Neighbour 10.0.0.1 Is Established",
+			Tags:   map[string]string{"bgp_neighbour": "10.0.0.1"},
+			Fields: map[string]interface{}{"bgp_peer_state": 6},
+		}},
+		//CISCO-BGP4-MIB cbgpPeer2Table, The Neighbour Encoded With Its Type And Length
+		{bgpStateChange, []g.SnmpPDU{
+			{Name: ".1.3.6.1.4.1.9.9.187.1.2.5.1.3.1.4.192.0.2.1", Value: 3},
+			{Name: ".1.3.6.1.4.1.9.9.187.1.2.5.1.28.1.4.192.0.2.1", Value: []byte("hold timer expired")},
+		}, data.Event{
+			Type: "bgp_state_change", Severity: data.WARNING, Message: "BGP Neighbour 192.0.2.1 Is Now Active",
+			Tags:   map[string]string{"bgp_neighbour": "192.0.2.1"},
+			Fields: map[string]interface{}{"bgp_peer_state": 3, "bgp_last_error": "hold timer expired"},
+		}},
+		//A State Change To Established Is Reported As Such
+		{bgpStateChange, []g.SnmpPDU{
+			{Name: ".1.3.6.1.4.1.9.9.187.1.2.1.1.3.192.0.2.2", Value: 6},
+		}, data.Event{
+			Type: "bgp_established", Severity: data.INFO, Message: "BGP Neighbour 192.0.2.2 Is Established",
+			Tags:   map[string]string{"bgp_neighbour": "192.0.2.2"},
+			Fields: map[string]interface{}{"bgp_peer_state": 6},
+		}},
+		{bgpBackward, nil, data.Event{
+			Type: "bgp_backward_transition", Severity: data.CRITICAL, Message: "BGP Neighbour  Left The Established State",
+			Tags: map[string]string{},
+		}},
+	}
+	for i, test := range tests {
+		events := test.handler(test.vars)
+		if len(events) != 1 || fmt.Sprintf("%v", events[0]) != fmt.Sprintf("%v", test.expected) {
+			t.Errorf("Case %d - Expected %v, Got %v", i, test.expected, events)
+		}
+	}
+}
+
+func TestEnvmon(t *testing.T) {
+	tests := []struct {
+		kind     string
+		vars     []g.SnmpPDU
+		expected data.Event
+	}{
+		{"temperature", []g.SnmpPDU{
+			{Name: ".1.3.6.1.4.1.9.9.13.1.3.1.2.1", Value: []byte("Inlet")},
+			{Name: ".1.3.6.1.4.1.9.9.13.1.3.1.3.1", Value: 58},
+			{Name: ".1.3.6.1.4.1.9.9.13.1.3.1.6.1", Value: 3},
+		}, data.Event{
+			Type: "envmon_temperature", Severity: data.CRITICAL, Message: "Environmental Monitor Notification: temperature - Inlet Is Critical",
+			Tags:   map[string]string{"sensor_descr": "Inlet"},
+			Fields: map[string]interface{}{"sensor_value_celsius": float64(58), "sensor_state": 3},
+		}},
+		{"power_supply", []g.SnmpPDU{
+			{Name: ".1.3.6.1.4.1.9.9.13.1.5.1.2.2", Value: []byte("PS2")},
+			{Name: ".1.3.6.1.4.1.9.9.13.1.5.1.3.2", Value: 1},
+		}, data.Event{
+			Type: "envmon_power_supply", Severity: data.INFO, Message: "Environmental Monitor Notification: power supply - PS2 Is Normal",
+			Tags:   map[string]string{"sensor_descr": "PS2"},
+			Fields: map[string]interface{}{"sensor_state": 1},
+		}},
+		//An Unknown State Keeps The Severity Of The Notification
+		{"fan", []g.SnmpPDU{
+			{Name: ".1.3.6.1.4.1.9.9.13.1.4.1.3.1", Value: 9},
+		}, data.Event{
+			Type: "envmon_fan", Severity: data.WARNING, Message: "Environmental Monitor Notification: fan Is In State 9",
+			Tags:   map[string]string{},
+			Fields: map[string]interface{}{"sensor_state": 9},
+		}},
+		{"shutdown", nil, data.Event{
+			Type: "envmon_shutdown", Severity: data.CRITICAL, Message: "Environmental Monitor Notification: shutdown",
+			Tags: map[string]string{},
+		}},
+	}
+	for i, test := range tests {
+		events := envmon(test.kind)(test.vars)
+		if len(events) != 1 || fmt.Sprintf("%v", events[0]) != fmt.Sprintf("%v", test.expected) {
+			t.Errorf("Case %d - Expected %v, Got %v", i, test.expected, events)
+		}
+	}
+}
+
+func TestPeer2Address(t *testing.T) {
+	tests := map[string]string{
+		"1.4.10.0.0.1": "10.0.0.1",
+		"2.16.32.1.13.184.0.0.0.0.0.0.0.0.0.0.0.1": "2001:db8::1",
+		"1": "1",
+		"":  "",
+	}
+	for index, expected := range tests {
+		if got := peer2Address(index); got != expected {
+			t.Errorf("%q - Expected %q, Got %q", index, expected, got)
+		}
+	}
+}
+
+func TestClose(t *testing.T) {
+	if err := Listen(&config.Traps{Address: "127.0.0.1:0"}, devices.Hosts{}); err != nil {
+		t.Fatal(err)
+	}
+	r := active
+	defer func() { active = nil }()
+
+	//The Listener Is Closed And The Workers End Once The Queue Is Drained
+	if !Close(time.Second) {
+		t.Fatal("Expected The Receiver To Close Within The Timeout")
+	}
+	if _, open := <-r.queue; open {
+		t.Error("Expected The Queue To Be Closed")
+	}
+	if _, err := r.conn.WriteToUDP([]byte{0}, r.conn.LocalAddr().(*net.UDPAddr)); err == nil {
+		t.Error("Expected The Listener To Be Closed")
+	}
+}